@@ -34,6 +34,15 @@ func (x *IstioDistribution) ToString() string {
 	return fmt.Sprintf("%s-%s-v%d", x.Version, x.Flavor, x.FlavorVersion)
 }
 
+// ImageTag returns the tag of the container images corresponding to the distribution
+func (x *IstioDistribution) ImageTag() string {
+	if x.Flavor == IstioDistributionFlavorIstio {
+		// upstream images are tagged only with the upstream version
+		return x.Version
+	}
+	return x.ToString()
+}
+
 func (x *IstioDistribution) Equal(j *IstioDistribution) bool {
 	return x.Version == j.Version &&
 		x.Flavor == j.Flavor &&
@@ -71,10 +80,15 @@ func (x *IstioDistribution) Group() (string, error) {
 	return fmt.Sprintf("%s.%s-%s", ts[0], ts[1], x.Flavor), nil
 }
 
+// MinorVersion returns the upstream minor version which is the key of the end of life dates in the manifest, e.g. "1.8"
+func (x *IstioDistribution) MinorVersion() string {
+	ts := strings.Split(x.Version, ".")
+	return strings.Join(ts[:len(ts)-1], ".")
+}
+
 func (x *IstioDistribution) IsUpstream() bool {
-	// manifest.json denotes upstream by flavor 'istio'. Whereas the actual upstream images
-	// in the cluster is of the form 'x.y.z' with no flavor set
-	return x.Flavor == ""
+	// manifest.json denotes upstream by flavor 'istio', to which the upstream version 'x.y.z' is mapped
+	return x.Flavor == IstioDistributionFlavorIstio
 }
 
 // compare two (patch version, flavor version) tuples in the same group
@@ -111,12 +125,12 @@ func (x *IstioDistribution) GreaterThan(y *IstioDistribution) (bool, error) {
 
 func IstioDistributionFromString(in string) (*IstioDistribution, error) {
 	if !strings.Contains(in, "-") {
-		// handle the upstream version schema: 'x.y.z'
+		// handle the upstream version schema: 'x.y.z', which is denoted by flavor 'istio' in manifest.json
 		if err := verifyUpstreamVersionString(in); err != nil {
 			return nil, err
 		}
 
-		return &IstioDistribution{Version: in}, nil
+		return &IstioDistribution{Version: in, Flavor: IstioDistributionFlavorIstio}, nil
 	}
 
 	parts := strings.SplitN(in, "-", 2)
@@ -176,6 +176,17 @@ func TestIstioDistribution_Group(t *testing.T) {
 	}
 }
 
+func TestIstioDistribution_MinorVersion(t *testing.T) {
+	require.Equal(t, "1.8", (&IstioDistribution{Version: "1.8.3", Flavor: "tetrate"}).MinorVersion())
+	require.Equal(t, "1.10", (&IstioDistribution{Version: "1.10.0", Flavor: "istio"}).MinorVersion())
+}
+
+func TestIstioDistribution_ImageTag(t *testing.T) {
+	require.Equal(t, "1.9.5-tetrate-v0", (&IstioDistribution{Version: "1.9.5", Flavor: "tetrate"}).ImageTag())
+	require.Equal(t, "1.9.5-tetratefips-v1", (&IstioDistribution{Version: "1.9.5", Flavor: "tetratefips", FlavorVersion: 1}).ImageTag())
+	require.Equal(t, "1.9.5", (&IstioDistribution{Version: "1.9.5", Flavor: "istio"}).ImageTag())
+}
+
 func TestIstioDistribution_IsUpstream(t *testing.T) {
 	require.True(t, (&IstioDistribution{Flavor: "istio"}).IsUpstream())
 	require.False(t, (&IstioDistribution{Flavor: "tetrate"}).IsUpstream())
@@ -239,6 +250,8 @@ func TestIstioDistributionFromString(t *testing.T) {
 				exp: &IstioDistribution{Version: "1.1000.3", Flavor: "tetratefips", FlavorVersion: 1}},
 			{in: "1.8.3-istio-v0",
 				exp: &IstioDistribution{Version: "1.8.3", Flavor: "istio", FlavorVersion: 0}},
+			{in: "1.9.5",
+				exp: &IstioDistribution{Version: "1.9.5", Flavor: "istio", FlavorVersion: 0}},
 			{in: "1.7.30-tetratefips-v100",
 				exp: &IstioDistribution{Version: "1.7.30", Flavor: "tetratefips", FlavorVersion: 100}},
 			{in: "2001.7.3-tetrate-v0",
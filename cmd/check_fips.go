@@ -0,0 +1,114 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tetratelabs/getmesh/src/fips"
+	"github.com/tetratelabs/getmesh/src/getmesh"
+	"github.com/tetratelabs/getmesh/src/util"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func newCheckFIPSCmd() *cobra.Command {
+	var (
+		output, istioNamespace string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "check-fips",
+		Short: "Verify that the mesh consistently runs the FIPS builds",
+		Long: `Verify that the mesh consistently runs the FIPS builds of the tetratefips flavor, and print the pass/fail report.
+The following are checked:
+  istioctl        the active istioctl is a tetratefips build
+  control plane   every istiod runs a tetratefips build
+  gateway         every gateway proxy runs a tetratefips build
+  sidecar         every sidecar proxy runs a tetratefips build
+  mesh config     tlsDefaults of the mesh configs only contain the cipher suites and the curves approved for FIPS
+  gateway tls     the Gateway resources only contain the cipher suites approved for FIPS
+The command fails with the exit code 20 if any check fails.`,
+		Example: `# example output
+$ getmesh check-fips
+CATEGORY	TARGET						VALUE					RESULT
+istioctl	active						1.9.5-tetratefips-v0			PASS
+control plane	istio-system/istiod (revision default)		1.9.5-tetratefips-v0			PASS
+gateway		istio-system/istio-ingressgateway (2 proxies)	1.9.5-tetratefips-v0			PASS
+sidecar		default/reviews (3 proxies)			1.9.5-tetrate-v0			FAIL: not a tetratefips build
+mesh config	istio-system/istio tlsDefaults.cipherSuites	default					PASS
+mesh config	istio-system/istio tlsDefaults.ecdhCurves	default					PASS
+gateway tls	default/bookinfo-gateway servers[0]		ECDHE-RSA-AES128-SHA			FAIL: not approved for FIPS: ECDHE-RSA-AES128-SHA
+
+Result: FAIL (2 of 7 checks failed)
+
+# save the report in JSON for auditors
+$ getmesh check-fips -o json > fips-report.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %q: must be table or json", output)
+			}
+
+			config, err := util.GetK8sConfig()
+			if err != nil {
+				return err
+			}
+			kubeCli, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				return fmt.Errorf("failed to generate k8s client: %w", err)
+			}
+			dyn, err := dynamic.NewForConfig(config)
+			if err != nil {
+				return fmt.Errorf("failed to generate k8s dynamic client: %w", err)
+			}
+
+			i := &fips.Inspector{KubeCli: kubeCli, Dynamic: dyn, IstioNamespace: istioNamespace}
+			r, err := i.Inspect(getmesh.GetActiveConfig().IstioDistribution, time.Now())
+			if err != nil {
+				return err
+			}
+
+			if output == "json" {
+				raw, err := json.MarshalIndent(r, "", "  ")
+				if err != nil {
+					return fmt.Errorf("error marshaling the report: %w", err)
+				}
+				logger.Infof("%s\n", raw)
+			} else {
+				r.Print(logger.GetWriter())
+			}
+			return checkFIPSExitError(r)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+	flags.StringVarP(&output, "output", "o", "table", "Output format of the report: table or json")
+	flags.StringVarP(&istioNamespace, "istio-namespace", "i", "istio-system", "Namespace of the mesh configs")
+	return cmd
+}
+
+func checkFIPSExitError(r *fips.Report) error {
+	if r.Passed {
+		return nil
+	}
+	return &exitError{code: exitCodeFIPSNonCompliant,
+		err: fmt.Errorf("the mesh is not FIPS compliant: %d of %d checks failed", len(r.Failed()), len(r.Checks))}
+}
@@ -0,0 +1,32 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/src/fips"
+)
+
+func TestCheckFIPSExitError(t *testing.T) {
+	require.NoError(t, checkFIPSExitError(&fips.Report{Passed: true, Checks: []fips.Check{{Passed: true}}}))
+
+	err := checkFIPSExitError(&fips.Report{Checks: []fips.Check{{Passed: true}, {Reason: "not a tetratefips build"}}})
+	require.Error(t, err)
+	require.Equal(t, exitCodeFIPSNonCompliant, exitCode(err))
+	require.Equal(t, "the mesh is not FIPS compliant: 1 of 2 checks failed", err.Error())
+}
@@ -19,25 +19,49 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"io"
 	"strings"
+	"sync"
+	"time"
 
 	istioversion "istio.io/pkg/version"
 
 	"github.com/spf13/cobra"
 
+	"github.com/tetratelabs/getmesh/api"
 	"github.com/tetratelabs/getmesh/src/checkupgrade"
 	"github.com/tetratelabs/getmesh/src/getmesh"
 	"github.com/tetratelabs/getmesh/src/istioctl"
 	"github.com/tetratelabs/getmesh/src/manifest"
+	"github.com/tetratelabs/getmesh/src/util"
 	"github.com/tetratelabs/getmesh/src/util/logger"
 )
 
 func newCheckCmd(homedir string) *cobra.Command {
-	return &cobra.Command{
+	var (
+		details, remediation, allContexts, publish, save bool
+		contexts, failOn                                 []string
+		publishNamespace, compare                        string
+	)
+	cmd := &cobra.Command{
 		Use:   "check-upgrade",
 		Short: "Check if there are patches available in the current minor version",
-		Long:  `Check if there are patches available in the current minor version, e.g. 1.7-tetrate: 1.7.4-tetrate-v1 -> 1.7.5-tetrate-v1`,
+		Long: `Check if there are patches available in the current minor version, e.g. 1.7-tetrate: 1.7.4-tetrate-v1 -> 1.7.5-tetrate-v1
+
+The versions are read from the image tags of the istiod deployments and the proxies via the Kubernetes API.
+"istioctl version" is used instead if they cannot be determined, e.g. when the images are referenced by digest.
+Each running minor version is annotated with its end of life date, and the fetched istioctl in the minor versions past the end of life are warned.
+
+Without --fail-on, the command exits with 1 when newer patches are available or the minor versions are no longer supported.
+With --fail-on, the issues in the given categories make the command fail with the exit code below instead.
+When multiple categories are found, the largest code is used.
+   9: near-eol      the minor versions are reaching the end of life within "getmesh config set eol-warning-days"
+  10: patch         newer patches are available
+  11: multi-minor   multiple minor versions are running in the data plane or the control plane
+  12: gateway-skew  gateways do not run the version of the control planes of their revisions
+  13: eol           the minor versions are no longer supported or past the end of life
+  14: security      the newer patches include security fixes
+Other failures, e.g. unreachable clusters, exit with 1.`,
 		Example: `# example output
 $ getmesh check-upgrade
 ...
@@ -46,43 +70,534 @@ $ getmesh check-upgrade
 - The minor version 1.6-tetrate is not supported by Tetrate.io. We recommend you use the trusted minor versions in "getmesh list"
 - There is the available patch for the minor version 1.7-tetrate. We recommend upgrading all 1.7-tetrate versions -> 1.7.4-tetrate-v1
 - There is the available patch for the minor version 1.8-tetrate which includes **security upgrades**. We strongly recommend upgrading all 1.8-tetrate versions -> 1.8.1-tetrate-v1
+- The minor version 1.8-tetrate is reaching the end of life on 2021-05-11 (in 10 days). We recommend planning the upgrade to the higher minor versions in "getmesh list"
 
 In the above example, we call names in the form of x.y-${flavor} "minor version", where x.y is Istio's upstream minor and ${flavor} is the flavor of the distribution.
-Please refer to 'getmesh fetch --help' or 'getmesh list --help' for more information.`,
+The upstream versions in the form of x.y.z are checked as the "istio" flavor, along with the equivalent Tetrate build.
+Please refer to 'getmesh fetch --help' or 'getmesh list --help' for more information.
+
+# list the outdated proxies grouped by the owner workloads, and the commands to restart them
+$ getmesh check-upgrade --details --remediation
+...
+[Outdated proxies]
+- Deployment default/reviews-v1: 1.8.1-tetrate-v0 -> 1.8.3-tetrate-v0
+    reviews-v1-7f99cc4496-4xd2z
+    reviews-v1-7f99cc4496-zv5rw
+
+[Remediation]
+# Make sure the control plane of the recommended version is running and injecting the namespaces, then run:
+kubectl rollout restart deployment/reviews-v1 -n default
+
+# the proxies are broken down by the revisions, and the gateways are checked against the control planes of their revisions
+$ getmesh check-upgrade --fail-on security,gateway-skew
+...
+[Revisions]
+- canary: control plane 1.8.3-tetrate-v0
+    sidecars: 1.8.3-tetrate-v0 (3 proxies)
+    gateway istio-system/istio-ingressgateway: 1.8.1-tetrate-v0 (2 proxies)
+- default: control plane 1.8.1-tetrate-v0
+    sidecars: 1.8.1-tetrate-v0 (10 proxies)
+
+[Gateway Check]
+- The gateway istio-system/istio-ingressgateway runs 1.8.1-tetrate-v0 while the control plane of its revision canary runs 1.8.3-tetrate-v0. We recommend restarting or upgrading the gateway to match the control plane
+
+# check all the clusters in the kubeconfig. When the Kubernetes API is not available, each cluster is checked with the fetched istioctl in the same minor version as its control plane if any
+$ getmesh check-upgrade --all-contexts
+CONTEXT	ISTIOCTL		CONTROL PLANE		DATA PLANE					RECOMMENDED				SECURITY		STATUS
+dev		1.8.3-tetrate-v0	1.8.3-tetrate-v0	1.8.3-tetrate-v0 (12)				-					-			OK
+prod-eu		1.8.3-tetrate-v0	1.8.1-tetrate-v0	1.8.1-tetrate-v0 (30)				1.8-tetrate -> 1.8.3-tetrate-v0		SECURITY UPGRADE	ISSUE FOUND
+prod-us		1.7.8-tetrate-v0	1.7.8-tetrate-v0	1.7.5-tetrate-v0 (3), 1.7.8-tetrate-v0 (40)	1.7-tetrate -> 1.7.8-tetrate-v0		-			ISSUE FOUND
+
+# check the given clusters
+$ getmesh check-upgrade --contexts prod-eu,prod-us
+
+# track the progress of the rollout across multiple weeks by saving the snapshots and comparing them with the latest one
+$ getmesh check-upgrade --save --compare previous
+...
+[Changes since 2021-05-01T00:00:00Z (14 days ago)]
+- Data plane:
+    1.8.1-tetrate-v0: 30 -> 10 (-20)
+    1.8.3-tetrate-v0: 0 -> 20 (+20)
+- New versions: 1.8.3-tetrate-v0
+- Outdated proxies: 30 -> 10 (20 upgraded)
+- The security patch for 1.8-tetrate (-> 1.8.3-tetrate-v0) has been pending for 21 days since 2021-04-24T00:00:00Z
+The snapshot is saved to /home/user/.getmesh/snapshots/20210515T000000Z.json
+
+# compare with the specific snapshot
+$ getmesh check-upgrade --compare 20210501T000000Z
+
+# fail only when security patches are available, e.g. in CI
+$ getmesh check-upgrade --fail-on security
+
+# run in the cluster, e.g. as a CronJob, and publish the report to the ConfigMap istio-system/getmesh-health-report.
+# The service account needs to list deployments and pods in all namespaces, and to get, create and update the ConfigMap
+$ getmesh check-upgrade --in-cluster --publish --fail-on none
+$ kubectl get configmap getmesh-health-report -n istio-system -o jsonpath='{.data.report\.json}'
+{
+  "apiVersion": "getmesh.tetrate.io/v1alpha1",
+  "kind": "MeshHealthReport",
+  ...
+  "conditions": [
+    {
+      "type": "SecurityPatchPending",
+      "status": "True",
+      "reason": "SecurityPatchAvailable",
+      "message": "security patches are available: 1.8-tetrate -> 1.8.3-tetrate-v0",
+      "lastTransitionTime": "2021-05-01T00:00:00Z"
+    },
+    ...`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if getmesh.GetActiveConfig().IstioDistribution == nil {
+			// istioctl is not necessary in the cluster since the versions are read via the Kubernetes API
+			if !util.InCluster && getmesh.GetActiveConfig().IstioDistribution == nil {
 				return errors.New("please fetch Istioctl by `getmesh fetch` beforehand")
 			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			categories, err := checkUpgradeParseFailOn(failOn)
+			if err != nil {
+				return err
+			}
+			// keep exiting with 1 as before unless the categories are given explicitly
+			exitCodes := cmd.Flags().Changed("fail-on")
+
 			ms, err := manifest.FetchManifest()
 			if err != nil {
 				return fmt.Errorf(" failed to fetch manifests")
 			}
 
-			w := new(bytes.Buffer)
-			if err := istioctl.ExecWithWriters(homedir, []string{"version", "-o", "json"}, w, nil); err != nil {
-				return fmt.Errorf("error executing istioctl: %v", err)
+			// no istioctl is fetched in the cluster
+			if !util.InCluster {
+				if err := checkUpgradeFetchedEndOfLife(homedir, ms); err != nil {
+					return err
+				}
 			}
 
-			msg := w.String()
-			if strings.Contains(msg, istioctl.IstioVersionNoPodRunningMsg) {
-				logger.Infof(istioctl.IstioVersionNoPodRunningMsg + "\n")
+			if allContexts || len(contexts) > 0 {
+				if details || remediation || publish || save || compare != "" || util.InCluster {
+					return errors.New("--details, --remediation, --publish, --save, --compare and --in-cluster cannot be used with multiple contexts")
+				}
+				if allContexts {
+					if contexts, err = util.GetKubeContexts(util.GetKubeConfigLocation()); err != nil {
+						return err
+					}
+				}
+				issues, failed := checkUpgradeFleet(homedir, contexts, ms)
+				if err := checkUpgradeExitError(issues, categories, exitCodes); err != nil {
+					return err
+				} else if failed > 0 {
+					return fmt.Errorf("failed to check %d of %d contexts", failed, len(contexts))
+				}
 				return nil
 			}
 
-			var iv istioversion.Version
-			if err := json.Unmarshal(w.Bytes(), &iv); err != nil {
-				return fmt.Errorf("failed to parse istio version results: %v: %s", err, w.Bytes())
+			active := getmesh.GetActiveConfig().IstioDistribution
+			mesh, err := checkUpgradeCollect(active, "")
+			var iv *istioversion.Version
+			if mesh != nil {
+				iv = mesh.Version
+			}
+			if err != nil && active == nil {
+				return fmt.Errorf("failed to collect the versions via the Kubernetes API: %v", err)
+			} else if err != nil {
+				logger.Warnf("failed to collect the versions via the Kubernetes API, falling back to istioctl: %v\n", err)
+				if iv, err = checkUpgradeIstioctlVersion(homedir, active, ""); err != nil {
+					return err
+				}
+			}
+			if iv == nil {
+				logger.Infof(istioctl.IstioVersionNoPodRunningMsg + "\n")
+				// still publish and save the empty state so that the previous results do not remain as they are
+				if publish {
+					if err := checkUpgradePublish(istioversion.Version{}, ms, nil, publishNamespace); err != nil {
+						return err
+					}
+				}
+				if save || compare != "" {
+					return checkUpgradeSnapshot(homedir, istioversion.Version{}, ms, save, compare)
+				}
+				return nil
 			}
 
-			if err := checkupgrade.IstioVersion(iv, ms); err != nil && err != checkupgrade.ErrIssueFound {
+			issues, err := checkupgrade.IstioVersion(*iv, ms)
+			if err != nil {
 				return fmt.Errorf("failed to check Istio version: %v", err)
-			} else if err == checkupgrade.ErrIssueFound {
-				os.Exit(1)
 			}
-			return nil
+
+			// the revisions and the gateways are only available via the Kubernetes API
+			var skewedGateways []checkupgrade.GatewayStatus
+			if mesh != nil {
+				skewedGateways = checkUpgradeRevisions(mesh)
+				issues.GatewaySkew = len(skewedGateways) > 0
+			}
+
+			if details || remediation {
+				if err := checkUpgradePrintDetails(*iv, ms, details, remediation); err != nil {
+					return err
+				}
+			}
+
+			if publish {
+				if err := checkUpgradePublish(*iv, ms, skewedGateways, publishNamespace); err != nil {
+					return err
+				}
+			}
+
+			if save || compare != "" {
+				if err := checkUpgradeSnapshot(homedir, *iv, ms, save, compare); err != nil {
+					return err
+				}
+			}
+
+			return checkUpgradeExitError(issues, categories, exitCodes)
 		},
 	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&details, "details", "", false, "List each outdated proxy grouped by the owner workload with the recommended version")
+	flags.BoolVarP(&remediation, "remediation", "", false, "Print the kubectl commands to restart the workloads of the outdated proxies")
+	flags.BoolVarP(&allContexts, "all-contexts", "", false, "Check all the contexts in the kubeconfig and print the combined report")
+	flags.StringSliceVarP(&contexts, "contexts", "", nil, "Comma separated contexts in the kubeconfig to check, e.g. --contexts prod-us,prod-eu")
+	flags.BoolVarP(&util.InCluster, "in-cluster", "", false,
+		"Use the service account of the pod instead of the kubeconfig, e.g. when running as a Kubernetes CronJob")
+	flags.BoolVarP(&publish, "publish", "", false,
+		fmt.Sprintf("Write the results as the %s in the ConfigMap %q", checkupgrade.HealthReportKind, checkupgrade.HealthReportName))
+	flags.StringVarP(&publishNamespace, "publish-namespace", "", "istio-system", "Namespace of the ConfigMap written by --publish")
+	flags.BoolVarP(&save, "save", "", false,
+		"Save the snapshot of the versions under the getmesh home directory to be compared later by --compare")
+	flags.StringVarP(&compare, "compare", "", "",
+		fmt.Sprintf("Show the changes since the snapshot given by the file name or path, or %q for the latest one of the current context", checkupgrade.SnapshotPrevious))
+	flags.StringSliceVarP(&failOn, "fail-on", "", checkUpgradeDefaultFailOn,
+		"Comma separated categories of the issues which make the command fail: none, near-eol, patch, security, eol, multi-minor or gateway-skew")
+	return cmd
+}
+
+func checkUpgradePrintDetails(iv istioversion.Version, ms *api.Manifest, details, remediation bool) error {
+	if iv.DataPlaneVersion == nil || len(*iv.DataPlaneVersion) == 0 {
+		return nil
+	}
+
+	kubeCli, err := util.GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	proxies, err := checkupgrade.OutdatedProxies(*iv.DataPlaneVersion, ms, checkupgrade.NewOwnerResolver(kubeCli))
+	if err != nil {
+		return fmt.Errorf("failed to list outdated proxies: %v", err)
+	}
+
+	if details {
+		checkupgrade.PrintOutdatedProxies(proxies)
+	}
+	if remediation {
+		checkupgrade.PrintRemediation(proxies)
+	}
+	return nil
+}
+
+// the number of clusters checked at the same time
+const checkUpgradeConcurrency = 4
+
+// checkUpgradeCollect reads the versions of the mesh in the context, or the current context if empty, via the Kubernetes API.
+// nil is returned when no istiod is running
+func checkUpgradeCollect(active *api.IstioDistribution, context string) (*checkupgrade.Mesh, error) {
+	kubeCli, err := util.GetK8sClientForContext(context)
+	if err != nil {
+		return nil, err
+	}
+	var client string
+	if active != nil {
+		client = active.ToString()
+	}
+	return checkupgrade.CollectMesh(kubeCli, client)
+}
+
+// checkUpgradeFetchedEndOfLife warns about the fetched istioctl which reached the end of life
+func checkUpgradeFetchedEndOfLife(homedir string, ms *api.Manifest) error {
+	fetched, err := istioctl.GetFetchedVersions(homedir)
+	if err != nil {
+		logger.Warnf("failed to list the fetched istioctl: %v\n", err)
+		return nil
+	}
+	return checkupgrade.PrintFetchedEndOfLife(fetched, ms, time.Now())
+}
+
+// checkUpgradeRevisions prints the breakdown by the revisions, and returns the gateways skewed from their revisions
+func checkUpgradeRevisions(mesh *checkupgrade.Mesh) []checkupgrade.GatewayStatus {
+	revs := checkupgrade.Revisions(mesh.ControlPlanes, mesh.Proxies)
+	checkupgrade.PrintRevisions(revs)
+	return checkupgrade.SkewedGateways(revs)
+}
+
+// checkUpgradePublish writes the results into the cluster so that they can be read without running the CLI
+func checkUpgradePublish(iv istioversion.Version, ms *api.Manifest, skewedGateways []checkupgrade.GatewayStatus, namespace string) error {
+	analysis, err := checkupgrade.Analyze(iv, ms)
+	if err != nil {
+		return fmt.Errorf("failed to check Istio version: %v", err)
+	}
+
+	kubeCli, err := util.GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	if err := checkupgrade.PublishHealthReport(kubeCli, namespace, checkupgrade.NewHealthReport(iv, analysis, skewedGateways, time.Now())); err != nil {
+		return err
+	}
+	logger.Infof("The report is published to the ConfigMap %s/%s\n", namespace, checkupgrade.HealthReportName)
+	return nil
+}
+
+// checkUpgradeSnapshot saves the snapshot of the current state and/or prints the changes since the given snapshot.
+// The comparison is made before saving so that "previous" does not refer to the snapshot saved in this run
+func checkUpgradeSnapshot(homedir string, iv istioversion.Version, ms *api.Manifest, save bool, compare string) error {
+	analysis, err := checkupgrade.Analyze(iv, ms)
+	if err != nil {
+		return fmt.Errorf("failed to check Istio version: %v", err)
+	}
+
+	var context string
+	if !util.InCluster {
+		if context, err = util.GetKubeContext(util.GetKubeConfigLocation()); err != nil {
+			return err
+		}
+	}
+
+	dir := checkupgrade.SnapshotDir(homedir)
+	prev, err := checkupgrade.LatestSnapshot(dir, context)
+	if err != nil {
+		return err
+	}
+	current := checkupgrade.NewSnapshot(context, iv, analysis, time.Now(), prev)
+
+	if compare != "" {
+		from := prev
+		if compare != checkupgrade.SnapshotPrevious {
+			if from, err = checkupgrade.LoadSnapshot(dir, compare); err != nil {
+				return err
+			}
+		} else if from == nil {
+			return fmt.Errorf("no snapshot is saved for the context %q: run with --save first", context)
+		}
+		logger.Infof("\n")
+		checkupgrade.PrintSnapshotDiff(checkupgrade.CompareSnapshots(from, current))
+	}
+
+	if save {
+		p, err := checkupgrade.SaveSnapshot(dir, current)
+		if err != nil {
+			return err
+		}
+		logger.Infof("The snapshot is saved to %s\n", p)
+	}
+	return nil
+}
+
+// checkUpgradeIstioctlVersion runs "istioctl version" of the distribution against the context, or the current context if empty.
+// nil is returned when no Istio pod is running
+func checkUpgradeIstioctlVersion(homedir string, d *api.IstioDistribution, context string) (*istioversion.Version, error) {
+	args := []string{"version", "-o", "json"}
+	if context != "" {
+		args = append(args, "--context", context, "--kubeconfig", util.GetKubeConfigLocation())
+	}
+
+	w, errW := new(bytes.Buffer), new(bytes.Buffer)
+	if err := istioctl.ExecDistribution(homedir, d, args, w, errW); err != nil {
+		return nil, fmt.Errorf("error executing istioctl: %v: %s", err, strings.TrimSpace(errW.String()))
+	}
+
+	if strings.Contains(w.String(), istioctl.IstioVersionNoPodRunningMsg) {
+		return nil, nil
+	}
+
+	var iv istioversion.Version
+	if err := json.Unmarshal(w.Bytes(), &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse istio version results: %v: %s", err, w.Bytes())
+	}
+	return &iv, nil
+}
+
+// checkUpgradeSelectIstioctl chooses the fetched istioctl in the same minor version as the control plane
+// since istioctl may not be compatible with the control planes in the other minor versions
+func checkUpgradeSelectIstioctl(active *api.IstioDistribution, fetched []*api.IstioDistribution, iv *istioversion.Version) *api.IstioDistribution {
+	if iv == nil || iv.MeshVersion == nil || len(*iv.MeshVersion) == 0 {
+		return active
+	}
+
+	cp, err := api.IstioDistributionFromString((*iv.MeshVersion)[0].Info.Version)
+	if err != nil {
+		return active
+	}
+	group, err := cp.Group()
+	if err != nil {
+		return active
+	}
+	if g, _ := active.Group(); g == group {
+		return active
+	}
+
+	var ret *api.IstioDistribution
+	for _, f := range fetched {
+		if f.Equal(cp) {
+			return f
+		}
+		if g, _ := f.Group(); g != group {
+			continue
+		}
+		if ret == nil {
+			ret = f
+		} else if ok, _ := f.GreaterThan(ret); ok {
+			ret = f
+		}
+	}
+
+	if ret == nil {
+		return active
+	}
+	return ret
+}
+
+// checkUpgradeCluster checks the cluster of the context via the Kubernetes API, or with the suitable istioctl as the fallback.
+// The warnings are written to out so that the outputs of the clusters checked concurrently do not interleave
+func checkUpgradeCluster(homedir, context string, active *api.IstioDistribution, fetched []*api.IstioDistribution,
+	ms *api.Manifest, out io.Writer) *checkupgrade.ClusterReport {
+	d := active
+	var iv *istioversion.Version
+	mesh, err := checkUpgradeCollect(active, context)
+	if mesh != nil {
+		iv = mesh.Version
+	}
+	if err != nil {
+		fmt.Fprintf(out, "[WARNING] failed to collect the versions in %s via the Kubernetes API, falling back to istioctl: %v\n", context, err)
+		if iv, err = checkUpgradeIstioctlVersion(homedir, active, context); err != nil {
+			return &checkupgrade.ClusterReport{Context: context, Err: err}
+		}
+
+		if d = checkUpgradeSelectIstioctl(active, fetched, iv); !d.Equal(active) {
+			if iv, err = checkUpgradeIstioctlVersion(homedir, d, context); err != nil {
+				return &checkupgrade.ClusterReport{Context: context, Err: err}
+			}
+		}
+	}
+
+	if iv == nil {
+		return &checkupgrade.ClusterReport{Context: context, Istioctl: d.ToString(), NotInstalled: true}
+	}
+
+	ret, err := checkupgrade.Summarize(*iv, ms)
+	if err != nil {
+		return &checkupgrade.ClusterReport{Context: context, Err: err}
+	}
+	ret.Context, ret.Istioctl = context, d.ToString()
+	return ret
+}
+
+// checkUpgradeFleet checks the clusters concurrently and prints the combined report.
+// The issues found in all the clusters and the number of the clusters failed to be checked are returned
+func checkUpgradeFleet(homedir string, contexts []string, ms *api.Manifest) (checkupgrade.Issues, int) {
+	active := getmesh.GetActiveConfig().IstioDistribution
+	fetched, err := istioctl.GetFetchedVersions(homedir)
+	if err != nil {
+		logger.Warnf("failed to list the fetched istioctl: %v\n", err)
+	}
+
+	reports := make([]*checkupgrade.ClusterReport, len(contexts))
+	outs := make([]*bytes.Buffer, len(contexts))
+	sem := make(chan struct{}, checkUpgradeConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range contexts {
+		wg.Add(1)
+		go func(i int, c string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			outs[i] = new(bytes.Buffer)
+			reports[i] = checkUpgradeCluster(homedir, c, active, fetched, ms, outs[i])
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, out := range outs {
+		logger.Infof("%s", out.String())
+	}
+
+	checkupgrade.PrintFleetReport(reports)
+	var issues checkupgrade.Issues
+	var failed int
+	for _, r := range reports {
+		if r.Err != nil {
+			failed++
+		}
+		issues = issues.Merge(r.Issues)
+	}
+	return issues, failed
+}
+
+// the issues which failed check-upgrade before --fail-on was introduced, with exit code 1 unless --fail-on is given
+var checkUpgradeDefaultFailOn = []string{
+	string(checkupgrade.CategoryPatch), string(checkupgrade.CategorySecurity), string(checkupgrade.CategoryEOL),
+}
+
+const checkUpgradeFailOnNone = "none"
+
+var checkUpgradeExitCodes = map[checkupgrade.Category]int{
+	checkupgrade.CategoryNearEOL:     exitCodeUpgradeNearEOL,
+	checkupgrade.CategoryPatch:       exitCodeUpgradePatch,
+	checkupgrade.CategoryMultiMinor:  exitCodeUpgradeMultiMinor,
+	checkupgrade.CategoryGatewaySkew: exitCodeUpgradeGatewaySkew,
+	checkupgrade.CategoryEOL:         exitCodeUpgradeEOL,
+	checkupgrade.CategorySecurity:    exitCodeUpgradeSecurity,
+}
+
+func checkUpgradeParseFailOn(in []string) ([]checkupgrade.Category, error) {
+	var ret []checkupgrade.Category
+	for _, v := range in {
+		if v == checkUpgradeFailOnNone {
+			if len(in) > 1 {
+				return nil, fmt.Errorf("--fail-on %s cannot be combined with the other categories", checkUpgradeFailOnNone)
+			}
+			return nil, nil
+		}
+
+		c := checkupgrade.Category(v)
+		if _, ok := checkUpgradeExitCodes[c]; !ok {
+			return nil, fmt.Errorf("invalid --fail-on category %q: must be one of none, near-eol, patch, security, eol, multi-minor or gateway-skew", v)
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+// checkUpgradeExitError returns the error with the exit code of the most severe issue in the given categories, or nil if not found.
+// The error exits with 1 regardless of the categories unless exitCodes is true
+func checkUpgradeExitError(issues checkupgrade.Issues, failOn []checkupgrade.Category, exitCodes bool) error {
+	var found []string
+	var code int
+	for _, c := range checkupgrade.Categories {
+		if !issues.Has(c) || !checkUpgradeContains(failOn, c) {
+			continue
+		}
+		found = append(found, string(c))
+		code = checkUpgradeExitCodes[c]
+	}
+
+	if code == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("issues found: %s", strings.Join(found, ", "))
+	if !exitCodes {
+		return err
+	}
+	return &exitError{code: code, err: err}
+}
+
+func checkUpgradeContains(cs []checkupgrade.Category, c checkupgrade.Category) bool {
+	for _, v := range cs {
+		if v == c {
+			return true
+		}
+	}
+	return false
 }
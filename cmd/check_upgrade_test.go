@@ -0,0 +1,120 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/checkupgrade"
+)
+
+func TestCheckUpgradeSelectIstioctl(t *testing.T) {
+	active := &api.IstioDistribution{Version: "1.9.0", Flavor: api.IstioDistributionFlavorTetrate, FlavorVersion: 0}
+	fetched := []*api.IstioDistribution{
+		active,
+		{Version: "1.8.1", Flavor: api.IstioDistributionFlavorTetrate, FlavorVersion: 0},
+		{Version: "1.8.3", Flavor: api.IstioDistributionFlavorTetrate, FlavorVersion: 0},
+		{Version: "1.7.4", Flavor: api.IstioDistributionFlavorTetrate, FlavorVersion: 0},
+	}
+	withControlPlane := func(v string) *istioversion.Version {
+		return &istioversion.Version{MeshVersion: &istioversion.MeshInfo{
+			istioversion.ServerInfo{Component: "pilot", Info: istioversion.BuildInfo{Version: v}},
+		}}
+	}
+
+	for _, c := range []struct {
+		name     string
+		iv       *istioversion.Version
+		expected *api.IstioDistribution
+	}{
+		{name: "not installed", iv: nil, expected: active},
+		{name: "same minor as active", iv: withControlPlane("1.9.2-tetrate-v0"), expected: active},
+		{name: "exact match", iv: withControlPlane("1.8.1-tetrate-v0"), expected: fetched[1]},
+		{name: "latest in the same minor", iv: withControlPlane("1.8.2-tetrate-v0"), expected: fetched[2]},
+		{name: "no fetched istioctl in the minor", iv: withControlPlane("1.6.14-tetrate-v0"), expected: active},
+		{name: "invalid version", iv: withControlPlane("invalid"), expected: active},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, checkUpgradeSelectIstioctl(active, fetched, c.iv))
+		})
+	}
+}
+
+func TestCheckUpgradeParseFailOn(t *testing.T) {
+	actual, err := checkUpgradeParseFailOn(checkUpgradeDefaultFailOn)
+	require.NoError(t, err)
+	require.Equal(t, []checkupgrade.Category{checkupgrade.CategoryPatch, checkupgrade.CategorySecurity, checkupgrade.CategoryEOL}, actual)
+
+	actual, err = checkUpgradeParseFailOn([]string{"none"})
+	require.NoError(t, err)
+	require.Empty(t, actual)
+
+	for _, in := range [][]string{{"none", "security"}, {"critical"}} {
+		_, err = checkUpgradeParseFailOn(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestCheckUpgradeExitError(t *testing.T) {
+	all := []checkupgrade.Category{
+		checkupgrade.CategoryNearEOL, checkupgrade.CategoryPatch, checkupgrade.CategoryMultiMinor, checkupgrade.CategoryGatewaySkew,
+		checkupgrade.CategoryEOL, checkupgrade.CategorySecurity,
+	}
+	for _, c := range []struct {
+		name     string
+		issues   checkupgrade.Issues
+		failOn   []checkupgrade.Category
+		expected int
+	}{
+		{name: "no issue", issues: checkupgrade.Issues{}, failOn: all},
+		{name: "none", issues: checkupgrade.Issues{Patch: true, Security: true}},
+		{name: "patch", issues: checkupgrade.Issues{Patch: true}, failOn: all, expected: exitCodeUpgradePatch},
+		{name: "near eol", issues: checkupgrade.Issues{NearEOL: true}, failOn: all, expected: exitCodeUpgradeNearEOL},
+		{name: "near eol not in default", issues: checkupgrade.Issues{NearEOL: true}, failOn: []checkupgrade.Category{
+			checkupgrade.CategoryPatch, checkupgrade.CategorySecurity, checkupgrade.CategoryEOL}},
+		{name: "gateway skew", issues: checkupgrade.Issues{MultiMinor: true, GatewaySkew: true}, failOn: all,
+			expected: exitCodeUpgradeGatewaySkew},
+		{name: "security only", issues: checkupgrade.Issues{Patch: true, MultiMinor: true},
+			failOn: []checkupgrade.Category{checkupgrade.CategorySecurity}},
+		{name: "most severe", issues: checkupgrade.Issues{Patch: true, EOL: true, Security: true}, failOn: all,
+			expected: exitCodeUpgradeSecurity},
+		{name: "most severe in fail-on", issues: checkupgrade.Issues{Patch: true, MultiMinor: true, Security: true},
+			failOn: []checkupgrade.Category{checkupgrade.CategoryPatch, checkupgrade.CategoryMultiMinor}, expected: exitCodeUpgradeMultiMinor},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkUpgradeExitError(c.issues, c.failOn, true)
+			if c.expected == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Equal(t, c.expected, exitCode(err))
+		})
+	}
+
+	t.Run("without --fail-on", func(t *testing.T) {
+		failOn, err := checkUpgradeParseFailOn(checkUpgradeDefaultFailOn)
+		require.NoError(t, err)
+
+		err = checkUpgradeExitError(checkupgrade.Issues{Patch: true, Security: true}, failOn, false)
+		require.EqualError(t, err, "issues found: patch, security")
+		require.Equal(t, 1, exitCode(err))
+		require.NoError(t, checkUpgradeExitError(checkupgrade.Issues{NearEOL: true, MultiMinor: true}, failOn, false))
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/tetratelabs/getmesh/src/getmesh"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func newConfigCmd(homedir string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View and modify getmesh settings",
+		Long: fmt.Sprintf(`View and modify getmesh settings stored in the getmesh home directory.
+Each setting can be overridden by the environment variable GETMESH_<KEY>, e.g. GETMESH_DEFAULT_HUB for "default-hub".
+
+Available keys:
+%s`, configKeysHelp()),
+		Example: `# Show all the settings
+$ getmesh config view
+
+# Show the manifest URL
+$ getmesh config get manifest-url
+
+# Set the default flavor used by "getmesh fetch"
+$ getmesh config set default-flavor tetratefips
+
+# Disable the confirmation prompts
+$ getmesh config set non-interactive true
+
+# Restore the default hub
+$ getmesh config unset default-hub
+`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "view",
+		Short: "Show all the settings with their sources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configHandleView()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <key>",
+		Short: "Show the value of the given key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configHandleGet(args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set the value of the given key",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configHandleSet(homedir, args[0], args[1])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "unset <key>",
+		Short: "Remove the value of the given key so that the default is used",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configHandleUnset(homedir, args[0])
+		},
+	})
+	return cmd
+}
+
+func configKeysHelp() string {
+	var b strings.Builder
+	for _, k := range getmesh.ConfigKeyNames {
+		desc, _ := getmesh.ConfigKeyDescription(k)
+		b.WriteString(fmt.Sprintf("  %s: %s\n", k, desc))
+	}
+	return b.String()
+}
+
+func configHandleView() error {
+	data := make([][]string, 0, len(getmesh.ConfigKeyNames))
+	for _, k := range getmesh.ConfigKeyNames {
+		v, src, err := getmesh.GetConfigValue(k)
+		if err != nil {
+			return err
+		}
+		if v == "" {
+			v = "-"
+		}
+		data = append(data, []string{k, v, src})
+	}
+
+	table := tablewriter.NewWriter(logger.GetWriter())
+	table.SetHeader([]string{"KEY", "VALUE", "SOURCE"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t") // pad with tabs
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+	return nil
+}
+
+func configHandleGet(key string) error {
+	v, src, err := getmesh.GetConfigValue(key)
+	if err != nil {
+		return err
+	}
+
+	if src == getmesh.ConfigSourceDefault {
+		logger.Infof("%s is not set. The default value is used\n", key)
+		return nil
+	}
+	logger.Infof("%s\n", v)
+	return nil
+}
+
+func configHandleSet(homedir, key, value string) error {
+	if err := getmesh.SetConfigValue(homedir, key, value); err != nil {
+		return err
+	}
+
+	logger.Infof("%s is now set to %s\n", key, value)
+	configWarnEnvOverride(key)
+	return nil
+}
+
+func configHandleUnset(homedir, key string) error {
+	if err := getmesh.UnsetConfigValue(homedir, key); err != nil {
+		return err
+	}
+
+	logger.Infof("%s is removed. Now the default value is used\n", key)
+	configWarnEnvOverride(key)
+	return nil
+}
+
+func configWarnEnvOverride(key string) {
+	if _, src, _ := getmesh.GetConfigValue(key); src == getmesh.ConfigSourceEnv {
+		logger.Warnf("%s is overridden by the environment variable %s\n", key, getmesh.ConfigKeyEnv(key))
+	}
+}
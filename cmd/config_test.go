@@ -0,0 +1,65 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/src/getmesh"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func Test_configHandlers(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	require.NoError(t, getmesh.InitConfig(home))
+
+	buf := logger.ExecuteWithLock(func() {
+		require.NoError(t, configHandleSet(home, "default-flavor", "tetratefips"))
+	})
+	require.Contains(t, buf.String(), "default-flavor is now set to tetratefips")
+	require.Equal(t, "tetratefips", getmesh.GetActiveConfig().DefaultFlavor)
+
+	buf = logger.ExecuteWithLock(func() {
+		require.NoError(t, configHandleGet("default-flavor"))
+	})
+	require.Equal(t, "tetratefips\n", buf.String())
+
+	buf = logger.ExecuteWithLock(func() {
+		require.NoError(t, configHandleView())
+	})
+	require.Contains(t, buf.String(), "default-flavor")
+	require.Contains(t, buf.String(), "tetratefips")
+
+	buf = logger.ExecuteWithLock(func() {
+		require.NoError(t, configHandleUnset(home, "default-flavor"))
+	})
+	require.Contains(t, buf.String(), "default-flavor is removed")
+
+	buf = logger.ExecuteWithLock(func() {
+		require.NoError(t, configHandleGet("default-flavor"))
+	})
+	require.Contains(t, buf.String(), "default-flavor is not set")
+
+	require.Error(t, configHandleSet(home, "default-flavor", "unknown"))
+	require.Error(t, configHandleGet("unknown-key"))
+}
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -26,13 +27,57 @@ import (
 )
 
 func newConfigValidateCmd(homedir string) *cobra.Command {
-	var flagNS, flagOutputThreshold string
+	var flagNS, flagOutputThreshold, flagOutput, flagSuppressionFile, flagBaseline string
+	var flagRuleFiles, flagIncludeNamespaces, flagExcludeNamespaces []string
+	var flagNamespaceSelector string
+	var flagOffline bool
+	var flagConcurrency int
+	var flagNamespaceTimeout time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "config-validate <file/directory>...",
 		Short: "Validate the current Istio configurations in your cluster",
 		Long: `Validate the current Istio configurations in your cluster just like 'istioctl analyze'. Inspect all namespaces by default.
-If the <file/directory> is specified, we analyze the effect of applying these yaml files against the current cluster.`,
+If the <file/directory> is specified, we analyze the effect of applying these yaml files against the current cluster.
+When inspecting all namespaces, the namespaces can be selected by --include-namespaces, --exclude-namespaces and --namespace-selector,
+and they are validated concurrently up to --concurrency.
+With --offline, the yaml files are validated by themselves without accessing the cluster, e.g. in CI.
+
+The configurations are validated by 'istioctl analyze' and Kiali. 'istioctl analyze' covers all the kinds its version supports, both in the cluster
+and in the yaml files, while Kiali only validates Gateway, VirtualService, DestinationRule, ServiceEntry, Sidecar, WorkloadEntry, EnvoyFilter,
+PeerAuthentication, AuthorizationPolicy and RequestAuthentication. The objects in the yaml files are told apart by their apiVersion,
+so that the Gateway of the Gateway API is not validated as an Istio Gateway by Kiali.
+
+The known issues can be suppressed by the suppression file, ` + "`" + configvalidator.SuppressionFileName + "`" + ` in the current directory by default:
+
+suppressions:
+- code: KIA0302                # the issues match all of the given code, namespace, name and resourceType
+  namespace: bookinfo
+  name: bookinfo-gateway
+  resourceType: Gateway
+  reason: the gateway workload is deployed by another team    # mandatory
+  expires: 2021-12-31          # optional. the issues are reported again from this date
+
+With --baseline, only the issues not found in the JSON output of a previous run are reported, so that CI can fail on the new issues only.
+The namespaces skipped in that run as their validation timed out are recorded as "NamespaceSkipped", and their issues are not reported as new.
+
+The house rules can be enforced by the custom rules in the files given by --rules. The expression is written in CEL (https://github.com/google/cel-spec),
+where the object is bound to "object", and the issue is reported when it evaluates to false. The code must not be prefixed by 'IST' or 'KIA'.
+Besides the kinds validated by Kiali, the rules can apply to WorkloadGroup, Telemetry, ProxyConfig and WasmPlugin, and the Gateway API kinds
+qualified by the group, e.g. Gateway.gateway.networking.k8s.io and HTTPRoute.gateway.networking.k8s.io. Other than 'istioctl analyze',
+these kinds are only checked by the rules. They are read in the version served by the cluster, e.g. v1alpha2, v1beta1 or v1 of the Gateway API:
+
+rules:
+- code: ACME001
+  kinds: [VirtualService]
+  severity: Warning            # Error, Warning or Info. Warning by default
+  message: every HTTP route must set the timeout
+  expression: "!has(object.spec.http) || object.spec.http.all(r, has(r.timeout))"
+- code: ACME002
+  kinds: [Gateway]
+  severity: Error
+  message: wildcard hosts are only allowed in the Gateways in istio-system
+  expression: "object.metadata.namespace == 'istio-system' || object.spec.servers.all(s, s.hosts.all(h, !h.contains('*')))"`,
 		Example: `# validating a local manifest against the current cluster
 $ getmesh config-validate my-app.yaml another-app.yaml
 
@@ -42,6 +87,22 @@ $ getmesh config-validate -n bookinfo my-manifest-dir/
 NAME                        	RESOURCE TYPE 	ERROR CODE	SEVERITY	MESSAGE
 httpbin                     	Service       	IST0108   	Warning 	[my-manifest-dir/service.yaml:1] Unknown annotation: networking.istio.io/non-exist
 
+# validating local manifests without the cluster
+$ getmesh config-validate --offline my-manifest-dir/
+
+NAMESPACE               NAME                    RESOURCE TYPE           ERROR CODE      SEVERITY        MESSAGE
+bookinfo                reviews                 Virtualservice          KIA1107         Warning         [my-manifest-dir/reviews.yaml] Subset not found
+
+# report only the new issues since the last run
+$ getmesh config-validate -o json > baseline.json
+$ getmesh config-validate --baseline baseline.json
+
+# enforce the custom rules as well
+$ getmesh config-validate --rules house-rules.yaml
+
+NAMESPACE               NAME                    RESOURCE TYPE           ERROR CODE      SEVERITY        MESSAGE
+bookinfo                bookinfo-gateway        Gateway                 ACME002         Error           wildcard hosts are only allowed in the Gateways in istio-system
+
 # for all namespaces
 $ getmesh config-validate
 
@@ -50,6 +111,9 @@ default                 bookinfo-gateway        Gateway                 IST0101
 bookinfo                default                 Peerauthentication      KIA0505         Error           Destination Rule disabling namespace-wide mTLS is missing
 bookinfo                bookinfo-gateway        Gateway                 KIA0302         Warning         No matching workload found for gateway selector in this namespace
 
+# for the namespaces of the team except the sandboxes
+$ getmesh config-validate --include-namespaces 'team-a-*' --exclude-namespaces '*-sandbox' --namespace-selector istio-injection=enabled
+
 # for a specific namespace
 $ getmesh config-validate -n bookinfo
 
@@ -88,7 +152,23 @@ The error code of the found issue which is prefixed by 'IST' or 'KIA'. Please re
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			validator, err := configvalidator.New(homedir, flagNS, flagOutputThreshold, args)
+			validator, err := configvalidator.New(homedir, configvalidator.Options{
+				Namespace:       flagNS,
+				OutputThreshold: flagOutputThreshold,
+				Files:           args,
+				Offline:         flagOffline,
+				Output:          flagOutput,
+				SuppressionFile: flagSuppressionFile,
+				BaselineFile:    flagBaseline,
+				RuleFiles:       flagRuleFiles,
+				NamespaceSelection: configvalidator.NamespaceSelection{
+					Include:       flagIncludeNamespaces,
+					Exclude:       flagExcludeNamespaces,
+					LabelSelector: flagNamespaceSelector,
+				},
+				Concurrency:      flagConcurrency,
+				NamespaceTimeout: flagNamespaceTimeout,
+			})
 			if err != nil {
 				return err
 			}
@@ -96,6 +176,8 @@ The error code of the found issue which is prefixed by 'IST' or 'KIA'. Please re
 			err = validator.Validate()
 			if err == configvalidator.ErrConfigIssuesFound {
 				os.Exit(1)
+			} else if err == configvalidator.ErrNamespacesSkipped {
+				return &exitError{code: exitCodeNamespacesSkipped, err: err}
 			}
 			return err
 		},
@@ -108,6 +190,25 @@ The error code of the found issue which is prefixed by 'IST' or 'KIA'. Please re
 		configvalidator.SeverityLevelInfo.Name,
 		fmt.Sprintf("severity level of analysis at which to display messages. Valid values: %v",
 			configvalidator.SeverityNames))
+	flags.BoolVarP(&flagOffline, "offline", "", false,
+		"validate the given yaml files by themselves without accessing the cluster")
+	flags.StringVarP(&flagOutput, "output", "o", configvalidator.OutputTable,
+		fmt.Sprintf("output format: %s or %s", configvalidator.OutputTable, configvalidator.OutputJSON))
+	flags.StringVarP(&flagSuppressionFile, "suppression-file", "", configvalidator.SuppressionFileName,
+		"file of the suppressions of the known issues")
+	flags.StringVarP(&flagBaseline, "baseline", "", "",
+		"JSON output of a previous run by \"-o json\". Only the issues not found in it are reported")
+	flags.StringSliceVarP(&flagRuleFiles, "rules", "", nil, "files of the custom rules")
+	flags.StringSliceVarP(&flagIncludeNamespaces, "include-namespaces", "", nil,
+		"glob patterns of the namespaces to validate when validating all namespaces")
+	flags.StringSliceVarP(&flagExcludeNamespaces, "exclude-namespaces", "", nil,
+		"glob patterns of the namespaces not to validate when validating all namespaces")
+	flags.StringVarP(&flagNamespaceSelector, "namespace-selector", "", "",
+		"label selector of the namespaces to validate when validating all namespaces")
+	flags.IntVarP(&flagConcurrency, "concurrency", "", 8, "number of the namespaces validated concurrently")
+	flags.DurationVarP(&flagNamespaceTimeout, "namespace-timeout", "", 2*time.Minute,
+		"timeout of validating each namespace by Kiali. The timed-out namespaces are skipped with a warning, "+
+			"recorded in the JSON output and make getmesh exit with code 5. No timeout if zero")
 
 	return cmd
 }
@@ -32,7 +32,8 @@ func newSetDefaultHubCmd(homedir string) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "default-hub",
 		Short: `Set or Show the default hub passed to "getmesh istioctl install" via "--set hub=" e.g. docker.io/istio`,
-		Long:  `Set or Show the default hub (root for Istio docker image paths) passed to "getmesh istioctl install" via "--set hub="  e.g. docker.io/istio`,
+		Long: `Set or Show the default hub (root for Istio docker image paths) passed to "getmesh istioctl install" via "--set hub="  e.g. docker.io/istio.
+This is equivalent to "getmesh config set|get|unset default-hub".`,
 		Example: `# Set the default hub to docker.io/istio
 $ getmesh default-hub --set docker.io/istio
 
@@ -87,7 +88,7 @@ func defaultHubCheckFlags(remove bool, setValue string, show bool) error {
 }
 
 func defaultHubHandleSet(homdir, setValue string) error {
-	if err := getmesh.SetDefaultHub(homdir, setValue); err != nil {
+	if err := getmesh.SetConfigValue(homdir, "default-hub", setValue); err != nil {
 		return err
 	}
 	logger.Infof("The default hub is now set to %s\n", setValue)
@@ -103,7 +104,7 @@ func defaultHubHandleShow(current string) {
 }
 
 func defaultHubHandleRemove(homdir string) error {
-	if err := getmesh.SetDefaultHub(homdir, ""); err != nil {
+	if err := getmesh.UnsetConfigValue(homdir, "default-hub"); err != nil {
 		return err
 	}
 	logger.Infof("The default hub is removed. Now Istioctl's default value is used for \"getmesh istioctl install\" command\n")
@@ -96,4 +96,5 @@ func Test_defaultHubHandleRemove(t *testing.T) {
 		require.NoError(t, defaultHubHandleRemove(home))
 	})
 	require.Contains(t, buf.String(), "The default hub is removed. Now Istioctl's default value is used for \"getmesh istioctl install\"")
+	require.Equal(t, "", getmesh.GetActiveConfig().DefaultHub)
 }
@@ -0,0 +1,73 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// exit codes other than 1 (general errors) returned by getmesh
+const (
+	// the active istioctl is not listed in the manifest, and the warning policy is "fail"
+	exitCodeDeprecatedVersion = 3
+	// the active istioctl is not the latest patch in its minor version, and the warning policy is "fail"
+	exitCodeOutdatedPatch = 4
+	// config-validate skipped some namespaces as the validation timed out
+	exitCodeNamespacesSkipped = 5
+
+	// check-upgrade found the issues in the categories given by --fail-on.
+	// When multiple categories are found, the most severe one, i.e. the largest code, is used
+	exitCodeUpgradeNearEOL     = 9
+	exitCodeUpgradePatch       = 10
+	exitCodeUpgradeMultiMinor  = 11
+	exitCodeUpgradeGatewaySkew = 12
+	exitCodeUpgradeEOL         = 13
+	exitCodeUpgradeSecurity    = 14
+
+	// check-fips found the components or the settings not compliant with FIPS
+	exitCodeFIPSNonCompliant = 20
+)
+
+// exitError makes getmesh exit with the specific code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func exitCode(err error) int {
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return 1
+}
+
+// stdinIsTerminal is a variable for test purpose
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
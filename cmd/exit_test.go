@@ -0,0 +1,32 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_exitCode(t *testing.T) {
+	require.Equal(t, 1, exitCode(errors.New("error")))
+
+	err := &exitError{code: exitCodeOutdatedPatch, err: errors.New("outdated")}
+	require.Equal(t, exitCodeOutdatedPatch, exitCode(err))
+	require.Equal(t, exitCodeOutdatedPatch, exitCode(fmt.Errorf("wrapped: %w", err)))
+	require.Equal(t, "outdated", err.Error())
+}
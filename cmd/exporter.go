@@ -0,0 +1,113 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/src/checkupgrade"
+	"github.com/tetratelabs/getmesh/src/exporter"
+	"github.com/tetratelabs/getmesh/src/manifest"
+	"github.com/tetratelabs/getmesh/src/util"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func newExporterCmd() *cobra.Command {
+	var (
+		listen   string
+		interval time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "exporter",
+		Short: "Expose the results of check-upgrade as Prometheus metrics",
+		Long: `Periodically perform the check-upgrade analysis against the cluster and expose the results as Prometheus metrics on /metrics.
+The versions are read via the Kubernetes API, and the following metrics are exposed:
+  getmesh_data_plane_proxies{version}                         number of the proxies per version
+  getmesh_control_plane_replicas{revision,version}            number of the ready istiod replicas per revision
+  getmesh_minor_version_eol_days{minor_version}               days until the end of life of the running minor version
+  getmesh_minor_version_near_eol{minor_version}               1 if the end of life is within "getmesh config set eol-warning-days"
+  getmesh_minor_version_supported{minor_version}              1 if the running minor version is listed in the manifest
+  getmesh_patch_pending{minor_version,latest}                 1 if a newer patch is available
+  getmesh_security_patch_pending{minor_version,latest}        1 if the newer patches include security fixes
+  getmesh_outdated_proxies{minor_version}                     number of the proxies not running the latest patch
+  getmesh_check_success                                       1 if the last check succeeded
+  getmesh_check_timestamp_seconds                             unix time of the last successful check`,
+		Example: `# serve the metrics on :9090 and refresh them every 5 minutes
+$ getmesh exporter --listen :9090 --interval 5m
+
+# run as a Kubernetes Deployment with the service account of the pod
+$ getmesh exporter --in-cluster
+
+# example alerting rule of Prometheus which fires when a security patch has been pending for 2 weeks
+- alert: IstioSecurityPatchPending
+  expr: max by (minor_version, latest) (getmesh_security_patch_pending) == 1
+  for: 14d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive: %s", interval)
+			}
+
+			kubeCli, err := util.GetK8sClient()
+			if err != nil {
+				return err
+			}
+
+			e := exporter.New(
+				func() (*istioversion.Version, error) { return checkupgrade.Collect(kubeCli, "") },
+				func() ([]checkupgrade.ControlPlane, error) { return checkupgrade.ControlPlanes(kubeCli) },
+				manifest.FetchManifest,
+			)
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+			go e.Run(ctx, interval)
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", e.Handler())
+			srv := &http.Server{Addr: listen, Handler: mux}
+			go func() {
+				<-ctx.Done()
+				if err := srv.Shutdown(context.Background()); err != nil {
+					logger.Warnf("failed to shut down the server: %v\n", err)
+				}
+			}()
+
+			logger.Infof("serving the metrics on %s/metrics\n", listen)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("error serving the metrics: %w", err)
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+	flags.StringVarP(&listen, "listen", "", ":9090", "Address to serve the metrics")
+	flags.DurationVarP(&interval, "interval", "", 5*time.Minute, "Interval of the checks")
+	flags.BoolVarP(&util.InCluster, "in-cluster", "", false,
+		"Use the service account of the pod instead of the kubeconfig, e.g. when running as a Kubernetes Deployment")
+	return cmd
+}
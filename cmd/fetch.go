@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/getmesh"
 	"github.com/tetratelabs/getmesh/src/istioctl"
 	"github.com/tetratelabs/getmesh/src/manifest"
 	"github.com/tetratelabs/getmesh/src/util/logger"
@@ -71,7 +72,7 @@ $ getmesh fetch
 As you can see the above examples:
 - If --flavor-versions is not given, it defaults to the latest flavor version in the list
 	If the value does not have patch version, "1.7" or "1.8" for example, then we fallback to the latest patch version in that minor version. 
-- If --flavor is not given, it defaults to "tetrate" flavor.
+- If --flavor is not given, it defaults to "tetrate" flavor, or the one set by "getmesh config set default-flavor".
 - If --versions is not given, it defaults to the latest version of "tetrate" flavor.
 
 
@@ -129,7 +130,7 @@ func fetchParams(flags *fetchFlags,
 	if flags.flavor != api.IstioDistributionFlavorTetrate &&
 		flags.flavor != api.IstioDistributionFlavorTetrateFIPS &&
 		flags.flavor != api.IstioDistributionFlavorIstio {
-		flags.flavor = api.IstioDistributionFlavorTetrate
+		flags.flavor = fetchDefaultFlavor()
 		logger.Infof("fallback to the %s flavor since --flavor flag is not given or not supported\n", flags.flavor)
 	}
 	if len(flags.version) == 0 {
@@ -195,3 +196,10 @@ func fetchParams(flags *fetchFlags,
 
 	return ret, nil
 }
+
+func fetchDefaultFlavor() string {
+	if f := getmesh.GetActiveConfig().DefaultFlavor; f != "" {
+		return f
+	}
+	return api.IstioDistributionFlavorTetrate
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/history"
+	"github.com/tetratelabs/getmesh/src/istioctl"
+	"github.com/tetratelabs/getmesh/src/util"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func newHistoryCmd(homedir string) *cobra.Command {
+	var (
+		context, since, until string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the history of istioctl invocations which mutated clusters",
+		Long: `Show the history of istioctl invocations which mutated clusters, e.g. install, upgrade, uninstall and tag set,
+executed via getmesh. The history is recorded in the append-only log located at ~/.getmesh/history.jsonl
+with the timestamp, the user, the kube context, the active distribution, the sanitized arguments, the exit code and the duration.`,
+		Example: `# show all the history
+$ getmesh history
+
+# show the history of the context in the last 24 hours
+$ getmesh history --context my-cluster --since 24h
+
+# show the history in the specific period
+$ getmesh history --since 2021-04-01 --until 2021-04-30T12:00:00Z`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := history.Filter{Context: context}
+			var err error
+			if filter.Since, err = historyParseTime(since, time.Now()); err != nil {
+				return err
+			}
+			if filter.Until, err = historyParseTime(until, time.Now()); err != nil {
+				return err
+			}
+
+			entries, err := history.Load(homedir, filter)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				logger.Infof("No history found\n")
+				return nil
+			}
+			historyPrint(entries)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+	flags.StringVarP(&context, "context", "", "", "Show only the history of the kube context")
+	flags.StringVarP(&since, "since", "", "",
+		"Show only the history after the time given as a duration relative to now (e.g. 24h), a date (e.g. 2021-04-01) or RFC3339")
+	flags.StringVarP(&until, "until", "", "", "Show only the history before the time given in the same format as --since")
+	return cmd
+}
+
+// parse the time given as a duration relative to now, a date or RFC3339
+func historyParseTime(in string, now time.Time) (time.Time, error) {
+	if in == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(in); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", in, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, in); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: must be a duration, a date in YYYY-MM-DD or RFC3339", in)
+}
+
+func historyPrint(entries []history.Entry) {
+	data := make([][]string, 0, len(entries))
+	for _, e := range entries {
+		data = append(data, []string{
+			e.Timestamp.Local().Format(time.RFC3339),
+			e.User,
+			e.Context,
+			e.Distribution,
+			strconv.Itoa(e.ExitCode),
+			e.Duration.Round(time.Millisecond).String(),
+			strings.Join(e.Args, " "),
+		})
+	}
+
+	table := tablewriter.NewWriter(logger.GetWriter())
+	table.SetHeader([]string{"TIME", "USER", "CONTEXT", "DISTRIBUTION", "EXIT CODE", "DURATION", "ARGS"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t") // pad with tabs
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+// record the istioctl invocation in the history if it mutates the cluster
+func historyRecord(homedir string, d *api.IstioDistribution, args []string, start time.Time, execErr error) {
+	parsed := istioctl.ParseArgs(args)
+	if !parsed.IsMutating() {
+		return
+	}
+
+	e := history.Entry{
+		Timestamp:    start,
+		Distribution: d.ToString(),
+		Args:         history.SanitizeArgs(parsed.Tokens),
+		Duration:     time.Since(start),
+	}
+
+	if usr, err := user.Current(); err == nil {
+		e.User = usr.Username
+	}
+
+	if cs := parsed.Values("context"); len(cs) > 0 {
+		e.Context = cs[len(cs)-1]
+	} else {
+		kubeconfig := util.GetKubeConfigLocation()
+		if ks := parsed.Values("kubeconfig"); len(ks) > 0 {
+			kubeconfig = ks[len(ks)-1]
+		}
+		e.Context, _ = util.GetKubeContext(kubeconfig) // intentionally ignore the error
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(execErr, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+	} else if execErr != nil {
+		e.ExitCode = 1
+	}
+
+	if err := history.Append(homedir, e); err != nil {
+		logger.Warnf("failed to record the history: %v\n", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/history"
+)
+
+func Test_historyParseTime(t *testing.T) {
+	now := time.Date(2021, 4, 2, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		in  string
+		exp time.Time
+	}{
+		{in: ""},
+		{in: "24h", exp: time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)},
+		{in: "2021-04-01", exp: time.Date(2021, 4, 1, 0, 0, 0, 0, time.Local)},
+		{in: "2021-04-01T10:00:00Z", exp: time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)},
+	} {
+		actual, err := historyParseTime(c.in, now)
+		require.NoError(t, err)
+		require.True(t, c.exp.Equal(actual), c.in)
+	}
+
+	_, err := historyParseTime("yesterday", now)
+	require.Error(t, err)
+}
+
+func Test_historyRecord(t *testing.T) {
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	d := &api.IstioDistribution{Version: "1.9.5", Flavor: "tetrate", FlavorVersion: 0}
+	historyRecord(home, d, []string{"version"}, time.Now(), nil)
+	historyRecord(home, d, []string{"install", "--context", "my-cluster", "--set=values.foo.password=bar"},
+		time.Now(), errors.New("error"))
+
+	entries, err := history.Load(home, history.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "my-cluster", entries[0].Context)
+	require.Equal(t, "1.9.5-tetrate-v0", entries[0].Distribution)
+	require.Equal(t, 1, entries[0].ExitCode)
+	require.Equal(t, []string{"install", "--context", "my-cluster", "--set", "values.foo.password=***"}, entries[0].Args)
+}
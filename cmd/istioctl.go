@@ -19,55 +19,136 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/tetratelabs/getmesh/api"
 	"github.com/tetratelabs/getmesh/src/getmesh"
+	"github.com/tetratelabs/getmesh/src/installdiff"
 	"github.com/tetratelabs/getmesh/src/istioctl"
 	"github.com/tetratelabs/getmesh/src/manifest"
+	"github.com/tetratelabs/getmesh/src/registry"
+	"github.com/tetratelabs/getmesh/src/uninstall"
 	"github.com/tetratelabs/getmesh/src/util"
 	"github.com/tetratelabs/getmesh/src/util/logger"
 )
 
+const (
+	istioctlShowEffectiveArgsFlag = "--show-effective-args"
+	istioctlDiffFlag              = "--diff"
+	// not "--force" which is the flag of istioctl uninstall itself
+	istioctlSkipDependentsCheckFlag = "--skip-dependents-check"
+)
+
 func newIstioCmd(homedir string) *cobra.Command {
-	var processedArgs []string
+	var (
+		processedArgs     []string
+		showEffectiveArgs bool
+		diff              bool
+		skipDependents    bool
+	)
 	return &cobra.Command{
 		Use:   "istioctl <args...>",
 		Short: "Execute istioctl with given arguments",
-		Long:  `Execute istioctl with given arguments where the version of istioctl is set by "getsitio fetch or switch"`,
+		Long: `Execute istioctl with given arguments where the version of istioctl is set by "getsitio fetch or switch".
+
+Before "istioctl install", getmesh asks for confirmation when the active istioctl is deprecated or not the latest patch.
+The prompt is skipped in the non-interactive mode, i.e. with "--non-interactive" ("--yes") flag, "getmesh config set non-interactive true" or without TTY.
+With "getmesh config set warning-policy fail", getmesh instead exits with the code 3 for the deprecated version, or 4 for the outdated patch.
+
+For "istioctl install", the defaults set by "getmesh config set default-*" (hub, tag, profile, image pull secrets, mesh config
+and IstioOperator file) are injected unless explicitly given by "--set" or in the IstioOperator files given by "-f". Pass "--show-effective-args" to print the final command without executing it.
+Pass "--diff" to render the manifest by "istioctl manifest generate" with the same arguments, and print the per-resource
+difference from the live cluster without executing the install.
+When the default hub is injected, getmesh verifies that the hub serves the pilot and proxyv2 images of the tag to be installed
+using the credentials in the docker config.json.
+
+For "istioctl uninstall" and "istioctl x uninstall", getmesh lists the namespaces still labeled for injection, the pods still
+running sidecars and the Istio resources which will be orphaned, and asks for confirmation if any of them exists.
+Only the dependents of the revision given by "--revision", or by spec.revision of the IstioOperator in "-f", are listed unless "--purge" is given.
+In the non-interactive mode, the uninstall is aborted unless "--skip-dependents-check" is given. After the uninstall, getmesh verifies
+that no istiod remains.
+
+The invocations which mutate the cluster, e.g. install, upgrade and uninstall, are recorded in the history shown by "getmesh history".`,
 		Example: `# install Istio with the default profile
 getmesh istioctl install --set profile=default
 
+# print the istioctl command including the injected defaults
+getmesh istioctl install --show-effective-args
+
+# show the difference between the manifest to be installed and the cluster
+getmesh istioctl install --set profile=demo --diff
+
 # check versions of Istio data plane, control plane, and istioctl
 getmesh istioctl version`,
 		PreRunE: func(_ *cobra.Command, args []string) error {
-			cur := getmesh.GetActiveConfig().IstioDistribution
-			if cur == nil {
+			conf := getmesh.GetActiveConfig()
+			if conf.IstioDistribution == nil {
 				return errors.New("please fetch Istioctl by `getmesh fetch` beforehand")
 			}
+			args, showEffectiveArgs = istioctlPopFlag(args, istioctlShowEffectiveArgsFlag)
+			args, diff = istioctlPopDiffFlag(args)
+			// global flags are not parsed by cobra since flag parsing is disabled for this command
+			var nonInteractive, yes bool
+			args, nonInteractive = istioctlPopFlag(args, "--"+nonInteractiveFlag)
+			args, yes = istioctlPopFlag(args, "--"+yesFlag)
+			flagNonInteractive = flagNonInteractive || nonInteractive || yes
+			args, skipDependents = istioctlPopUninstallFlags(args)
 			var err error
-			processedArgs, err = istioctlArgChecks(args, cur, getmesh.GetActiveConfig().DefaultHub)
+			processedArgs, err = istioctlArgChecks(args, conf)
 			if err != nil {
 				return err
 			}
+
+			if showEffectiveArgs || diff {
+				return nil
+			}
+
+			if t, ok := istioctlUninstallTarget(istioctl.ParseArgs(processedArgs)); ok {
+				return istioctlUninstallCheck(t, skipDependents)
+			}
+
+			if err := istioctlVerifyDefaultHub(args, conf); err != nil {
+				return err
+			}
 			// precheck inspects a Kubernetes cluster for istio
 			return istioK8scompatibilityCheck(homedir, processedArgs)
 		},
 
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if err := istioctl.Exec(homedir, processedArgs); err != nil {
+			if showEffectiveArgs {
+				logger.Infof("istioctl %s\n", strings.Join(processedArgs, " "))
+				return nil
+			} else if diff {
+				return istioctlDiff(homedir, processedArgs)
+			}
+
+			start := time.Now()
+			err := istioctl.Exec(homedir, processedArgs)
+			historyRecord(homedir, getmesh.GetActiveConfig().IstioDistribution, processedArgs, start, err)
+			if err != nil {
 				return fmt.Errorf("error executing istioctl: %v", err)
 			}
 			return nil
 		},
 
 		// verify on whether istiod and CRDs are installed correctly
-		PostRunE: func(_ *cobra.Command, args []string) error {
-			args = istioctlParseVerifyInstallArgs(args)
+		PostRunE: func(_ *cobra.Command, _ []string) error {
+			if showEffectiveArgs || diff {
+				return nil
+			}
+
+			parsed := istioctl.ParseArgs(processedArgs)
+			if t, ok := istioctlUninstallTarget(parsed); ok {
+				return istioctlUninstallVerify(parsed, t)
+			}
+
+			args := istioctlParseVerifyInstallArgs(processedArgs)
 			if len(args) > 0 {
 				if err := istioctl.Exec(homedir, args); err != nil {
 					return fmt.Errorf("error executing istioctl: %v", err)
@@ -80,63 +161,177 @@ getmesh istioctl version`,
 	}
 }
 
-func istioctlArgChecks(args []string, currentDistro *api.IstioDistribution, defaultHub string) ([]string, error) {
-	// Sanitize args.
-	out := istioctlPreProcessArgs(args)
-
-	// Walk thourgh args and search if it has 1) install command, 2) "--set hub=..." parameter.
-	var (
-		prev            string
-		hasInstallCMD   bool
-		hasHubParameter bool
-	)
-	for _, a := range out {
-		if a == "install" {
-			hasInstallCMD = true
-			m, err := manifest.FetchManifest()
-			if err != nil {
-				return nil, err
-			}
-			ok, err := currentDistro.ExistInManifest(m)
-			if err != nil {
-				return nil, err
-			} else if !ok {
-				logger.Warnf("Your active istioctl of version %s is deprecated. "+
-					"We recommend you use the supported distribution listed in \"getmesh list\" command. \n", currentDistro.ToString())
-				p := promptui.Prompt{
-					Label:     "Proceed",
-					IsConfirm: true,
-				}
-				if _, err := p.Run(); err != nil {
-					// error returned when it's not confirmed
-					return nil, err
-				}
-			}
+func istioctlArgChecks(args []string, conf getmesh.Config) ([]string, error) {
+	currentDistro := conf.IstioDistribution
+	parsed := istioctl.ParseArgs(args)
+	out := parsed.Tokens
+	if !parsed.IsCommand("install") {
+		return out, nil
+	}
+	installPos, givenSetPaths := parsed.CommandPos(), parsed.SetPaths()
 
-			err = istioctlPatchVersionCheck(currentDistro, m)
-			if err != nil {
-				return nil, err
-			}
+	m, err := manifest.FetchManifest()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := currentDistro.ExistInManifest(m)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		logger.Warnf("Your active istioctl of version %s is deprecated. "+
+			"We recommend you use the supported distribution listed in \"getmesh list\" command. \n", currentDistro.ToString())
+		if err := istioctlConfirm(exitCodeDeprecatedVersion,
+			fmt.Sprintf("the active istioctl of version %s is deprecated", currentDistro.ToString())); err != nil {
+			return nil, err
 		}
+	}
 
-		// Search "--set hub=..." args.
-		if prev == "--set" || prev == "-s" {
-			if kv := strings.SplitN(a, "=", 2); len(kv) == 2 && kv[0] == "hub" {
-				hasHubParameter = true
-			}
+	if err := istioctlPatchVersionCheck(currentDistro, m); err != nil {
+		return nil, err
+	}
+
+	// "--set" overrides "-f" in istioctl, so the paths set in the given IstioOperator files are not defaulted either.
+	givenOperators, err := istioctl.ReadOperators(parsed.Values("filename"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the IstioOperator files: %w", err)
+	}
+
+	// Insert the defaults set by "getmesh config set default-*".
+	for _, s := range istioctlInstallDefaultSets(conf) {
+		if !istioctlHasSetPath(givenSetPaths, s[0]) && !givenOperators.HasPath(s[0]) {
+			out = append(out, "--set", fmt.Sprintf("%s=%s", s[0], s[1]))
 		}
-		prev = a
 	}
 
-	// Insert the default hub set by "getmesh default-hub --set".
-	if hasInstallCMD && !hasHubParameter {
-		if defaultHub != "" {
-			out = append(out, "--set", fmt.Sprintf("hub=%s", defaultHub))
+	// Image pull secrets are a list so we inject all of them only when none of the elements is given.
+	const imagePullSecretsPath = "values.global.imagePullSecrets"
+	if !istioctlHasSetPath(givenSetPaths, imagePullSecretsPath) && !givenOperators.HasPath(imagePullSecretsPath) {
+		for i, s := range conf.DefaultImagePullSecrets {
+			out = append(out, "--set", fmt.Sprintf("%s[%d]=%s", imagePullSecretsPath, i, s))
 		}
 	}
+
+	// The default file comes right after "install" so that the explicitly given files and "--set" take precedence.
+	if f := conf.DefaultInstallFile; f != "" {
+		out = append(out[:installPos+1], append([]string{"-f", f}, out[installPos+1:]...)...)
+	}
 	return out, nil
 }
 
+// determine the hub and the tag to be verified when the default hub is injected into "istioctl install"
+func istioctlDefaultHubVerificationTarget(args []string, conf getmesh.Config) (hub, tag string, ok bool) {
+	if conf.DefaultHub == "" || conf.SkipHubVerification || conf.IstioDistribution == nil {
+		return "", "", false
+	}
+
+	parsed := istioctl.ParseArgs(args)
+	givenSetPaths := parsed.SetPaths()
+	if !parsed.IsCommand("install") || istioctlHasSetPath(givenSetPaths, "hub") {
+		return "", "", false
+	}
+
+	// the unreadable files are reported by istioctlArgChecks
+	givenOperators, _ := istioctl.ReadOperators(parsed.Values("filename"))
+	if givenOperators.HasPath("hub") {
+		return "", "", false
+	}
+
+	tag = givenSetPaths["tag"]
+	if tag == "" {
+		tag = givenOperators.StringValue("tag")
+	}
+	if tag == "" {
+		tag = conf.DefaultTag
+	}
+	if tag == "" {
+		tag = conf.IstioDistribution.ImageTag()
+	}
+	return conf.DefaultHub, tag, true
+}
+
+// verify that the default hub serves the images required by "istioctl install"
+// so that typos do not result in ImagePullBackOff in the middle of the installation
+func istioctlVerifyDefaultHub(args []string, conf getmesh.Config) error {
+	hub, tag, ok := istioctlDefaultHubVerificationTarget(args, conf)
+	if !ok {
+		return nil
+	}
+
+	c, err := registry.NewClient()
+	if err != nil {
+		return fmt.Errorf("error creating registry client: %w", err)
+	}
+
+	if err := c.VerifyHub(hub, tag, registry.RequiredIstioImages); err != nil {
+		return fmt.Errorf("the default hub %s does not serve the images required by \"istioctl install\": %w. "+
+			"Please fix the hub by \"getmesh config set default-hub\", "+
+			"or skip this check by \"getmesh config set skip-hub-verification true\"", hub, err)
+	}
+	return nil
+}
+
+// construct the (path, value) pairs of the scalar defaults passed via "--set" in "istioctl install"
+func istioctlInstallDefaultSets(conf getmesh.Config) [][2]string {
+	var ret [][2]string
+	if conf.DefaultHub != "" {
+		ret = append(ret, [2]string{"hub", conf.DefaultHub})
+	}
+	if conf.DefaultTag != "" {
+		ret = append(ret, [2]string{"tag", conf.DefaultTag})
+	}
+	if conf.DefaultProfile != "" {
+		ret = append(ret, [2]string{"profile", conf.DefaultProfile})
+	}
+
+	for _, kv := range conf.DefaultMeshConfig {
+		if ps := strings.SplitN(kv, "=", 2); len(ps) == 2 {
+			ret = append(ret, [2]string{"meshConfig." + ps[0], ps[1]})
+		}
+	}
+	return ret
+}
+
+// check if the path, any of its parents, or any of its children or list elements, is given by "--set"
+func istioctlHasSetPath(given map[string]string, path string) bool {
+	for g := range given {
+		if g == path || strings.HasPrefix(path, g+".") ||
+			strings.HasPrefix(g, path+".") || strings.HasPrefix(g, path+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// remove the getmesh specific flag from args which are otherwise passed to istioctl as-is
+func istioctlPopFlag(args []string, flag string) ([]string, bool) {
+	var found bool
+	ret := make([]string, 0, len(args))
+	for _, a := range args {
+		if a == flag {
+			found = true
+			continue
+		}
+		ret = append(ret, a)
+	}
+	return ret, found
+}
+
+// pop the getmesh's own flag of uninstall, while the other flags including "--force" are passed to istioctl as is
+func istioctlPopUninstallFlags(args []string) ([]string, bool) {
+	if _, ok := istioctlUninstallTarget(istioctl.ParseArgs(args)); !ok {
+		return args, false
+	}
+	return istioctlPopFlag(args, istioctlSkipDependentsCheckFlag)
+}
+
+// pop the getmesh's own "--diff" of install, while the other commands receive it as is
+func istioctlPopDiffFlag(args []string) ([]string, bool) {
+	if !istioctl.ParseArgs(args).IsCommand("install") {
+		return args, false
+	}
+	return istioctlPopFlag(args, istioctlDiffFlag)
+}
+
 // check on whether the current version is the latest patch given current group version
 func istioctlPatchVersionCheck(current *api.IstioDistribution, ms *api.Manifest) error {
 	latestPatch, _, err := api.GetLatestDistribution(current, ms)
@@ -156,31 +351,138 @@ func istioctlPatchVersionCheck(current *api.IstioDistribution, ms *api.Manifest)
 		logger.Warnf("your current patch version %s is not the latest version %s. "+
 			"We recommend you fetch the latest version through \"getmesh fetch\" command, "+
 			"and switch to the latest version through \"getmesh switch\" command \n", current.Version, latestPatch.Version)
-
-		p := promptui.Prompt{
-			Label:     "Proceed",
-			IsConfirm: true,
-		}
-		if _, err := p.Run(); err != nil {
-			// error returned when it's not confirmed
+		if err := istioctlConfirm(exitCodeOutdatedPatch,
+			fmt.Sprintf("the active patch version %s is not the latest version %s", current.Version, latestPatch.Version)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func istioctlParsePreCheckArgs(args []string) []string {
-	for _, arg := range args {
-		if arg == "--help" || arg == "-h" {
-			return nil
-		}
+// ask users to proceed on the warning. Depending on the settings, this instead
+// - fails with the given exit code if the warning policy is "fail"
+// - proceeds without prompts in the non-interactive mode, which is enabled by the flag, the setting, or the absence of TTY
+func istioctlConfirm(code int, reason string) error {
+	conf := getmesh.GetActiveConfig()
+	if conf.WarningPolicy == getmesh.WarningPolicyFail {
+		return &exitError{code: code, err: fmt.Errorf("aborted by the warning policy %q: %s",
+			getmesh.WarningPolicyFail, reason)}
 	}
-	argument := []string{"x", "precheck", "--kubeconfig", util.GetKubeConfigLocation()}
-	hasInstall := istioctlProcessChecksArgs(args, &argument, true)
-	if !hasInstall {
+
+	if istioctlNonInteractive() {
+		logger.Infof("Proceeding without confirmation in the non-interactive mode\n")
 		return nil
 	}
-	return argument
+
+	p := promptui.Prompt{
+		Label:     "Proceed",
+		IsConfirm: true,
+	}
+	// error returned when it's not confirmed
+	_, err := p.Run()
+	return err
+}
+
+func istioctlNonInteractive() bool {
+	return flagNonInteractive || getmesh.GetActiveConfig().NonInteractive || !stdinIsTerminal()
+}
+
+// the target of "istioctl uninstall" or "istioctl x uninstall". ok is false for the other commands
+func istioctlUninstallTarget(parsed *istioctl.Args) (t uninstall.Target, ok bool) {
+	if !parsed.IsCommand("uninstall") && !parsed.IsCommand("x", "uninstall") {
+		return t, false
+	} else if parsed.Has("help") || parsed.Has("dry-run") {
+		return t, false
+	}
+
+	if rs := parsed.Values("revision"); len(rs) > 0 {
+		t.Revision = rs[len(rs)-1]
+	} else if fs := parsed.Values("filename"); len(fs) > 0 {
+		t.Revision = istioctlOperatorsRevision(fs)
+	}
+	t.Purge = parsed.Has("purge")
+	return t, true
+}
+
+// the revision uninstalled by "-f", which is the default one unless the IstioOperator sets spec.revision.
+// Empty, i.e. all the revisions, is returned if the files cannot be read so that every dependent is checked
+func istioctlOperatorsRevision(files []string) string {
+	ops, err := istioctl.ReadOperators(files)
+	if err != nil {
+		return ""
+	}
+	if r := ops.StringValue("revision"); r != "" {
+		return r
+	}
+	return "default"
+}
+
+// list the resources still depending on the control plane to be uninstalled, and require the confirmation
+func istioctlUninstallCheck(t uninstall.Target, skipDependents bool) error {
+	config, err := util.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	kubeCli, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to generate k8s client: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to generate k8s dynamic client: %w", err)
+	}
+
+	i := &uninstall.Inspector{KubeCli: kubeCli, Dynamic: dyn, Discovery: kubeCli.Discovery()}
+	r, err := i.Inspect(t)
+	if err != nil {
+		return err
+	} else if r.Empty() {
+		return nil
+	}
+
+	logger.Warnf("The following resources still depend on the control plane to be uninstalled:\n")
+	r.Print(logger.GetWriter())
+	if skipDependents {
+		logger.Infof("Proceeding because %s is given\n", istioctlSkipDependentsCheckFlag)
+		return nil
+	} else if istioctlNonInteractive() {
+		return fmt.Errorf("aborted the uninstall in the non-interactive mode. Pass %s to uninstall anyway", istioctlSkipDependentsCheckFlag)
+	}
+
+	p := promptui.Prompt{
+		Label:     "Proceed",
+		IsConfirm: true,
+	}
+	// error returned when it's not confirmed
+	_, err = p.Run()
+	return err
+}
+
+// verify that no istiod of the target remains after the uninstall
+func istioctlUninstallVerify(parsed *istioctl.Args, t uninstall.Target) error {
+	ns := "istio-system"
+	if vs := parsed.Values("istioNamespace"); len(vs) > 0 {
+		ns = vs[len(vs)-1]
+	}
+
+	kubeCli, err := util.GetK8sClient()
+	if err != nil {
+		return err
+	}
+	if err := uninstall.WaitIstiodRemoved(kubeCli, ns, t, 2*time.Minute, 2*time.Second); err != nil {
+		return err
+	}
+	logger.Infof("Verified that no istiod remains in %s\n", ns)
+	return nil
+}
+
+func istioctlParsePreCheckArgs(args []string) []string {
+	parsed := istioctl.ParseArgs(args)
+	if !parsed.IsCommand("install") || parsed.Has("help") {
+		return nil
+	}
+	return append([]string{"x", "precheck", "--kubeconfig", util.GetKubeConfigLocation()},
+		istioctlChecksFlags(parsed, true)...)
 }
 
 func istioK8scompatibilityCheck(homedir string, args []string) error {
@@ -228,69 +530,69 @@ func istioctlHasPreCheckCommand(homedir string) bool {
 }
 
 func istioctlParseVerifyInstallArgs(args []string) []string {
-	for _, arg := range args {
-		if arg == "--help" || arg == "-h" {
-			return nil
-		}
-	}
-	argument := []string{"verify-install", "--kubeconfig", util.GetKubeConfigLocation()}
-	hasInstallCMD := istioctlProcessChecksArgs(args, &argument, false)
-	if !hasInstallCMD {
+	parsed := istioctl.ParseArgs(args)
+	if !parsed.IsCommand("install") || parsed.Has("help") {
 		return nil
 	}
-	return argument
+	return append([]string{"verify-install", "--kubeconfig", util.GetKubeConfigLocation()},
+		istioctlChecksFlags(parsed, false)...)
 }
 
-// preprocess corner cases in handcrafted install command parsing
-func istioctlPreProcessArgs(args []string) []string {
-	ret := []string{}
-	// match string in format like --manifests=testfile -f=a --set=profile=demo -s=profile=demo
-	// and supports to match with dir/ or values.value
-	valid := regexp.MustCompile(`^((((\-\-|\-)([\w./]+))(=)([\w./]+))|(((\-\-set=)|(\-s=))([\w./]+=)([\w./\-]+)))$`)
-	var prev string
-	for _, arg := range args {
-		if prev != "--set" && prev != "-s" && valid.MatchString(arg) {
-			splited := strings.SplitN(arg, "=", 2)
-			if len(splited) != 2 {
-				continue
-			}
-			ret = append(ret, strings.TrimSpace(splited[0]), strings.TrimSpace(splited[1]))
-		} else {
-			ret = append(ret, strings.TrimSpace(arg))
-		}
-		prev = arg
+// the flags of "istioctl install" which "istioctl manifest generate" does not accept
+var istioctlInstallOnlyFlags = []string{"skip-confirmation", "verify", "readiness-timeout", "dry-run"}
+
+// render the manifest of the install command, and print the difference from the live objects in the cluster
+func istioctlDiff(homedir string, args []string) error {
+	parsed := istioctl.ParseArgs(args)
+	if !parsed.IsCommand("install") {
+		return fmt.Errorf("%s is only supported for \"istioctl install\"", istioctlDiffFlag)
 	}
-	return ret
+
+	w, errW := new(bytes.Buffer), new(bytes.Buffer)
+	genArgs := parsed.Rewrite([]string{"manifest", "generate"}, istioctlInstallOnlyFlags...)
+	if err := istioctl.ExecWithWriters(homedir, genArgs, w, errW); err != nil {
+		return fmt.Errorf("error executing istioctl: %v, %s", err, errW.String())
+	}
+
+	desired, err := installdiff.ParseManifest(w.Bytes())
+	if err != nil {
+		return err
+	}
+
+	config, err := util.GetK8sConfig()
+	if err != nil {
+		return err
+	}
+	get, err := installdiff.NewClusterGetter(config)
+	if err != nil {
+		return err
+	}
+
+	diffs, err := installdiff.Diff(desired, get)
+	if err != nil {
+		return err
+	}
+	installdiff.Print(logger.GetWriter(), diffs)
+	return nil
 }
 
-// parse checks will parse install command line into precheck commands or verify install commands
-// argument is the command pass to precheck and verify-install command and is passed by reference
-func istioctlProcessChecksArgs(args []string, ourArg *[]string, precheck bool) bool {
-	hasInstallCMD := false
-	var prev string
-	args = istioctlPreProcessArgs(args)
-	for _, a := range args {
-		if a == "install" {
-			hasInstallCMD = true
-		}
-		switch prev {
-		case "-f", "--filename", "--revision", "-r":
-			*ourArg = append(*ourArg, prev, a)
-		case "--set", "-s":
-			kv := strings.SplitN(a, "=", 2)
-			if len(kv) != 2 || strings.TrimSpace(kv[0]) != "values.global.istioNamespace" {
-				prev = a
-				continue
+// derive the flags of precheck or verify-install commands from the install command
+func istioctlChecksFlags(parsed *istioctl.Args, precheck bool) []string {
+	var ret []string
+	for _, f := range parsed.Flags {
+		switch f.Name {
+		case "filename", "revision":
+			ret = append(ret, f.Given, f.Value)
+		case "set":
+			kv := strings.SplitN(f.Value, "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "values.global.istioNamespace" {
+				ret = append(ret, "--istioNamespace", kv[1])
 			}
-			*ourArg = append(*ourArg, "--istioNamespace", kv[1])
-		case "--manifests", "-d":
+		case "manifests":
 			if !precheck {
-				*ourArg = append(*ourArg, prev, a)
+				ret = append(ret, f.Given, f.Value)
 			}
-		default:
-			// default do nothing
 		}
-		prev = a
 	}
-	return hasInstallCMD
+	return ret
 }
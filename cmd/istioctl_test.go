@@ -18,12 +18,16 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/getmesh"
+	"github.com/tetratelabs/getmesh/src/istioctl"
 	"github.com/tetratelabs/getmesh/src/manifest"
+	"github.com/tetratelabs/getmesh/src/uninstall"
 	"github.com/tetratelabs/getmesh/src/util"
 	"github.com/tetratelabs/getmesh/src/util/logger"
 )
@@ -67,38 +71,114 @@ func TestIstioctl_istioctlArgChecks(t *testing.T) {
 	}()
 
 	t.Run("ok", func(t *testing.T) {
-		out, err := istioctlArgChecks([]string{"analyze"}, nil, "")
+		out, err := istioctlArgChecks([]string{"analyze"}, getmesh.Config{})
 		require.NoError(t, err)
 		require.Equal(t, []string{"analyze"}, out)
 
 		// Default hub is given but should not affect commands other than "install".
-		out, err = istioctlArgChecks([]string{"analyze"}, nil, "gcr.io/istio")
+		out, err = istioctlArgChecks([]string{"analyze"}, getmesh.Config{DefaultHub: "gcr.io/istio"})
 		require.NoError(t, err)
 		require.Equal(t, []string{"analyze"}, out)
 
-		out, err = istioctlArgChecks([]string{"install"}, m.IstioDistributions[0], "")
+		out, err = istioctlArgChecks([]string{"install"}, getmesh.Config{IstioDistribution: m.IstioDistributions[0]})
 		require.NoError(t, err)
 		require.Equal(t, []string{"install"}, out)
 
 		// Default hub is given and should be set to output args.
-		out, err = istioctlArgChecks([]string{"install"}, m.IstioDistributions[0], "gcr.io/istio")
+		out, err = istioctlArgChecks([]string{"install"}, getmesh.Config{IstioDistribution: m.IstioDistributions[0], DefaultHub: "gcr.io/istio"})
 		require.NoError(t, err)
 		require.Equal(t, []string{"install", "--set", "hub=gcr.io/istio"}, out)
 
 		// Default hub is given but it should not affect the explicitly given hub arg
-		out, err = istioctlArgChecks([]string{"install", "--set=hub=my-space.com/istio"}, m.IstioDistributions[0], "gcr.io/istio")
+		out, err = istioctlArgChecks([]string{"install", "--set=hub=my-space.com/istio"}, getmesh.Config{IstioDistribution: m.IstioDistributions[0], DefaultHub: "gcr.io/istio"})
 		require.NoError(t, err)
 		require.Equal(t, []string{"install", "--set", "hub=my-space.com/istio"}, out)
 	})
 
+	t.Run("install defaults", func(t *testing.T) {
+		conf := getmesh.Config{
+			IstioDistribution:       m.IstioDistributions[0],
+			DefaultHub:              "gcr.io/istio",
+			DefaultTag:              "1.7.6-tetrate-v0",
+			DefaultProfile:          "minimal",
+			DefaultInstallFile:      "/etc/getmesh/operator.yaml",
+			DefaultImagePullSecrets: []string{"secret-a", "secret-b"},
+			DefaultMeshConfig:       []string{"accessLogFile=/dev/stdout", "enableTracing=true"},
+		}
+
+		// Defaults should not affect commands other than "install".
+		out, err := istioctlArgChecks([]string{"analyze"}, conf)
+		require.NoError(t, err)
+		require.Equal(t, []string{"analyze"}, out)
+
+		out, err = istioctlArgChecks([]string{"install"}, conf)
+		require.NoError(t, err)
+		require.Equal(t, []string{"install", "-f", "/etc/getmesh/operator.yaml",
+			"--set", "hub=gcr.io/istio",
+			"--set", "tag=1.7.6-tetrate-v0",
+			"--set", "profile=minimal",
+			"--set", "meshConfig.accessLogFile=/dev/stdout",
+			"--set", "meshConfig.enableTracing=true",
+			"--set", "values.global.imagePullSecrets[0]=secret-a",
+			"--set", "values.global.imagePullSecrets[1]=secret-b",
+		}, out)
+
+		// The values set in the given IstioOperator files take precedence, since "--set" would override them.
+		my, err := ioutil.TempFile("", "*.yaml")
+		require.NoError(t, err)
+		defer os.Remove(my.Name())
+		_, err = my.WriteString(`apiVersion: install.istio.io/v1alpha1
+kind: IstioOperator
+spec:
+  hub: my-registry.io/istio
+  meshConfig:
+    enableTracing: false
+  values:
+    global:
+      imagePullSecrets: [mine]
+`)
+		require.NoError(t, err)
+
+		out, err = istioctlArgChecks([]string{"install", "-f", my.Name()}, conf)
+		require.NoError(t, err)
+		require.Equal(t, []string{"install", "-f", "/etc/getmesh/operator.yaml", "-f", my.Name(),
+			"--set", "tag=1.7.6-tetrate-v0",
+			"--set", "profile=minimal",
+			"--set", "meshConfig.accessLogFile=/dev/stdout",
+		}, out)
+
+		_, err = istioctlArgChecks([]string{"install", "-f", "not-exist.yaml"}, conf)
+		require.Error(t, err)
+
+		// Explicitly given values take precedence.
+		out, err = istioctlArgChecks([]string{"install", "--set=profile=demo", "-s", "tag=latest",
+			"--set", "meshConfig.enableTracing=false", "--set", "values.global.imagePullSecrets[0]=mine"}, conf)
+		require.NoError(t, err)
+		require.Equal(t, []string{"install", "-f", "/etc/getmesh/operator.yaml",
+			"--set", "profile=demo", "-s", "tag=latest",
+			"--set", "meshConfig.enableTracing=false", "--set", "values.global.imagePullSecrets[0]=mine",
+			"--set", "hub=gcr.io/istio",
+			"--set", "meshConfig.accessLogFile=/dev/stdout",
+		}, out)
+
+		// The explicitly given parent overrides the defaults
+		out, err = istioctlArgChecks([]string{"install", "--set", "meshConfig={}"}, getmesh.Config{
+			IstioDistribution: m.IstioDistributions[0],
+			DefaultMeshConfig: []string{"accessLogFile=/dev/stdout"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"install", "--set", "meshConfig={}"}, out)
+	})
+
 	t.Run("warning", func(t *testing.T) {
+		defer istioctlTestStubTerminal(true)()
 		buf := logger.ExecuteWithLock(func() {
 			// confirmation failed so error must be returned
-			_, err := istioctlArgChecks([]string{"install"}, &api.IstioDistribution{
+			_, err := istioctlArgChecks([]string{"install"}, getmesh.Config{IstioDistribution: &api.IstioDistribution{
 				Version:       "1.7.4",
 				Flavor:        api.IstioDistributionFlavorTetrateFIPS,
 				FlavorVersion: 0,
-			}, "")
+			}})
 			require.Error(t, err)
 		})
 
@@ -167,6 +247,23 @@ func TestIstioctl_istioctlParsePreCheckArgs(t *testing.T) {
 			exp: []string{"x", "precheck", "--kubeconfig", util.GetKubeConfigLocation(),
 				"--istioNamespace", "default", "--revision", "canary", "-f", "a", "--filename", "b"},
 		},
+		{
+			name: "eq with dashes",
+			args: []string{"install", "--revision=canary", "-f=dir/my-operator.yaml", "-r=1-9-5",
+				"--set=values.global.istioNamespace=istio-system-2"},
+			exp: []string{"x", "precheck", "--kubeconfig", util.GetKubeConfigLocation(),
+				"--revision", "canary", "-f", "dir/my-operator.yaml", "-r", "1-9-5", "--istioNamespace", "istio-system-2"},
+		},
+		{
+			name: "alias",
+			args: []string{"apply", "-y", "-fmy.yaml"},
+			exp:  []string{"x", "precheck", "--kubeconfig", util.GetKubeConfigLocation(), "-f", "my.yaml"},
+		},
+		{
+			name: "install as flag value",
+			args: []string{"manifest", "generate", "-f", "install"},
+			exp:  nil,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -235,6 +332,17 @@ func TestIstioctl_istioctlParseVerifyInstallArgs(t *testing.T) {
 			exp: []string{"verify-install", "--kubeconfig", util.GetKubeConfigLocation(),
 				"--istioNamespace", "default", "--revision", "canary", "-f", "a", "--filename", "b", "--manifests", "test/"},
 		},
+		{
+			name: "manifest install",
+			args: []string{"manifest", "install", "--manifests", "test/"},
+			exp:  nil,
+		},
+		{
+			name: "eq with dashes",
+			args: []string{"install", "--manifests=my-manifests/", "--revision=canary", "--filename=dir/my-operator.yaml"},
+			exp: []string{"verify-install", "--kubeconfig", util.GetKubeConfigLocation(), "--manifests", "my-manifests/",
+				"--revision", "canary", "--filename", "dir/my-operator.yaml"},
+		},
 		{
 			name: "full 2",
 			args: []string{"--set", "values.global.istioNamespace=default",
@@ -271,6 +379,7 @@ func TestIstioctl_istioctPatchVersionCheck(t *testing.T) {
 			Flavor:        api.IstioDistributionFlavorTetrate,
 			FlavorVersion: 0,
 		}
+		defer istioctlTestStubTerminal(true)()
 		buf := logger.ExecuteWithLock(func() {
 			// confirmation failed so error must be returned
 			require.Error(t, istioctlPatchVersionCheck(current, m))
@@ -308,77 +417,190 @@ func TestIstioctl_istioctPatchVersionCheck(t *testing.T) {
 	})
 }
 
-func TestIstioctl_istioctlPreProcessArgs(t *testing.T) {
-	tests := []struct {
-		name  string
-		args  []string
-		wants []string
+func TestIstioctl_istioctlPopFlag(t *testing.T) {
+	args, ok := istioctlPopFlag([]string{"install", "--show-effective-args", "-f", "a"}, istioctlShowEffectiveArgsFlag)
+	require.True(t, ok)
+	require.Equal(t, []string{"install", "-f", "a"}, args)
+
+	args, ok = istioctlPopFlag([]string{"install", "-f", "a"}, istioctlShowEffectiveArgsFlag)
+	require.False(t, ok)
+	require.Equal(t, []string{"install", "-f", "a"}, args)
+}
+
+func TestIstioctl_istioctlPopUninstallFlags(t *testing.T) {
+	for _, c := range []struct {
+		args, exp []string
+		skip      bool
 	}{
-		{
-			name:  "double dash",
-			args:  []string{"--manifests=testfile"},
-			wants: []string{"--manifests", "testfile"},
-		},
-		{
-			name:  "single dash",
-			args:  []string{"-d=testfile"},
-			wants: []string{"-d", "testfile"},
-		},
-		{
-			name:  "with --set",
-			args:  []string{"--set", "profile=demo"},
-			wants: []string{"--set", "profile=demo"},
-		},
-		{
-			name:  "complex --set",
-			args:  []string{"--set=profile=demo"},
-			wants: []string{"--set", "profile=demo"},
-		},
-		{
-			name:  "complex --set2",
-			args:  []string{"--set=hub=docker-hub.io/istio"},
-			wants: []string{"--set", "hub=docker-hub.io/istio"},
-		},
-		{
-			name:  "with -s",
-			args:  []string{"-s", "profile=demo"},
-			wants: []string{"-s", "profile=demo"},
-		},
-		{
-			name:  "complex -s",
-			args:  []string{"-s=profile=demo"},
-			wants: []string{"-s", "profile=demo"},
-		},
-		{
-			name:  "with dot",
-			args:  []string{"-s=values.option1=true"},
-			wants: []string{"-s", "values.option1=true"},
-		},
-		{
-			name:  "with directory",
-			args:  []string{"-d=dir1/"},
-			wants: []string{"-d", "dir1/"},
-		},
-		{
-			name:  "integrate tests 1",
-			args:  []string{"--set", "profile=demo", "--skip-confirmation", "--manifests=testfile"},
-			wants: []string{"--set", "profile=demo", "--skip-confirmation", "--manifests", "testfile"},
-		},
-		{
-			name:  "integrate tests 2",
-			args:  []string{"--set", "profile=demo", "--skip-confirmation", "--manifests=testfile/"},
-			wants: []string{"--set", "profile=demo", "--skip-confirmation", "--manifests", "testfile/"},
-		},
-		{
-			name:  "integrate tests 3",
-			args:  []string{"--set=profile=demo", "--skip-confirmation", "--manifests=testfile/", "--set=values.test.foo=bar"},
-			wants: []string{"--set", "profile=demo", "--skip-confirmation", "--manifests", "testfile/", "--set", "values.test.foo=bar"},
-		},
+		// "--force" belongs to istioctl
+		{args: []string{"x", "uninstall", "--force", "--revision", "canary"}, exp: []string{"x", "uninstall", "--force", "--revision", "canary"}},
+		{args: []string{"x", "uninstall", "--purge", "--skip-dependents-check", "--force"}, exp: []string{"x", "uninstall", "--purge", "--force"}, skip: true},
+		{args: []string{"version", "--skip-dependents-check"}, exp: []string{"version", "--skip-dependents-check"}},
+	} {
+		args, skip := istioctlPopUninstallFlags(c.args)
+		require.Equal(t, c.skip, skip, c.args)
+		processed, err := istioctlArgChecks(args, getmesh.Config{})
+		require.NoError(t, err)
+		require.Equal(t, c.exp, processed, c.args)
+	}
+}
+
+func TestIstioctl_istioctlPopDiffFlag(t *testing.T) {
+	for _, c := range []struct {
+		args, exp []string
+		diff      bool
+	}{
+		{args: []string{"install", "--diff", "--set", "profile=demo"}, exp: []string{"install", "--set", "profile=demo"}, diff: true},
+		{args: []string{"install", "--set", "profile=demo"}, exp: []string{"install", "--set", "profile=demo"}},
+		// passed through to the other commands
+		{args: []string{"x", "precheck", "--diff"}, exp: []string{"x", "precheck", "--diff"}},
+		{args: []string{"manifest", "generate", "--diff"}, exp: []string{"manifest", "generate", "--diff"}},
+	} {
+		args, diff := istioctlPopDiffFlag(c.args)
+		require.Equal(t, c.diff, diff, c.args)
+		require.Equal(t, c.exp, args, c.args)
 	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			actual := istioctlPreProcessArgs(test.args)
-			require.Equal(t, test.wants, actual)
+}
+
+func TestIstioctl_istioctlDefaultHubVerificationTarget(t *testing.T) {
+	d := &api.IstioDistribution{Version: "1.9.5", Flavor: api.IstioDistributionFlavorTetrate, FlavorVersion: 0}
+	conf := getmesh.Config{IstioDistribution: d, DefaultHub: "gcr.io/istio"}
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	hubFile, tagFile := filepath.Join(dir, "hub.yaml"), filepath.Join(dir, "tag.yaml")
+	require.NoError(t, ioutil.WriteFile(hubFile, []byte("kind: IstioOperator\nspec:\n  hub: docker.io/istio\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(tagFile, []byte("kind: IstioOperator\nspec:\n  tag: 1.9.3-tetrate-v0\n"), 0644))
+
+	for _, c := range []struct {
+		name     string
+		args     []string
+		conf     getmesh.Config
+		hub, tag string
+		ok       bool
+	}{
+		{name: "not install", args: []string{"analyze"}, conf: conf},
+		{name: "no default hub", args: []string{"install"}, conf: getmesh.Config{IstioDistribution: d}},
+		{name: "skipped", args: []string{"install"},
+			conf: getmesh.Config{IstioDistribution: d, DefaultHub: "gcr.io/istio", SkipHubVerification: true}},
+		{name: "hub given", args: []string{"install", "--set=hub=docker.io/istio"}, conf: conf},
+		{name: "distribution tag", args: []string{"install"}, conf: conf, hub: "gcr.io/istio", tag: "1.9.5-tetrate-v0", ok: true},
+		{name: "default tag", args: []string{"install"},
+			conf: getmesh.Config{IstioDistribution: d, DefaultHub: "gcr.io/istio", DefaultTag: "1.9.5-custom"},
+			hub:  "gcr.io/istio", tag: "1.9.5-custom", ok: true},
+		{name: "tag given", args: []string{"install", "-s", "tag=1.9.4-tetrate-v0"}, conf: conf,
+			hub: "gcr.io/istio", tag: "1.9.4-tetrate-v0", ok: true},
+		{name: "hub in file", args: []string{"install", "-f", hubFile}, conf: conf},
+		{name: "tag in file", args: []string{"install", "-f", tagFile}, conf: conf,
+			hub: "gcr.io/istio", tag: "1.9.3-tetrate-v0", ok: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			hub, tag, ok := istioctlDefaultHubVerificationTarget(c.args, c.conf)
+			require.Equal(t, c.ok, ok)
+			require.Equal(t, c.hub, hub)
+			require.Equal(t, c.tag, tag)
 		})
 	}
 }
+
+// stub the TTY detection and return the function to restore it
+func istioctlTestStubTerminal(isTerminal bool) func() {
+	orig := stdinIsTerminal
+	stdinIsTerminal = func() bool { return isTerminal }
+	return func() { stdinIsTerminal = orig }
+}
+
+func TestIstioctl_istioctlConfirm(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	require.NoError(t, getmesh.InitConfig(home))
+
+	t.Run("no tty", func(t *testing.T) {
+		defer istioctlTestStubTerminal(false)()
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, istioctlConfirm(exitCodeOutdatedPatch, "reason"))
+		})
+		require.Contains(t, buf.String(), "Proceeding without confirmation in the non-interactive mode")
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		defer istioctlTestStubTerminal(true)()
+		flagNonInteractive = true
+		defer func() { flagNonInteractive = false }()
+		logger.ExecuteWithLock(func() {
+			require.NoError(t, istioctlConfirm(exitCodeOutdatedPatch, "reason"))
+		})
+	})
+
+	t.Run("config", func(t *testing.T) {
+		defer istioctlTestStubTerminal(true)()
+		require.NoError(t, getmesh.SetConfigValue(home, "non-interactive", "true"))
+		defer func() { require.NoError(t, getmesh.UnsetConfigValue(home, "non-interactive")) }()
+		logger.ExecuteWithLock(func() {
+			require.NoError(t, istioctlConfirm(exitCodeOutdatedPatch, "reason"))
+		})
+	})
+
+	t.Run("fail policy", func(t *testing.T) {
+		defer istioctlTestStubTerminal(false)()
+		require.NoError(t, getmesh.SetConfigValue(home, "warning-policy", "fail"))
+		defer func() { require.NoError(t, getmesh.UnsetConfigValue(home, "warning-policy")) }()
+
+		for _, code := range []int{exitCodeDeprecatedVersion, exitCodeOutdatedPatch} {
+			err := istioctlConfirm(code, "reason")
+			require.Error(t, err)
+			require.Equal(t, code, exitCode(err))
+			require.Contains(t, err.Error(), "reason")
+		}
+	})
+}
+
+func TestIstioctl_istioctlDiff(t *testing.T) {
+	err := istioctlDiff("", []string{"analyze", "--diff"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "only supported for \"istioctl install\"")
+}
+
+func TestIstioctl_istioctlUninstallTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	revisionFile, defaultFile := filepath.Join(dir, "canary.yaml"), filepath.Join(dir, "default.yaml")
+	require.NoError(t, ioutil.WriteFile(revisionFile, []byte(`apiVersion: install.istio.io/v1alpha1
+kind: IstioOperator
+spec:
+  revision: canary
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(defaultFile, []byte(`apiVersion: install.istio.io/v1alpha1
+kind: IstioOperator
+spec:
+  profile: default
+`), 0644))
+
+	for _, c := range []struct {
+		args []string
+		exp  uninstall.Target
+		ok   bool
+	}{
+		{args: []string{"x", "uninstall", "--purge"}, exp: uninstall.Target{Purge: true}, ok: true},
+		{args: []string{"uninstall", "--revision=canary", "-y"}, exp: uninstall.Target{Revision: "canary"}, ok: true},
+		{args: []string{"experimental", "uninstall", "-r", "1-9-5"}, exp: uninstall.Target{Revision: "1-9-5"}, ok: true},
+		{args: []string{"x", "uninstall", "-f", revisionFile}, exp: uninstall.Target{Revision: "canary"}, ok: true},
+		{args: []string{"x", "uninstall", "-f", defaultFile}, exp: uninstall.Target{Revision: "default"}, ok: true},
+		{args: []string{"x", "uninstall", "-f", revisionFile, "--revision", "stable"}, exp: uninstall.Target{Revision: "stable"}, ok: true},
+		// every revision is checked if the file cannot be read
+		{args: []string{"x", "uninstall", "-f", filepath.Join(dir, "not-found.yaml")}, exp: uninstall.Target{}, ok: true},
+		{args: []string{"uninstall", "--help"}},
+		{args: []string{"x", "uninstall", "--dry-run"}},
+		{args: []string{"install"}},
+	} {
+		actual, ok := istioctlUninstallTarget(istioctl.ParseArgs(c.args))
+		require.Equal(t, c.ok, ok, c.args)
+		require.Equal(t, c.exp, actual, c.args)
+	}
+}
@@ -24,12 +24,20 @@ import (
 	"github.com/tetratelabs/getmesh/src/util"
 )
 
+const (
+	nonInteractiveFlag = "non-interactive"
+	yesFlag            = "yes"
+)
+
+// set by --non-interactive or --yes flags
+var flagNonInteractive bool
+
 func Execute(version, homeDir string) {
 	cmd := NewRoot(version, homeDir)
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		handleUnknowns(cmd, err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -86,12 +94,20 @@ func NewRoot(version, homeDir string) *cobra.Command {
 	cmd.AddCommand(newFetchCmd(homeDir))
 	cmd.AddCommand(newVersionCmd(homeDir, version))
 	cmd.AddCommand(newCheckCmd(homeDir))
+	cmd.AddCommand(newCheckFIPSCmd())
 	cmd.AddCommand(newShowCmd(homeDir))
 	cmd.AddCommand(newConfigValidateCmd(homeDir))
 	cmd.AddCommand(newGenCACmd())
 	cmd.AddCommand(newPruneCmd(homeDir))
 	cmd.AddCommand(newSetDefaultHubCmd(homeDir))
+	cmd.AddCommand(newConfigCmd(homeDir))
+	cmd.AddCommand(newUpgradeCmd(homeDir))
+	cmd.AddCommand(newHistoryCmd(homeDir))
+	cmd.AddCommand(newExporterCmd())
 
 	cmd.PersistentFlags().StringVarP(&util.KubeConfig, "kubeconfig", "c", "", "Kubernetes configuration file")
+	cmd.PersistentFlags().BoolVar(&flagNonInteractive, nonInteractiveFlag, false,
+		"never prompt for confirmation. Same as \"getmesh config set non-interactive true\"")
+	cmd.PersistentFlags().BoolVar(&flagNonInteractive, yesFlag, false, "alias of --"+nonInteractiveFlag)
 	return cmd
 }
@@ -0,0 +1,272 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/getmesh"
+	"github.com/tetratelabs/getmesh/src/istioctl"
+	"github.com/tetratelabs/getmesh/src/upgrade"
+	"github.com/tetratelabs/getmesh/src/util"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+type upgradeFlags struct {
+	to, revision, fromRevision string
+	namespaces                 []string
+	oneNamespace, skipCleanup  bool
+	timeout                    time.Duration
+}
+
+func newUpgradeCmd(homedir string) *cobra.Command {
+	var flag upgradeFlags
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade Istio to the specified distribution by the revision-based canary upgrade",
+		Long: `Upgrade Istio to the specified distribution by the revision-based canary upgrade. The steps are:
+
+1. install the new control plane as the revision given by "--revision" with the istioctl of the target distribution
+2. move the namespaces from the old revision to the new one by the "istio.io/rev" label and restart their workloads,
+   then wait until all the proxies in the namespace report the new version via "istioctl version"
+3. remove the old revision
+
+The progress is saved under the getmesh home directory after each step, so the interrupted upgrade is resumed by
+running the same command again. Use "getmesh upgrade rollback" to move the migrated namespaces back and remove the new revision.
+The istioctl of the target distribution must be fetched beforehand by "getmesh fetch".`,
+		Example: `# Upgrade the non-revisioned installation to 1.9.5-tetrate-v0
+$ getmesh upgrade --to 1.9.5-tetrate-v0 --revision 1-9-5
+
+# Migrate one namespace per run, and keep the old revision until all of them are verified
+$ getmesh upgrade --to 1.9.5-tetrate-v0 --revision 1-9-5 --from-revision 1-8-3 --one-namespace --skip-cleanup
+
+# Roll back the upgrade
+$ getmesh upgrade rollback --revision 1-9-5`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return upgradeRun(homedir, &flag)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+	flags.StringVarP(&flag.to, "to", "", "", "Name of the distribution to upgrade to, e.g. 1.9.5-tetrate-v0")
+	flags.StringVarP(&flag.revision, "revision", "", "", "Revision name of the new control plane, e.g. 1-9-5")
+	flags.StringVarP(&flag.fromRevision, "from-revision", "", upgrade.DefaultRevision,
+		"Revision of the current control plane. \"default\" for the non-revisioned installation")
+	flags.StringSliceVarP(&flag.namespaces, "namespaces", "", nil,
+		"Namespaces to migrate. Defaults to all the namespaces injected by the current revision. Cannot be changed while the upgrade is in progress")
+	flags.BoolVarP(&flag.oneNamespace, "one-namespace", "", false, "Migrate only one namespace per run. The old revision is removed on the run after the last namespace is migrated")
+	flags.BoolVarP(&flag.skipCleanup, "skip-cleanup", "", false, "Do not remove the old revision after all the namespaces are migrated")
+	flags.DurationVarP(&flag.timeout, "timeout", "", 10*time.Minute, "Timeout for the proxies in each namespace to be rolled out")
+	_ = cmd.MarkFlagRequired("revision")
+
+	cmd.AddCommand(newUpgradeRollbackCmd(homedir))
+	return cmd
+}
+
+func newUpgradeRollbackCmd(homedir string) *cobra.Command {
+	var (
+		revision string
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Roll back the upgrade in progress",
+		Long: `Roll back the upgrade in progress by moving the migrated namespaces back to the old revision and removing the new revision.
+This is not possible once the old revision has been removed.`,
+		Example: `$ getmesh upgrade rollback --revision 1-9-5`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := upgrade.LoadState(homedir, revision)
+			if err != nil {
+				return err
+			} else if s == nil {
+				return fmt.Errorf("no upgrade to the revision %s found", revision)
+			}
+
+			u, err := upgradeNewUpgrader(homedir, s.To, timeout)
+			if err != nil {
+				return err
+			}
+			if err := u.Rollback(s); err != nil {
+				return err
+			}
+			logger.Infof("The upgrade to the revision %q has been rolled back\n", revision)
+			return s.Remove()
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&revision, "revision", "", "", "Revision name of the upgrade to roll back")
+	flags.DurationVarP(&timeout, "timeout", "", 10*time.Minute, "Timeout for the proxies in each namespace to be rolled out")
+	_ = cmd.MarkFlagRequired("revision")
+	return cmd
+}
+
+func upgradeRun(homedir string, flag *upgradeFlags) error {
+	s, err := upgradeLoadOrNewState(homedir, flag)
+	if err != nil {
+		return err
+	}
+
+	var installArgs []string
+	if s.Phase == upgrade.PhaseInstall {
+		target, err := api.IstioDistributionFromString(s.To)
+		if err != nil {
+			return err
+		}
+
+		// the defaults and the checks applied to "getmesh istioctl install" are applied to the target distribution
+		conf := getmesh.GetActiveConfig()
+		conf.IstioDistribution = target
+		if installArgs, err = istioctlArgChecks([]string{"install", "--revision", s.Revision, "-y"}, conf); err != nil {
+			return err
+		}
+		if err := istioctlVerifyDefaultHub(installArgs, conf); err != nil {
+			return err
+		}
+	}
+
+	u, err := upgradeNewUpgrader(homedir, s.To, flag.timeout)
+	if err != nil {
+		return err
+	}
+	return u.Run(s, upgrade.Options{InstallArgs: installArgs, OneNamespace: flag.oneNamespace, SkipCleanup: flag.skipCleanup})
+}
+
+var upgradeRevisionRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// load the upgrade in progress, or create the new one from the flags
+func upgradeLoadOrNewState(homedir string, flag *upgradeFlags) (*upgrade.State, error) {
+	if !upgradeRevisionRegexp.MatchString(flag.revision) {
+		return nil, fmt.Errorf("invalid revision %q: must consist of lower case alphanumeric characters or '-'", flag.revision)
+	} else if flag.revision == flag.fromRevision {
+		return nil, fmt.Errorf("the new revision must be different from the current one: %s", flag.revision)
+	}
+
+	s, err := upgrade.LoadState(homedir, flag.revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var to string
+	if flag.to != "" {
+		d, err := api.IstioDistributionFromString(flag.to)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse given name %s to istio distribution: %w", flag.to, err)
+		}
+		to = d.ToString()
+	}
+
+	if s != nil {
+		if to != "" && to != s.To {
+			return nil, fmt.Errorf("the upgrade to %s with the revision %s is in progress. "+
+				"Complete it or run \"getmesh upgrade rollback --revision %s\" first", s.To, s.Revision, s.Revision)
+		}
+		if len(flag.namespaces) > 0 && !upgradeSameNamespaces(flag.namespaces, s.Namespaces) {
+			current := "all the namespaces injected by the current revision"
+			if s.Namespaces != nil {
+				current = strings.Join(s.Namespaces, ", ")
+			}
+			return nil, fmt.Errorf("the upgrade with the revision %s is in progress for %s. "+
+				"Resume it without --namespaces or run \"getmesh upgrade rollback --revision %s\" first", s.Revision, current, s.Revision)
+		}
+		logger.Infof("Resuming the upgrade to %s from the %q phase\n", s.To, s.Phase)
+		return s, nil
+	}
+
+	if to == "" {
+		return nil, errors.New("--to flag is required for the new upgrade")
+	}
+	return upgrade.NewState(homedir, to, flag.revision, flag.fromRevision, flag.namespaces), nil
+}
+
+// the namespaces given by --namespaces must be the same as the ones in the state regardless of the order
+func upgradeSameNamespaces(given, current []string) bool {
+	if current == nil || len(given) != len(current) {
+		return false
+	}
+
+	set := make(map[string]bool, len(current))
+	for _, ns := range current {
+		set[ns] = true
+	}
+	for _, ns := range given {
+		if !set[ns] {
+			return false
+		}
+	}
+	return true
+}
+
+func upgradeNewUpgrader(homedir, to string, timeout time.Duration) (*upgrade.Upgrader, error) {
+	d, err := api.IstioDistributionFromString(to)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeCli, err := util.GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &upgrade.Upgrader{
+		KubeCli: kubeCli,
+		Istioctl: func(args []string) error {
+			start := time.Now()
+			err := istioctl.ExecDistribution(homedir, d, args, os.Stdout, os.Stderr)
+			historyRecord(homedir, d, args, start, err)
+			return err
+		},
+		ProxyVersions: func() ([]istioversion.ProxyInfo, error) {
+			return upgradeProxyVersions(homedir, d)
+		},
+		Timeout:      timeout,
+		PollInterval: 5 * time.Second,
+	}, nil
+}
+
+// get the data plane versions in the same way as "getmesh check-upgrade"
+func upgradeProxyVersions(homedir string, d *api.IstioDistribution) ([]istioversion.ProxyInfo, error) {
+	w := new(bytes.Buffer)
+	if err := istioctl.ExecDistribution(homedir, d, []string{"version", "-o", "json"}, w, nil); err != nil {
+		return nil, fmt.Errorf("error executing istioctl: %v", err)
+	}
+
+	if strings.Contains(w.String(), istioctl.IstioVersionNoPodRunningMsg) {
+		return nil, nil
+	}
+
+	var iv istioversion.Version
+	if err := json.Unmarshal(w.Bytes(), &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse istio version results: %v: %s", err, w.Bytes())
+	}
+	if iv.DataPlaneVersion == nil {
+		return nil, nil
+	}
+	return *iv.DataPlaneVersion, nil
+}
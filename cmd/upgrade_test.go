@@ -0,0 +1,69 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/src/upgrade"
+)
+
+func Test_upgradeLoadOrNewState(t *testing.T) {
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, f := range []upgradeFlags{
+			{to: "1.9.5-tetrate-v0", revision: "1.9.5", fromRevision: "default"},
+			{to: "1.9.5-tetrate-v0", revision: "default", fromRevision: "default"},
+			{to: "invalid", revision: "1-9-5", fromRevision: "default"},
+			{revision: "1-9-5", fromRevision: "default"},
+		} {
+			_, err := upgradeLoadOrNewState(home, &f)
+			require.Error(t, err, f)
+		}
+	})
+
+	t.Run("new and resume", func(t *testing.T) {
+		f := upgradeFlags{to: "1.9.5-tetrate-v0", revision: "1-9-5", fromRevision: "default", namespaces: []string{"a"}}
+		s, err := upgradeLoadOrNewState(home, &f)
+		require.NoError(t, err)
+		require.Equal(t, upgrade.PhaseInstall, s.Phase)
+		require.Equal(t, []string{"a"}, s.Namespaces)
+
+		s.Phase = upgrade.PhaseMigrate
+		require.NoError(t, s.Save())
+
+		// --to can be omitted when resuming
+		s, err = upgradeLoadOrNewState(home, &upgradeFlags{revision: "1-9-5", fromRevision: "default"})
+		require.NoError(t, err)
+		require.Equal(t, upgrade.PhaseMigrate, s.Phase)
+		require.Equal(t, "1.9.5-tetrate-v0", s.To)
+
+		_, err = upgradeLoadOrNewState(home, &upgradeFlags{to: "1.9.6-tetrate-v0", revision: "1-9-5", fromRevision: "default"})
+		require.Error(t, err)
+
+		// --namespaces can be given again as long as it's the same as the one in progress
+		_, err = upgradeLoadOrNewState(home, &upgradeFlags{revision: "1-9-5", fromRevision: "default", namespaces: []string{"a"}})
+		require.NoError(t, err)
+		_, err = upgradeLoadOrNewState(home, &upgradeFlags{revision: "1-9-5", fromRevision: "default", namespaces: []string{"a", "b"}})
+		require.Error(t, err)
+	})
+}
@@ -10,12 +10,15 @@ require (
 	github.com/aws/aws-sdk-go v1.36.20
 	github.com/elazarl/goproxy v0.0.0-20201021153353-00ad82a08272 // indirect
 	github.com/golang/protobuf v1.4.3
+	github.com/google/cel-go v0.7.3
 	github.com/google/uuid v1.1.2
 	github.com/imdario/mergo v0.3.11 // indirect
 	github.com/kiali/kiali v1.29.1-0.20210125202741-72d2ce2fceb5
 	github.com/kr/pretty v0.2.1 // indirect
 	github.com/manifoldco/promptui v0.8.0
 	github.com/olekukonko/tablewriter v0.0.4
+	github.com/openshift/api v0.0.0-20200221181648-8ce0047d664f
+	github.com/prometheus/client_golang v1.9.0
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.6.1
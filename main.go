@@ -43,5 +43,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := getmesh.ExportProxyEnv(); err != nil {
+		logger.Errorf("error configuring proxy: %w", err)
+		os.Exit(1)
+	}
+
 	cmd.Execute(version, hd)
 }
@@ -0,0 +1,168 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/getmesh"
+)
+
+// MinorVersionStatus is the result of the checks against a minor version running in the mesh
+type MinorVersionStatus struct {
+	// in the form of x.y-${flavor}
+	Group string
+	// the lowest patch running in the data plane and the control plane
+	Lowest *api.IstioDistribution
+	// the latest patch in the manifest, or nil if the minor version is no longer supported
+	Latest *api.IstioDistribution
+	// whether the patches newer than Lowest include security fixes
+	Security bool
+	// the end of life date in the manifest, or zero if not found
+	EOL time.Time
+	// whether the end of life date has already passed
+	PastEOL bool
+	// whether the end of life date is within the warning period, configurable via "getmesh config set eol-warning-days"
+	NearEOL bool
+}
+
+// Analysis is the result of the checks without the messages
+type Analysis struct {
+	MinorVersions []MinorVersionStatus
+	// whether multiple minor versions are running in either plane regardless of the flavors
+	MultiMinor bool
+	// the sorted minor versions running in each plane, in the form of x.y-${flavor}
+	DataPlaneMinors    []string
+	ControlPlaneMinors []string
+}
+
+// Issues returns the categories of the issues found
+func (a *Analysis) Issues() Issues {
+	ret := Issues{MultiMinor: a.MultiMinor}
+	for _, m := range a.MinorVersions {
+		ret = ret.Merge(m.Issues())
+	}
+	return ret
+}
+
+// Issues returns the categories of the issues found in the minor version
+func (m MinorVersionStatus) Issues() Issues {
+	if m.Latest == nil {
+		return Issues{EOL: true}
+	} else if !m.Latest.Equal(m.Lowest) {
+		return Issues{Patch: true, Security: m.Security, EOL: m.PastEOL, NearEOL: m.NearEOL}
+	}
+	return Issues{EOL: m.PastEOL, NearEOL: m.NearEOL}
+}
+
+// Analyze runs the same checks as IstioVersion without printing
+func Analyze(iv istioversion.Version, manifest *api.Manifest) (*Analysis, error) {
+	return analyze(iv, manifest, time.Now())
+}
+
+// AnalyzeAt runs the checks as of the given time, which is used to evaluate the end of life dates
+func AnalyzeAt(iv istioversion.Version, manifest *api.Manifest, now time.Time) (*Analysis, error) {
+	return analyze(iv, manifest, now)
+}
+
+func analyze(iv istioversion.Version, manifest *api.Manifest, now time.Time) (*Analysis, error) {
+	dpVersions, err := getDataPlaneVersions(iv.DataPlaneVersion)
+	if err != nil {
+		return nil, fmt.Errorf("collecting data plane versions: %v", err)
+	}
+	cpVersions, err := getControlPlaneVersions(iv.MeshVersion)
+	if err != nil {
+		return nil, fmt.Errorf("collecting control plane versions: %v", err)
+	}
+	ret := &Analysis{
+		DataPlaneMinors:    sortedGroups(dpVersions),
+		ControlPlaneMinors: sortedGroups(cpVersions),
+	}
+	ret.MultiMinor = len(minorVersions(ret.DataPlaneMinors)) > 1 || len(minorVersions(ret.ControlPlaneMinors)) > 1
+
+	lowest := dpVersions
+	for k, next := range cpVersions {
+		if prev, ok := lowest[k]; !ok {
+			lowest[k] = next
+		} else if ok, _ = prev.GreaterThan(next); ok {
+			lowest[k] = next
+		}
+	}
+
+	for _, g := range sortedGroups(lowest) {
+		m, err := newMinorVersionStatus(lowest[g], manifest, now)
+		if err != nil {
+			return nil, err
+		}
+		ret.MinorVersions = append(ret.MinorVersions, m)
+	}
+	return ret, nil
+}
+
+// check the lowest patch running in the minor version against the manifest
+func newMinorVersionStatus(lowest *api.IstioDistribution, manifest *api.Manifest, now time.Time) (MinorVersionStatus, error) {
+	g, err := lowest.Group()
+	if err != nil {
+		return MinorVersionStatus{}, err
+	}
+
+	latest, security, err := api.GetLatestDistribution(lowest, manifest)
+	if err != nil {
+		return MinorVersionStatus{}, fmt.Errorf("checking the latest patch for %s: %v", g, err)
+	}
+
+	eols, err := manifest.GetEOLDates()
+	if err != nil {
+		return MinorVersionStatus{}, fmt.Errorf("parsing the end of life dates: %v", err)
+	}
+
+	ret := MinorVersionStatus{Group: g, Lowest: lowest, Latest: latest, Security: security}
+	if eol, ok := eols[lowest.MinorVersion()]; ok {
+		ret.EOL, ret.PastEOL = eol, pastEndOfLife(eol, now)
+		ret.NearEOL = !ret.PastEOL && getmesh.EOLWarningStart(eol).Before(now)
+	}
+	return ret, nil
+}
+
+func sortedGroups(in map[string]*api.IstioDistribution) []string {
+	ret := make([]string, 0, len(in))
+	for g := range in {
+		ret = append(ret, g)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// minorVersions returns the sorted minor versions of the groups regardless of the flavors,
+// e.g. ["1.9"] for ["1.9-istio", "1.9-tetrate"]
+func minorVersions(groups []string) []string {
+	seen := make(map[string]struct{}, len(groups))
+	ret := make([]string, 0, len(groups))
+	for _, g := range groups {
+		m := strings.SplitN(g, "-", 2)[0]
+		if _, ok := seen[m]; !ok {
+			seen[m] = struct{}{}
+			ret = append(ret, m)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+)
+
+func Test_analyze_endOfLife(t *testing.T) {
+	iv := istioversion.Version{
+		MeshVersion:      &istioversion.MeshInfo{{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.3-tetrate-v0"}}},
+		DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.7.8-tetrate-v0"}},
+	}
+	ms := &api.Manifest{
+		IstioDistributions: []*api.IstioDistribution{
+			{Version: "1.8.3", Flavor: "tetrate", FlavorVersion: 0},
+			{Version: "1.7.8", Flavor: "tetrate", FlavorVersion: 0},
+		},
+		IstioMinorVersionsEolDates: map[string]string{"1.7": "2021-04-01", "1.8": "2021-05-11"},
+	}
+
+	analysis, err := analyze(iv, ms, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, analysis.MinorVersions, 2)
+	require.Equal(t, time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), analysis.MinorVersions[0].EOL)
+	require.True(t, analysis.MinorVersions[0].PastEOL)
+	require.Equal(t, time.Date(2021, 5, 11, 0, 0, 0, 0, time.UTC), analysis.MinorVersions[1].EOL)
+	require.False(t, analysis.MinorVersions[1].PastEOL)
+	require.False(t, analysis.MinorVersions[0].NearEOL)
+	require.True(t, analysis.MinorVersions[1].NearEOL)
+	require.Equal(t, Issues{EOL: true, NearEOL: true}, analysis.Issues())
+
+	report := NewHealthReport(iv, analysis, nil, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, "2021-04-01", report.MinorVersions[0].EndOfLife)
+	require.Equal(t, "minor versions no longer supported: 1.7-tetrate", report.Conditions[2].Message)
+	require.True(t, report.MinorVersions[1].NearEndOfLife)
+	require.Equal(t, "minor versions reaching the end of life: 1.8-tetrate (2021-05-11)", report.Conditions[3].Message)
+}
@@ -15,10 +15,10 @@
 package checkupgrade
 
 import (
-	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	// https://github.com/istio/pkg/blob/4f521de9c8caa220ebc9e7f57da2726dff2788fc/version/cobra.go
 	// TODO: Though this package is stable and it's been over a year since it changed last (as of 2020/11/19),
@@ -29,17 +29,20 @@ import (
 	"github.com/tetratelabs/getmesh/src/util/logger"
 )
 
-var ErrIssueFound = errors.New("version issue found")
-
-func IstioVersion(iv istioversion.Version, manifest *api.Manifest) error {
+// IstioVersion prints the summary and the results of the checks, and returns the issues found
+func IstioVersion(iv istioversion.Version, manifest *api.Manifest) (Issues, error) {
 	logger.Infof("[Summary of your Istio mesh]\n")
 	printSummary(iv)
 	logger.Infof("[GetMesh Check]\n")
-	return printgetmeshCheck(iv, manifest)
+	return printgetmeshCheck(iv, manifest, time.Now())
 }
 
 func printSummary(iv istioversion.Version) {
-	msg := fmt.Sprintf("active istioctl version: %s\n", iv.ClientVersion.Version)
+	var msg string
+	// no istioctl is used in the cluster
+	if cv := iv.ClientVersion; cv != nil && cv.Version != "" {
+		msg = fmt.Sprintf("active istioctl version: %s\n", cv.Version)
+	}
 
 	if dv := iv.DataPlaneVersion; dv != nil && len(*dv) > 0 {
 		versions := make(map[string]int, len(*dv))
@@ -71,113 +74,125 @@ func printSummary(iv istioversion.Version) {
 	logger.Infof("%s\n", msg)
 }
 
-func printgetmeshCheck(iv istioversion.Version, manifest *api.Manifest) error {
-	var multiple bool
-	dpVersions, err := getDataPlaneVersions(iv.DataPlaneVersion)
+func printgetmeshCheck(iv istioversion.Version, manifest *api.Manifest, now time.Time) (Issues, error) {
+	analysis, err := analyze(iv, manifest, now)
 	if err != nil {
-		return fmt.Errorf("collecting data plane versions: %v", err)
+		return Issues{}, err
 	}
 
-	cpVersions, err := getControlPlaneVersions(iv.MeshVersion)
-	if err != nil {
-		return fmt.Errorf("collecting control plane versions: %v", err)
+	dpMinors, cpMinors := minorVersions(analysis.DataPlaneMinors), minorVersions(analysis.ControlPlaneMinors)
+	if len(dpMinors) > 1 {
+		logger.Infof(getMultipleMinorVersionRunningMsg("data plane", analysis.DataPlaneMinors))
 	}
 
-	if len(dpVersions) > 1 {
-		multiple = true
-		logger.Infof(getMultipleMinorVersionRunningMsg("data plane", dpVersions))
+	if len(cpMinors) > 1 {
+		logger.Infof(getMultipleMinorVersionRunningMsg("control plane", analysis.ControlPlaneMinors))
 	}
 
-	if len(cpVersions) > 1 {
-		multiple = true
-		logger.Infof(getMultipleMinorVersionRunningMsg("control plane", cpVersions))
+	if len(analysis.MinorVersions) == 0 {
+		logger.Infof("nothing to check.\n")
+		return analysis.Issues(), nil
 	}
 
-	if len(cpVersions) == 0 && len(dpVersions) == 0 {
-		logger.Infof("nothing to check.\n")
-		return nil
+	if len(cpMinors) == 1 && len(dpMinors) == 1 && cpMinors[0] != dpMinors[0] {
+		logger.Infof("- Your data plane running in the minor version %s but control plane in %s\n",
+			strings.Join(analysis.DataPlaneMinors, ", "), strings.Join(analysis.ControlPlaneMinors, ", "))
 	}
 
-	if !multiple &&
-		(len(cpVersions) == len(dpVersions) && len(cpVersions) == 1) {
+	for _, m := range analysis.MinorVersions {
+		logger.Infof(getLatestPatchMsg(m))
+		logger.Infof(getEndOfLifeMsg(m, now))
 
-		var cpV, dpV string
-		for v := range cpVersions {
-			cpV = v
-		}
-		for v := range dpVersions {
-			dpV = v
-		}
-		if cpV != dpV {
-			logger.Infof("- Your data plane running in the minor version %s but control plane in %s\n", dpV, cpV)
+		if m.Lowest.IsUpstream() {
+			msg, err := getTetrateBuildMsg(m.Lowest, manifest)
+			if err != nil {
+				return Issues{}, fmt.Errorf("checking the tetrate build for %s: %v", m.Group, err)
+			}
+			logger.Infof(msg)
 		}
 	}
 
-	versionToLowestPatches := dpVersions
-	for k, next := range cpVersions {
-		if prev, ok := versionToLowestPatches[k]; !ok {
-			versionToLowestPatches[k] = next
-		} else if ok, _ = prev.GreaterThan(next); ok {
-			versionToLowestPatches[k] = next
-		}
+	return analysis.Issues(), nil
+}
+
+func getLatestPatchMsg(m MinorVersionStatus) string {
+	if m.Latest == nil {
+		return fmt.Sprintf("- The minor version %s is no longer supported by getmesh. "+
+			"We recommend you use the higher minor versions in \"getmesh list\"\n", m.Group)
 	}
 
-	var okCount int
-	for group, v := range versionToLowestPatches {
-		msg, ok, err := getLatestPatchInManifestMsg(v, manifest)
-		if err != nil {
-			return fmt.Errorf("checking the latest patch for %s: %v", group, err)
-		}
-		if ok {
-			okCount++
-		}
-		logger.Infof(msg)
+	// the upstream builds are printed by the versions they are tagged with, e.g. "1.9.5" instead of "1.9.5-istio-v0"
+	if m.Latest.Equal(m.Lowest) {
+		return fmt.Sprintf("- %s is the latest version in %s\n", m.Lowest.ImageTag(), m.Group)
 	}
 
-	if okCount == len(versionToLowestPatches) {
-		return nil
+	msg := fmt.Sprintf("- There is the available patch for the minor version %s", m.Group)
+	if m.Security {
+		msg += fmt.Sprintf(" which includes **security upgrades**. We strongly recommend upgrading all %s versions -> %s\n", m.Group, m.Latest.ImageTag())
+	} else {
+		msg += fmt.Sprintf(". We recommend upgrading all %s versions -> %s\n", m.Group, m.Latest.ImageTag())
+
 	}
-	return ErrIssueFound
+	return msg
 }
 
-func getLatestPatchInManifestMsg(target *api.IstioDistribution, manifest *api.Manifest) (string, bool, error) {
-	tg, err := target.Group()
-	if err != nil {
-		return "", false, err
+// annotate the minor version with the end of life date in the manifest if any
+func getEndOfLifeMsg(m MinorVersionStatus, now time.Time) string {
+	if m.EOL.IsZero() {
+		return ""
 	}
 
-	foundLatest, includeSecurityPatch, err := api.GetLatestDistribution(target, manifest)
-	if err != nil {
-		return "", false, err
+	date := m.EOL.Format("2006-01-02")
+	if m.PastEOL {
+		return fmt.Sprintf("- The minor version %s reached the end of life on %s. "+
+			"We strongly recommend upgrading to the higher minor versions in \"getmesh list\"\n", m.Group, date)
+	} else if m.NearEOL {
+		return fmt.Sprintf("- The minor version %s is reaching the end of life on %s (in %s). "+
+			"We recommend planning the upgrade to the higher minor versions in \"getmesh list\"\n", m.Group, date, formatDays(m.EOL.Sub(now)))
 	}
+	return fmt.Sprintf("- The minor version %s is supported until %s\n", m.Group, date)
+}
 
-	if foundLatest == nil {
-		return fmt.Sprintf("- The minor version %s is no longer supported by getmesh. "+
-			"We recommend you use the higher minor versions in \"getmesh list\"\n", tg), false, nil
+func pastEndOfLife(eol, now time.Time) bool {
+	return !now.Before(eol)
+}
+
+// PrintFetchedEndOfLife warns about the fetched istioctl whose minor versions reached the end of life
+func PrintFetchedEndOfLife(fetched []*api.IstioDistribution, manifest *api.Manifest, now time.Time) error {
+	dates, err := manifest.GetEOLDates()
+	if err != nil {
+		return fmt.Errorf("error parsing the end of life dates: %v", err)
 	}
 
-	if foundLatest.Equal(target) {
-		return fmt.Sprintf("- %s is the latest version in %s\n", target.ToString(), tg), true, nil
+	for _, d := range fetched {
+		if eol, ok := dates[d.MinorVersion()]; ok && pastEndOfLife(eol, now) {
+			logger.Warnf("The fetched istioctl %s is in the minor version %s which reached the end of life on %s. "+
+				"You can remove it by \"getmesh prune --version %s --flavor %s --flavor-version %d\"\n",
+				d.ToString(), d.MinorVersion(), eol.Format("2006-01-02"), d.Version, d.Flavor, d.FlavorVersion)
+		}
 	}
+	return nil
+}
 
-	msg := fmt.Sprintf("- There is the available patch for the minor version %s", tg)
-	if includeSecurityPatch {
-		msg += fmt.Sprintf(" which includes **security upgrades**. We strongly recommend upgrading all %s versions -> %s\n", tg, foundLatest.ToString())
-	} else {
-		msg += fmt.Sprintf(". We recommend upgrading all %s versions -> %s\n", tg, foundLatest.ToString())
+// suggest the latest tetrate build in the same minor version as the upstream distribution
+func getTetrateBuildMsg(upstream *api.IstioDistribution, manifest *api.Manifest) (string, error) {
+	latest, _, err := api.GetLatestDistribution(
+		&api.IstioDistribution{Version: upstream.Version, Flavor: api.IstioDistributionFlavorTetrate}, manifest)
+	if err != nil {
+		return "", err
+	}
 
+	if latest == nil {
+		return "", nil
 	}
-	return msg, false, nil
+	return fmt.Sprintf("- %s is the upstream Istio build. The equivalent Tetrate build is %s, "+
+		"which can be fetched by \"getmesh fetch --version %s --flavor %s --flavor-version %d\"\n",
+		upstream.Version, latest.ToString(), latest.Version, latest.Flavor, latest.FlavorVersion), nil
 }
 
-func getMultipleMinorVersionRunningMsg(t string, mvs map[string]*api.IstioDistribution) string {
+func getMultipleMinorVersionRunningMsg(t string, groups []string) string {
 	const template = "- Your %s running in multiple minor versions: %s\n"
-	vs := make([]string, 0, len(mvs))
-	for v := range mvs {
-		vs = append(vs, v)
-	}
-	sort.Strings(vs)
-	return fmt.Sprintf(template, t, strings.Join(vs, ", "))
+	return fmt.Sprintf(template, t, strings.Join(groups, ", "))
 }
 
 // construct {version's group -> lowest patch version} map from control plane versions
@@ -218,12 +233,6 @@ func getDataPlaneVersions(info *[]istioversion.ProxyInfo) (map[string]*api.Istio
 func findLowestPatchVersionsInGroup(in []*api.IstioDistribution) (map[string]*api.IstioDistribution, error) {
 	ret := make(map[string]*api.IstioDistribution, len(in))
 	for _, d := range in {
-		if d.IsUpstream() {
-			logger.Warnf("the upstream istio distributions are not supported by check-upgrade command: %s. "+
-				"Please install distributions with tetrate flavor listed in `getmesh list` command\n", d.Version)
-			continue
-		}
-
 		vg, err := d.Group()
 		if err != nil {
 			return nil, fmt.Errorf("error parsing version %s: %v", d.ToString(), err)
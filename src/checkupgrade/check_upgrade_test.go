@@ -17,6 +17,7 @@ package checkupgrade
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	istioversion "istio.io/pkg/version"
@@ -39,19 +40,32 @@ func TestIstioVersion(t *testing.T) {
 		},
 	}
 
-	require.NoError(t, IstioVersion(in, &api.Manifest{
+	issues, err := IstioVersion(in, &api.Manifest{
 		IstioDistributions: []*api.IstioDistribution{
 			{Version: "1.7.4", FlavorVersion: 0, Flavor: "tetrate"},
 			{Version: "1.6.10", FlavorVersion: 0, Flavor: "tetrate"},
 		},
-	}))
+	})
+	require.NoError(t, err)
+	require.Equal(t, Issues{}, issues)
 
-	require.Equal(t, ErrIssueFound, IstioVersion(in, &api.Manifest{
+	issues, err = IstioVersion(in, &api.Manifest{
 		IstioDistributions: []*api.IstioDistribution{{Version: "1.7.4", Flavor: "tetrate", FlavorVersion: 100}},
-	}))
-	require.Equal(t, ErrIssueFound, IstioVersion(in, &api.Manifest{
-		IstioDistributions: []*api.IstioDistribution{{Version: "1.7.5", Flavor: "tetrate", FlavorVersion: 0}},
-	}))
+	})
+	require.NoError(t, err)
+	require.Equal(t, Issues{Patch: true}, issues)
+
+	issues, err = IstioVersion(in, &api.Manifest{
+		IstioDistributions: []*api.IstioDistribution{{Version: "1.7.5", Flavor: "tetrate", FlavorVersion: 0, IsSecurityPatch: true}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, Issues{Patch: true, Security: true}, issues)
+
+	issues, err = IstioVersion(in, &api.Manifest{
+		IstioDistributions: []*api.IstioDistribution{{Version: "1.8.1", Flavor: "tetrate", FlavorVersion: 0}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, Issues{EOL: true}, issues)
 }
 
 func Test_printSummary(t *testing.T) {
@@ -156,50 +170,100 @@ func Test_printgetmeshCheck(t *testing.T) {
 				},
 			},
 		} {
-			err := printgetmeshCheck(c, &api.Manifest{
-				IstioDistributions: []*api.IstioDistribution{{Version: "1.4.aaaa"}}})
+			_, err := printgetmeshCheck(c, &api.Manifest{
+				IstioDistributions: []*api.IstioDistribution{{Version: "1.aaaa"}}}, time.Now())
 			require.Error(t, err)
 			t.Log(err)
 		}
 	})
 
-	t.Run("only upstream versions", func(t *testing.T) {
-		for i, c := range []istioversion.Version{
-			{
-				DataPlaneVersion: &[]istioversion.ProxyInfo{
-					{IstioVersion: "1.7.1"},
-					{IstioVersion: "1.6.1"},
-				},
-			},
-			{
-				MeshVersion: &istioversion.MeshInfo{
-					{Info: istioversion.BuildInfo{Version: "1.7.1"}},
-					{Info: istioversion.BuildInfo{Version: "1.20.1"}},
-				},
-			},
-			{
-				DataPlaneVersion: &[]istioversion.ProxyInfo{
-					{IstioVersion: "1.7.1"},
-					{IstioVersion: "1.6.1"},
-				},
-				MeshVersion: &istioversion.MeshInfo{
-					{Info: istioversion.BuildInfo{Version: "1.7.1"}},
-					{Info: istioversion.BuildInfo{Version: "1.20.1"}},
-				},
-			},
-		} {
-			t.Run(fmt.Sprintf("%d-th", i), func(t *testing.T) {
-				buf := logger.ExecuteWithLock(func() {
-					err := printgetmeshCheck(c, &api.Manifest{})
-					require.NoError(t, err)
-				})
+	t.Run("upstream versions", func(t *testing.T) {
+		ms := &api.Manifest{IstioDistributions: []*api.IstioDistribution{
+			{Version: "1.9.5", Flavor: api.IstioDistributionFlavorIstio, IsSecurityPatch: true},
+			{Version: "1.9.3", Flavor: api.IstioDistributionFlavorIstio},
+			{Version: "1.9.5", Flavor: api.IstioDistributionFlavorTetrate, FlavorVersion: 1},
+			{Version: "1.9.5", Flavor: api.IstioDistributionFlavorTetrate, FlavorVersion: 0},
+		}}
 
-				actual := buf.String()
-				require.Contains(t, actual, "Please install distributions with tetrate flavor listed in `getmesh list` command")
-				require.Contains(t, actual, "nothing to check")
-				t.Log(actual)
+		t.Run("recommend upgrade", func(t *testing.T) {
+			buf := logger.ExecuteWithLock(func() {
+				issues, err := printgetmeshCheck(istioversion.Version{
+					DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.9.3"}, {IstioVersion: "1.9.5"}},
+					MeshVersion:      &istioversion.MeshInfo{{Info: istioversion.BuildInfo{Version: "1.9.5"}}},
+				}, ms, time.Now())
+				require.NoError(t, err)
+				require.Equal(t, Issues{Patch: true, Security: true}, issues)
+			})
+
+			require.Equal(t, "- There is the available patch for the minor version 1.9-istio which includes **security upgrades**. "+
+				"We strongly recommend upgrading all 1.9-istio versions -> 1.9.5\n"+
+				"- 1.9.3 is the upstream Istio build. The equivalent Tetrate build is 1.9.5-tetrate-v1, "+
+				"which can be fetched by \"getmesh fetch --version 1.9.5 --flavor tetrate --flavor-version 1\"\n", buf.String())
+		})
+
+		t.Run("upstream and tetrate builds in the same minor version", func(t *testing.T) {
+			buf := logger.ExecuteWithLock(func() {
+				issues, err := printgetmeshCheck(istioversion.Version{
+					DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.9.5"}, {IstioVersion: "1.9.5-tetrate-v1"}},
+					MeshVersion:      &istioversion.MeshInfo{{Info: istioversion.BuildInfo{Version: "1.9.5-tetrate-v1"}}},
+				}, ms, time.Now())
+				require.NoError(t, err)
+				require.Equal(t, Issues{}, issues)
+			})
+
+			actual := buf.String()
+			require.NotContains(t, actual, "multiple minor versions")
+			require.NotContains(t, actual, "but control plane in")
+			require.Contains(t, actual, "- 1.9.5 is the latest version in 1.9-istio\n")
+			require.Contains(t, actual, "- 1.9.5-tetrate-v1 is the latest version in 1.9-tetrate\n")
+			require.Contains(t, actual, "The equivalent Tetrate build is 1.9.5-tetrate-v1")
+		})
+
+		t.Run("unsupported", func(t *testing.T) {
+			buf := logger.ExecuteWithLock(func() {
+				issues, err := printgetmeshCheck(istioversion.Version{
+					MeshVersion: &istioversion.MeshInfo{{Info: istioversion.BuildInfo{Version: "1.20.1"}}},
+				}, ms, time.Now())
+				require.NoError(t, err)
+				require.Equal(t, Issues{EOL: true}, issues)
 			})
+			require.Equal(t, "- The minor version 1.20-istio is no longer supported by getmesh. "+
+				"We recommend you use the higher minor versions in \"getmesh list\"\n", buf.String())
+		})
+	})
+
+	t.Run("end of life", func(t *testing.T) {
+		ms := &api.Manifest{
+			IstioDistributions: []*api.IstioDistribution{
+				{Version: "1.8.3", Flavor: api.IstioDistributionFlavorTetrate},
+				{Version: "1.7.8", Flavor: api.IstioDistributionFlavorTetrate},
+			},
+			IstioMinorVersionsEolDates: map[string]string{"1.8": "2021-05-11", "1.7": "2021-04-01"},
 		}
+		now := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+
+		buf := logger.ExecuteWithLock(func() {
+			issues, err := printgetmeshCheck(istioversion.Version{
+				MeshVersion: &istioversion.MeshInfo{{Info: istioversion.BuildInfo{Version: "1.8.3-tetrate-v0"}}},
+			}, ms, now)
+			require.NoError(t, err)
+			require.Equal(t, Issues{NearEOL: true}, issues)
+		})
+		require.Equal(t, "- 1.8.3-tetrate-v0 is the latest version in 1.8-tetrate\n"+
+			"- The minor version 1.8-tetrate is reaching the end of life on 2021-05-11 (in 10 days). "+
+			"We recommend planning the upgrade to the higher minor versions in \"getmesh list\"\n", buf.String())
+
+		buf = logger.ExecuteWithLock(func() {
+			issues, err := printgetmeshCheck(istioversion.Version{
+				DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.7.8-tetrate-v0"}},
+				MeshVersion:      &istioversion.MeshInfo{{Info: istioversion.BuildInfo{Version: "1.7.8-tetrate-v0"}}},
+			}, ms, now)
+			require.NoError(t, err)
+			require.Equal(t, Issues{EOL: true}, issues)
+		})
+		require.Equal(t, "- 1.7.8-tetrate-v0 is the latest version in 1.7-tetrate\n"+
+			"- The minor version 1.7-tetrate reached the end of life on 2021-04-01. "+
+			"We strongly recommend upgrading to the higher minor versions in \"getmesh list\"\n", buf.String())
 	})
 
 	t.Run("multiple minor versions", func(t *testing.T) {
@@ -210,12 +274,6 @@ func Test_printgetmeshCheck(t *testing.T) {
 					{IstioVersion: "1.6.1-tetrate-v1"},
 				},
 			},
-			{
-				MeshVersion: &istioversion.MeshInfo{
-					{Info: istioversion.BuildInfo{Version: "1.7.1-tetratefips-v1"}},
-					{Info: istioversion.BuildInfo{Version: "1.7.1-tetrate-v1"}},
-				},
-			},
 			{
 				MeshVersion: &istioversion.MeshInfo{
 					{Info: istioversion.BuildInfo{Version: "1.7.1-tetratefips-v1"}},
@@ -233,28 +291,62 @@ func Test_printgetmeshCheck(t *testing.T) {
 					{Info: istioversion.BuildInfo{Version: "1.7.1-tetrate-v3"}},
 				},
 			},
+		} {
+
+			t.Run(fmt.Sprintf("%d-th", i), func(t *testing.T) {
+				buf := logger.ExecuteWithLock(func() {
+					issues, err := printgetmeshCheck(c, &api.Manifest{}, time.Now())
+					require.NoError(t, err)
+					require.True(t, issues.MultiMinor)
+				})
+
+				actual := buf.String()
+				require.Contains(t, actual, "running in multiple minor versions")
+				t.Log(actual)
+			})
+		}
+	})
+
+	t.Run("multiple flavors in the same minor version", func(t *testing.T) {
+		for i, c := range []struct {
+			iv  istioversion.Version
+			exp string
+		}{
 			{
-				DataPlaneVersion: &[]istioversion.ProxyInfo{
-					{IstioVersion: "1.6.1-tetrate-v1"},
-					{IstioVersion: "1.6.1-tetrate-v2"},
+				iv: istioversion.Version{
+					MeshVersion: &istioversion.MeshInfo{
+						{Info: istioversion.BuildInfo{Version: "1.7.1-tetratefips-v1"}},
+						{Info: istioversion.BuildInfo{Version: "1.7.1-tetrate-v1"}},
+					},
 				},
-				MeshVersion: &istioversion.MeshInfo{
-					{Info: istioversion.BuildInfo{Version: "1.7.1-tetrate-v1"}},
-					{Info: istioversion.BuildInfo{Version: "1.7.2-tetrate-v1"}},
-					{Info: istioversion.BuildInfo{Version: "1.7.1-tetratefips-v3"}},
+			},
+			{
+				iv: istioversion.Version{
+					DataPlaneVersion: &[]istioversion.ProxyInfo{
+						{IstioVersion: "1.6.1-tetrate-v1"},
+						{IstioVersion: "1.6.1-tetrate-v2"},
+					},
+					MeshVersion: &istioversion.MeshInfo{
+						{Info: istioversion.BuildInfo{Version: "1.7.1-tetrate-v1"}},
+						{Info: istioversion.BuildInfo{Version: "1.7.2-tetrate-v1"}},
+						{Info: istioversion.BuildInfo{Version: "1.7.1-tetratefips-v3"}},
+					},
 				},
+				exp: "- Your data plane running in the minor version 1.6-tetrate but control plane in 1.7-tetrate, 1.7-tetratefips\n",
 			},
 		} {
-
 			t.Run(fmt.Sprintf("%d-th", i), func(t *testing.T) {
 				buf := logger.ExecuteWithLock(func() {
-					err := printgetmeshCheck(c, &api.Manifest{})
-					require.Error(t, err)
+					issues, err := printgetmeshCheck(c.iv, &api.Manifest{}, time.Now())
+					require.NoError(t, err)
+					require.False(t, issues.MultiMinor)
 				})
 
 				actual := buf.String()
-				require.Contains(t, actual, "running in multiple minor versions")
-				t.Log(actual)
+				require.NotContains(t, actual, "running in multiple minor versions")
+				if c.exp != "" {
+					require.Contains(t, actual, c.exp)
+				}
 			})
 		}
 	})
@@ -343,8 +435,9 @@ func Test_printgetmeshCheck(t *testing.T) {
 		} {
 			t.Run(fmt.Sprintf("%d-th", i), func(t *testing.T) {
 				buf := logger.ExecuteWithLock(func() {
-					err := printgetmeshCheck(c.iv, &api.Manifest{IstioDistributions: c.ds})
-					require.Error(t, err)
+					issues, err := printgetmeshCheck(c.iv, &api.Manifest{IstioDistributions: c.ds}, time.Now())
+					require.NoError(t, err)
+					require.True(t, issues.Any())
 				})
 
 				actual := buf.String()
@@ -357,16 +450,57 @@ func Test_printgetmeshCheck(t *testing.T) {
 	})
 }
 
-func Test_getLatestPatchInManifestMsg(t *testing.T) {
+func Test_getEndOfLifeMsg(t *testing.T) {
+	ms := &api.Manifest{IstioMinorVersionsEolDates: map[string]string{"1.8": "2021-05-11"}}
+	for _, c := range []struct {
+		name, version    string
+		now              time.Time
+		exp              string
+		pastEOL, nearEOL bool
+	}{
+		{name: "unknown", version: "1.9.0", now: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "supported", version: "1.8.1", now: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+			exp: "- The minor version 1.8-tetrate is supported until 2021-05-11\n"},
+		{name: "near", version: "1.8.1", now: time.Date(2021, 5, 10, 0, 0, 0, 0, time.UTC),
+			exp: "- The minor version 1.8-tetrate is reaching the end of life on 2021-05-11 (in 1 day). " +
+				"We recommend planning the upgrade to the higher minor versions in \"getmesh list\"\n", nearEOL: true},
+		{name: "past", version: "1.8.1", now: time.Date(2021, 5, 11, 0, 0, 0, 0, time.UTC),
+			exp: "- The minor version 1.8-tetrate reached the end of life on 2021-05-11. " +
+				"We strongly recommend upgrading to the higher minor versions in \"getmesh list\"\n", pastEOL: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := newMinorVersionStatus(&api.IstioDistribution{
+				Version: c.version, Flavor: api.IstioDistributionFlavorTetrate}, ms, c.now)
+			require.NoError(t, err)
+			require.Equal(t, c.exp, getEndOfLifeMsg(m, c.now))
+			require.Equal(t, c.pastEOL, m.PastEOL)
+			require.Equal(t, c.nearEOL, m.NearEOL)
+		})
+	}
+}
+
+func TestPrintFetchedEndOfLife(t *testing.T) {
+	ms := &api.Manifest{IstioMinorVersionsEolDates: map[string]string{"1.8": "2021-05-11", "1.7": "2021-04-01"}}
+	buf := logger.ExecuteWithLock(func() {
+		require.NoError(t, PrintFetchedEndOfLife([]*api.IstioDistribution{
+			{Version: "1.7.8", Flavor: api.IstioDistributionFlavorTetrate},
+			{Version: "1.8.3", Flavor: api.IstioDistributionFlavorTetrate},
+			{Version: "1.9.0", Flavor: api.IstioDistributionFlavorTetrate},
+		}, ms, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)))
+	})
+	require.Equal(t, "[WARNING] The fetched istioctl 1.7.8-tetrate-v0 is in the minor version 1.7 which reached the end of life on 2021-04-01. "+
+		"You can remove it by \"getmesh prune --version 1.7.8 --flavor tetrate --flavor-version 0\"\n", buf.String())
+}
+
+func Test_getLatestPatchMsg(t *testing.T) {
 	t.Run("error", func(t *testing.T) {
 		ms := []*api.IstioDistribution{
 			{Version: "1.aaaa"},
 			{Version: "1.7.8"},
 			{Version: "1.7.9"},
 		}
-		_, ok, err := getLatestPatchInManifestMsg(&api.IstioDistribution{}, &api.Manifest{IstioDistributions: ms})
+		_, err := newMinorVersionStatus(&api.IstioDistribution{Version: "1.7.1"}, &api.Manifest{IstioDistributions: ms}, time.Now())
 		require.Error(t, err)
-		require.False(t, ok)
 	})
 
 	t.Run("not supported minot version", func(t *testing.T) {
@@ -374,21 +508,21 @@ func Test_getLatestPatchInManifestMsg(t *testing.T) {
 			{Version: "1.8.10", FlavorVersion: 20, Flavor: "tetrate"},
 			{Version: "1.7.8", FlavorVersion: 10, Flavor: "tetratefips"},
 		}
-		msg, ok, err := getLatestPatchInManifestMsg(&api.IstioDistribution{
+		msg, issues, err := latestPatchMsg(&api.IstioDistribution{
 			Version: "1.0.100",
 			Flavor:  "tetrate", FlavorVersion: 10,
 		}, &api.Manifest{IstioDistributions: ms})
 		require.NoError(t, err)
-		require.False(t, ok)
+		require.Equal(t, Issues{EOL: true}, issues)
 		require.Contains(t, msg, "The minor version 1.0-tetrate is no longer supported by getmesh.")
 		require.Contains(t, msg, "getmesh list")
 		t.Log(msg)
 
-		msg, ok, err = getLatestPatchInManifestMsg(&api.IstioDistribution{
+		msg, issues, err = latestPatchMsg(&api.IstioDistribution{
 			Version: "1.10.100", Flavor: "tetratefips", FlavorVersion: 10,
 		}, &api.Manifest{IstioDistributions: ms})
 		require.NoError(t, err)
-		require.False(t, ok)
+		require.Equal(t, Issues{EOL: true}, issues)
 		require.Contains(t, msg, "The minor version 1.10-tetratefips is no longer supported by getmesh.")
 		require.Contains(t, msg, "getmesh list")
 		t.Log(msg)
@@ -401,21 +535,21 @@ func Test_getLatestPatchInManifestMsg(t *testing.T) {
 			{Version: "1.7.20", Flavor: "tetratefips", FlavorVersion: 1},
 		}
 
-		msg, ok, err := getLatestPatchInManifestMsg(&api.IstioDistribution{
+		msg, issues, err := latestPatchMsg(&api.IstioDistribution{
 			Version: "1.8.10",
 			Flavor:  "tetrate", FlavorVersion: 10,
 		}, &api.Manifest{IstioDistributions: ms})
 		require.NoError(t, err)
-		require.True(t, ok)
+		require.Equal(t, Issues{}, issues)
 		require.Contains(t, msg, "- 1.8.10-tetrate-v10 is the latest version in 1.8-tetrate")
 		t.Log(msg)
 
-		msg, ok, err = getLatestPatchInManifestMsg(&api.IstioDistribution{
+		msg, issues, err = latestPatchMsg(&api.IstioDistribution{
 			Version: "1.7.20",
 			Flavor:  "tetratefips", FlavorVersion: 1,
 		}, &api.Manifest{IstioDistributions: ms})
 		require.NoError(t, err)
-		require.True(t, ok)
+		require.Equal(t, Issues{}, issues)
 		require.Contains(t, msg, "- 1.7.20-tetratefips-v1 is the latest version in 1.7-tetratefips")
 		t.Log(msg)
 	})
@@ -436,9 +570,9 @@ func Test_getLatestPatchInManifestMsg(t *testing.T) {
 			{Version: "1.8.10", Flavor: "tetrate", FlavorVersion: 5},
 		} {
 			t.Run(fmt.Sprintf("%d-th_1.8", i), func(t *testing.T) {
-				actual, ok, err := getLatestPatchInManifestMsg(v, &api.Manifest{IstioDistributions: ms})
+				actual, issues, err := latestPatchMsg(v, &api.Manifest{IstioDistributions: ms})
 				require.NoError(t, err)
-				require.False(t, ok)
+				require.Equal(t, Issues{Patch: true}, issues)
 				require.Contains(t, actual, "all 1.8-tetrate versions -> 1.8.10-tetrate-v10")
 				t.Log(actual)
 			})
@@ -450,9 +584,9 @@ func Test_getLatestPatchInManifestMsg(t *testing.T) {
 			{Version: "1.7.20", Flavor: "tetratefips", FlavorVersion: 1},
 		} {
 			t.Run(fmt.Sprintf("%d-th_1.8", i), func(t *testing.T) {
-				actual, ok, err := getLatestPatchInManifestMsg(v, &api.Manifest{IstioDistributions: ms})
+				actual, issues, err := latestPatchMsg(v, &api.Manifest{IstioDistributions: ms})
 				require.NoError(t, err)
-				require.False(t, ok)
+				require.Equal(t, Issues{Patch: true, Security: true}, issues)
 				require.Contains(t, actual, "which includes **security upgrades**. We strongly recommend upgrading all 1.7-tetratefips versions -> 1.7.20-tetratefips-v30")
 				t.Log(actual)
 			})
@@ -460,18 +594,27 @@ func Test_getLatestPatchInManifestMsg(t *testing.T) {
 	})
 }
 
+// render the status of the given lowest patch as printgetmeshCheck does
+func latestPatchMsg(lowest *api.IstioDistribution, manifest *api.Manifest) (string, Issues, error) {
+	m, err := newMinorVersionStatus(lowest, manifest, time.Now())
+	if err != nil {
+		return "", Issues{}, err
+	}
+	return getLatestPatchMsg(m), m.Issues(), nil
+}
+
 func Test_getMultipleMinorVersionRunningMsg(t *testing.T) {
 	for _, c := range []struct {
 		t   string
-		mvs map[string]*api.IstioDistribution
+		mvs []string
 		exp string
 	}{
 		{
-			t: "control plane", mvs: map[string]*api.IstioDistribution{"1.6-tetrate": {}, "1.7-tetratefips": {}},
+			t: "control plane", mvs: []string{"1.6-tetrate", "1.7-tetratefips"},
 			exp: "- Your control plane running in multiple minor versions: 1.6-tetrate, 1.7-tetratefips\n",
 		},
 		{
-			t: "data plane", mvs: map[string]*api.IstioDistribution{"1.6-tetrate": {}, "1.7-tetrate": {}, "1.9-tetratefips": {}, "2.1-tetratefips": {}},
+			t: "data plane", mvs: []string{"1.6-tetrate", "1.7-tetrate", "1.9-tetratefips", "2.1-tetratefips"},
 			exp: "- Your data plane running in multiple minor versions: 1.6-tetrate, 1.7-tetrate, 1.9-tetratefips, 2.1-tetratefips\n",
 		},
 	} {
@@ -499,13 +642,11 @@ func Test_getControlPlaneVersions(t *testing.T) {
 			Version: "1.7.1",
 		}}}
 
-		buf := logger.ExecuteWithLock(func() {
-			_, err := getControlPlaneVersions(&in)
-			require.NoError(t, err)
-		})
-
-		require.Contains(t, buf.String(), "Please install distributions with tetrate flavor listed in")
-		t.Log(buf.String())
+		actual, err := getControlPlaneVersions(&in)
+		require.NoError(t, err)
+		require.Equal(t, map[string]*api.IstioDistribution{
+			"1.7-istio": {Version: "1.7.1", Flavor: "istio"},
+		}, actual)
 	})
 
 	t.Run("ok", func(t *testing.T) {
@@ -578,13 +719,11 @@ func Test_getDataPlaneVersions(t *testing.T) {
 
 	t.Run("upstream version", func(t *testing.T) {
 		in := []istioversion.ProxyInfo{{IstioVersion: "1.7.1"}}
-		buf := logger.ExecuteWithLock(func() {
-			_, err := getDataPlaneVersions(&in)
-			require.NoError(t, err)
-		})
-
-		require.Contains(t, buf.String(), "Please install distributions with tetrate flavor listed in")
-		t.Log(buf.String())
+		actual, err := getDataPlaneVersions(&in)
+		require.NoError(t, err)
+		require.Equal(t, map[string]*api.IstioDistribution{
+			"1.7-istio": {Version: "1.7.1", Flavor: "istio"},
+		}, actual)
 	})
 
 	t.Run("ok", func(t *testing.T) {
@@ -602,10 +741,11 @@ func Test_getDataPlaneVersions(t *testing.T) {
 			},
 			{
 				in: []istioversion.ProxyInfo{
-					{IstioVersion: "1.7.3"}, // to be ignored
+					{IstioVersion: "1.7.3"},
 					{IstioVersion: "1.6.1-tetrate-v100"},
 				},
 				exp: map[string]*api.IstioDistribution{
+					"1.7-istio":   {Version: "1.7.3", Flavor: "istio"},
 					"1.6-tetrate": {Version: "1.6.1", Flavor: "tetrate", FlavorVersion: 100},
 				}},
 			{
@@ -0,0 +1,247 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	istioversion "istio.io/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tetratelabs/getmesh/api"
+)
+
+const (
+	istiodSelector          = "app=istiod"
+	istiodContainerName     = "discovery"
+	proxyContainerName      = "istio-proxy"
+	sidecarStatusAnnotation = "sidecar.istio.io/status"
+	gatewayLabel            = "istio"
+	revisionLabel           = "istio.io/rev"
+	defaultRevision         = "default"
+)
+
+// ErrUnknownImageVersion is returned by Collect when the version cannot be determined from the image tags,
+// e.g. "latest", a digest or a custom tag, in which case the versions should be collected by istioctl instead
+var ErrUnknownImageVersion = errors.New("cannot determine the version from the image")
+
+// Mesh is the versions of the mesh collected via the Kubernetes API
+type Mesh struct {
+	// in the same form as "istioctl version -o json"
+	Version       *istioversion.Version
+	ControlPlanes []ControlPlane
+	Proxies       []Proxy
+}
+
+// CollectMesh builds the versions of the mesh from the istiod deployments and the proxy images via the Kubernetes API.
+// nil is returned if no istiod is running, and ErrUnknownImageVersion if any of the image tags is not a version.
+// client is the version of the active istioctl, which is only used for the summary
+func CollectMesh(kubeCli kubernetes.Interface, client string) (*Mesh, error) {
+	cps, err := ControlPlanes(kubeCli)
+	if err != nil {
+		return nil, err
+	} else if len(cps) == 0 {
+		return nil, nil
+	}
+
+	proxies, err := Proxies(kubeCli)
+	if err != nil {
+		return nil, err
+	}
+
+	mesh := make(istioversion.MeshInfo, 0, len(cps))
+	for _, cp := range cps {
+		if !isVersion(cp.Version) {
+			return nil, fmt.Errorf("%w of istiod %s/%s", ErrUnknownImageVersion, cp.Namespace, cp.Name)
+		}
+		mesh = append(mesh, istioversion.ServerInfo{Component: "pilot", Info: istioversion.BuildInfo{Version: cp.Version}})
+	}
+
+	dataPlane := make([]istioversion.ProxyInfo, 0, len(proxies))
+	for _, p := range proxies {
+		if !isVersion(p.Version) {
+			return nil, fmt.Errorf("%w of the proxy in %s/%s", ErrUnknownImageVersion, p.Namespace, p.Pod)
+		}
+		// the ID is in the same form as istioctl, i.e. "${pod}.${namespace}"
+		dataPlane = append(dataPlane, istioversion.ProxyInfo{ID: p.Pod + "." + p.Namespace, IstioVersion: p.Version})
+	}
+	sort.Slice(dataPlane, func(i, j int) bool { return dataPlane[i].ID < dataPlane[j].ID })
+
+	return &Mesh{
+		Version: &istioversion.Version{
+			ClientVersion:    &istioversion.BuildInfo{Version: client},
+			MeshVersion:      &mesh,
+			DataPlaneVersion: &dataPlane,
+		},
+		ControlPlanes: cps,
+		Proxies:       proxies,
+	}, nil
+}
+
+// Collect returns the versions of CollectMesh in the same form as "istioctl version -o json"
+func Collect(kubeCli kubernetes.Interface, client string) (*istioversion.Version, error) {
+	m, err := CollectMesh(kubeCli, client)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return m.Version, nil
+}
+
+// ControlPlane is a running istiod deployment
+type ControlPlane struct {
+	Namespace, Name string
+	Revision        string
+	// the image tag, which is empty or not a version if the image is not tagged with the version
+	Version       string
+	ReadyReplicas int32
+}
+
+// ControlPlanes lists the istiod deployments which have ready replicas
+func ControlPlanes(kubeCli kubernetes.Interface) ([]ControlPlane, error) {
+	deployments, err := kubeCli.AppsV1().Deployments("").List(context.Background(), metav1.ListOptions{LabelSelector: istiodSelector})
+	if err != nil {
+		return nil, fmt.Errorf("error listing istiod deployments: %w", err)
+	}
+
+	var ret []ControlPlane
+	for _, d := range deployments.Items {
+		if d.Status.ReadyReplicas == 0 {
+			continue
+		}
+
+		c := findContainer(d.Spec.Template.Spec.Containers, istiodContainerName)
+		if c == nil && len(d.Spec.Template.Spec.Containers) > 0 {
+			c = &d.Spec.Template.Spec.Containers[0]
+		}
+		if c == nil {
+			continue
+		}
+
+		rev := d.Labels[revisionLabel]
+		if rev == "" {
+			rev = defaultRevision
+		}
+		ret = append(ret, ControlPlane{Namespace: d.Namespace, Name: d.Name, Revision: rev,
+			Version: versionFromImage(c.Image), ReadyReplicas: d.Status.ReadyReplicas})
+	}
+	return ret, nil
+}
+
+// Proxy is a running istio-proxy, either an injected sidecar or a gateway
+type Proxy struct {
+	Namespace, Pod string
+	// the app label, e.g. "istio-ingressgateway", or the pod name if absent
+	Workload string
+	Revision string
+	// the image tag, which is empty or not a version if the image is not tagged with the version
+	Version string
+	Gateway bool
+}
+
+// Proxies lists the running sidecars and gateways
+func Proxies(kubeCli kubernetes.Interface) ([]Proxy, error) {
+	pods, err := kubeCli.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+
+	var ret []Proxy
+	for _, p := range pods.Items {
+		if p.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		_, injected := p.Annotations[sidecarStatusAnnotation]
+		c := findContainer(p.Spec.Containers, proxyContainerName)
+		if c == nil {
+			if !injected {
+				continue
+			}
+			// the sidecar may run as an init container
+			if c = findContainer(p.Spec.InitContainers, proxyContainerName); c == nil {
+				continue
+			}
+		}
+
+		proxy := Proxy{Namespace: p.Namespace, Pod: p.Name, Workload: p.Labels["app"], Revision: p.Labels[revisionLabel],
+			Version: versionFromImage(c.Image), Gateway: isGateway(&p, c)}
+		if proxy.Workload == "" {
+			proxy.Workload = p.Name
+		}
+		if proxy.Revision == "" {
+			proxy.Revision = defaultRevision
+		}
+		ret = append(ret, proxy)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Namespace < ret[j].Namespace || (ret[i].Namespace == ret[j].Namespace && ret[i].Pod < ret[j].Pod)
+	})
+	return ret, nil
+}
+
+// gateways run the proxy in the router mode, i.e. "istio-proxy proxy router", while sidecars in the sidecar mode.
+// Without the args, e.g. when given by the injection template, the gateways are told by their "istio" label such as "ingressgateway".
+// Note that the pods without the injection annotation are not necessarily gateways since the sidecars can be injected manually
+func isGateway(p *corev1.Pod, c *corev1.Container) bool {
+	for _, a := range c.Args {
+		switch a {
+		case "router":
+			return true
+		case "sidecar":
+			return false
+		}
+	}
+	return strings.HasSuffix(p.Labels[gatewayLabel], "gateway")
+}
+
+func findContainer(cs []corev1.Container, name string) *corev1.Container {
+	for i := range cs {
+		if cs[i].Name == name {
+			return &cs[i]
+		}
+	}
+	return nil
+}
+
+// the image variants which are appended to the tags, e.g. "1.8.3-tetrate-v0-distroless"
+var imageVariants = []string{"-distroless", "-debug"}
+
+// versionFromImage extracts the version from the image tag, e.g. "containers.istio.tetratelabs.com/proxyv2:1.8.3-tetrate-v0".
+// Empty is returned if the image is not tagged, e.g. pinned by the digest
+func versionFromImage(image string) string {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") || strings.Contains(image, "@") {
+		return ""
+	}
+
+	tag := image[i+1:]
+	for _, v := range imageVariants {
+		tag = strings.TrimSuffix(tag, v)
+	}
+	return tag
+}
+
+// isVersion returns true if the tag is the version of a distribution, e.g. "1.8.3" or "1.8.3-tetrate-v0"
+func isVersion(tag string) bool {
+	_, err := api.IstioDistributionFromString(tag)
+	return err == nil
+}
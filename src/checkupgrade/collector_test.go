@@ -0,0 +1,161 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCollect(t *testing.T) {
+	istiod := func(name, image string, ready int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system", Labels: map[string]string{"app": "istiod"}},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "discovery", Image: image}},
+			}}},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: ready},
+		}
+	}
+	pod := func(namespace, name string, annotations map[string]string, phase corev1.PodPhase, containers ...corev1.Container) *corev1.Pod {
+		var labels map[string]string
+		if annotations == nil {
+			labels = map[string]string{"istio": "ingressgateway"}
+		}
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: annotations},
+			Spec:       corev1.PodSpec{Containers: containers},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	injected := map[string]string{"sidecar.istio.io/status": `{"containers":["istio-proxy"]}`}
+	app := corev1.Container{Name: "app", Image: "docker.io/library/nginx:1.19"}
+	proxy := func(tag string) corev1.Container {
+		return corev1.Container{Name: "istio-proxy", Image: "containers.istio.tetratelabs.com/proxyv2:" + tag}
+	}
+
+	t.Run("not installed", func(t *testing.T) {
+		actual, err := Collect(fake.NewSimpleClientset(
+			istiod("istiod", "containers.istio.tetratelabs.com/pilot:1.8.3-tetrate-v0", 0),
+		), "1.8.3-tetrate-v0")
+		require.NoError(t, err)
+		require.Nil(t, actual)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		actual, err := Collect(fake.NewSimpleClientset(
+			istiod("istiod", "containers.istio.tetratelabs.com/pilot:1.8.1-tetrate-v0", 1),
+			istiod("istiod-1-8-3", "containers.istio.tetratelabs.com/pilot:1.8.3-tetrate-v0-distroless", 2),
+			pod("default", "reviews", injected, corev1.PodRunning, app, proxy("1.8.1-tetrate-v0")),
+			pod("default", "ratings", injected, corev1.PodRunning, app, proxy("1.8.3-tetrate-v0-distroless")),
+			pod("default", "pending", injected, corev1.PodPending, app, proxy("1.8.1-tetrate-v0")),
+			pod("default", "plain", nil, corev1.PodRunning, app),
+			pod("istio-system", "istio-ingressgateway", nil, corev1.PodRunning, proxy("1.8.3-tetrate-v0")),
+		), "1.9.0-tetrate-v0")
+		require.NoError(t, err)
+		require.Equal(t, &istioversion.Version{
+			ClientVersion: &istioversion.BuildInfo{Version: "1.9.0-tetrate-v0"},
+			MeshVersion: &istioversion.MeshInfo{
+				{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}},
+				{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.3-tetrate-v0"}},
+			},
+			DataPlaneVersion: &[]istioversion.ProxyInfo{
+				{ID: "istio-ingressgateway.istio-system", IstioVersion: "1.8.3-tetrate-v0"},
+				{ID: "ratings.default", IstioVersion: "1.8.3-tetrate-v0"},
+				{ID: "reviews.default", IstioVersion: "1.8.1-tetrate-v0"},
+			},
+		}, actual)
+	})
+
+	t.Run("mesh", func(t *testing.T) {
+		actual, err := CollectMesh(fake.NewSimpleClientset(
+			istiod("istiod", "containers.istio.tetratelabs.com/pilot:1.8.3-tetrate-v0", 1),
+			pod("default", "reviews", injected, corev1.PodRunning, app, proxy("1.8.3-tetrate-v0")),
+		), "1.9.0-tetrate-v0")
+		require.NoError(t, err)
+		require.Equal(t, []ControlPlane{{Namespace: "istio-system", Name: "istiod", Revision: "default",
+			Version: "1.8.3-tetrate-v0", ReadyReplicas: 1}}, actual.ControlPlanes)
+		require.Equal(t, []Proxy{{Namespace: "default", Pod: "reviews", Workload: "reviews", Revision: "default",
+			Version: "1.8.3-tetrate-v0"}}, actual.Proxies)
+		require.Len(t, *actual.Version.DataPlaneVersion, 1)
+	})
+
+	// the versions should be collected by istioctl instead
+	for _, c := range []struct {
+		name    string
+		objects []runtime.Object
+	}{
+		{name: "istiod digest", objects: []runtime.Object{
+			istiod("istiod", "containers.istio.tetratelabs.com/pilot@sha256:0123456789abcdef", 1)}},
+		{name: "istiod latest", objects: []runtime.Object{
+			istiod("istiod", "containers.istio.tetratelabs.com/pilot:latest", 1)}},
+		{name: "proxy custom tag", objects: []runtime.Object{
+			istiod("istiod", "containers.istio.tetratelabs.com/pilot:1.8.3-tetrate-v0", 1),
+			pod("default", "reviews", injected, corev1.PodRunning, app, proxy("my-build")),
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Collect(fake.NewSimpleClientset(c.objects...), "1.8.3-tetrate-v0")
+			require.True(t, errors.Is(err, ErrUnknownImageVersion), err)
+		})
+	}
+}
+
+func TestVersionFromImage(t *testing.T) {
+	for _, c := range []struct {
+		image, expected string
+	}{
+		{image: "containers.istio.tetratelabs.com/proxyv2:1.8.3-tetrate-v0", expected: "1.8.3-tetrate-v0"},
+		{image: "localhost:5000/istio/proxyv2:1.8.3", expected: "1.8.3"},
+		{image: "docker.io/istio/proxyv2:1.9.0-distroless", expected: "1.9.0"},
+		{image: "docker.io/istio/proxyv2:latest", expected: "latest"},
+		{image: "localhost:5000/istio/proxyv2"},
+		{image: "istio/proxyv2@sha256:0123456789abcdef"},
+	} {
+		require.Equal(t, c.expected, versionFromImage(c.image), c.image)
+	}
+}
+
+func TestControlPlanes(t *testing.T) {
+	istiod := func(name string, labels map[string]string, ready int32) *appsv1.Deployment {
+		labels["app"] = "istiod"
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system", Labels: labels},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "discovery", Image: "containers.istio.tetratelabs.com/pilot:1.8.3-tetrate-v0"}},
+			}}},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: ready},
+		}
+	}
+
+	actual, err := ControlPlanes(fake.NewSimpleClientset(
+		istiod("istiod", map[string]string{}, 2),
+		istiod("istiod-canary", map[string]string{"istio.io/rev": "canary"}, 1),
+		istiod("istiod-stopped", map[string]string{"istio.io/rev": "stopped"}, 0),
+	))
+	require.NoError(t, err)
+	require.Equal(t, []ControlPlane{
+		{Namespace: "istio-system", Name: "istiod", Revision: "default", Version: "1.8.3-tetrate-v0", ReadyReplicas: 2},
+		{Namespace: "istio-system", Name: "istiod-canary", Revision: "canary", Version: "1.8.3-tetrate-v0", ReadyReplicas: 1},
+	}, actual)
+}
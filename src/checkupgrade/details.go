@@ -0,0 +1,161 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	istioversion "istio.io/pkg/version"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+// OwnerResolver returns the kind and the name of the workload which owns the pod
+type OwnerResolver func(namespace, pod string) (kind, name string, err error)
+
+// OutdatedProxy is a proxy not running the latest patch of its minor version
+type OutdatedProxy struct {
+	Namespace, Pod       string
+	OwnerKind, OwnerName string
+	Current              string
+	// empty if the minor version is no longer supported
+	Recommended string
+}
+
+// OutdatedProxies lists the proxies which are not running the latest patch in the manifest
+func OutdatedProxies(proxies []istioversion.ProxyInfo, manifest *api.Manifest, resolve OwnerResolver) ([]OutdatedProxy, error) {
+	var ret []OutdatedProxy
+	for _, p := range proxies {
+		current, err := api.IstioDistributionFromString(p.IstioVersion)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing dataplane's version %s: %v", p.IstioVersion, err)
+		}
+
+		latest, _, err := api.GetLatestDistribution(current, manifest)
+		if err != nil {
+			return nil, err
+		} else if latest != nil && latest.Equal(current) {
+			continue
+		}
+
+		// the ID is in the form of "${pod}.${namespace}"
+		i := strings.LastIndex(p.ID, ".")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid proxy ID: %s", p.ID)
+		}
+		op := OutdatedProxy{Namespace: p.ID[i+1:], Pod: p.ID[:i], Current: p.IstioVersion}
+		if latest != nil {
+			op.Recommended = latest.ToString()
+		}
+
+		if op.OwnerKind, op.OwnerName, err = resolve(op.Namespace, op.Pod); err != nil {
+			return nil, fmt.Errorf("error resolving the owner of %s/%s: %w", op.Namespace, op.Pod, err)
+		}
+		ret = append(ret, op)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].ownerID() < ret[j].ownerID() || (ret[i].ownerID() == ret[j].ownerID() && ret[i].Pod < ret[j].Pod)
+	})
+	return ret, nil
+}
+
+func (p *OutdatedProxy) ownerID() string {
+	return fmt.Sprintf("%s %s/%s", p.OwnerKind, p.Namespace, p.OwnerName)
+}
+
+// PrintOutdatedProxies prints the outdated proxies grouped by the owner workloads
+func PrintOutdatedProxies(proxies []OutdatedProxy) {
+	logger.Infof("[Outdated proxies]\n")
+	if len(proxies) == 0 {
+		logger.Infof("- All the proxies are running the latest patch\n\n")
+		return
+	}
+
+	var prev string
+	for _, p := range proxies {
+		if id := p.ownerID(); id != prev {
+			recommended := p.Recommended
+			if recommended == "" {
+				recommended = "(unsupported minor version)"
+			}
+			logger.Infof("- %s: %s -> %s\n", id, p.Current, recommended)
+			prev = id
+		}
+		logger.Infof("    %s\n", p.Pod)
+	}
+	logger.Infof("\n")
+}
+
+// PrintRemediation prints the commands to restart the outdated workloads so that the proxies are re-injected
+func PrintRemediation(proxies []OutdatedProxy) {
+	logger.Infof("[Remediation]\n")
+	if len(proxies) == 0 {
+		logger.Infof("# nothing to do\n\n")
+		return
+	}
+
+	logger.Infof("# Make sure the control plane of the recommended version is running and injecting the namespaces, then run:\n")
+	seen := map[string]bool{}
+	for _, p := range proxies {
+		id := p.ownerID()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		switch p.OwnerKind {
+		case "Deployment", "StatefulSet", "DaemonSet":
+			logger.Infof("kubectl rollout restart %s/%s -n %s\n", strings.ToLower(p.OwnerKind), p.OwnerName, p.Namespace)
+		default:
+			logger.Infof("# %s %s/%s cannot be restarted by kubectl rollout restart. Please recreate it manually\n",
+				p.OwnerKind, p.Namespace, p.OwnerName)
+		}
+	}
+	logger.Infof("\n")
+}
+
+// NewOwnerResolver creates the OwnerResolver which follows the controller references of pods and ReplicaSets
+func NewOwnerResolver(kubeCli kubernetes.Interface) OwnerResolver {
+	return func(namespace, pod string) (string, string, error) {
+		ctx := context.Background()
+		p, err := kubeCli.CoreV1().Pods(namespace).Get(ctx, pod, metav1.GetOptions{})
+		if err != nil {
+			return "", "", err
+		}
+
+		owner := metav1.GetControllerOf(p)
+		if owner == nil {
+			return "Pod", pod, nil
+		} else if owner.Kind != "ReplicaSet" {
+			return owner.Kind, owner.Name, nil
+		}
+
+		rs, err := kubeCli.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", "", err
+		}
+		if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil {
+			return rsOwner.Kind, rsOwner.Name, nil
+		}
+		return owner.Kind, owner.Name, nil
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func TestOutdatedProxies(t *testing.T) {
+	controller := true
+	owned := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+	}
+
+	cs := fake.NewSimpleClientset(
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "reviews-v1-7f99cc4496", Namespace: "default",
+			OwnerReferences: owned("Deployment", "reviews-v1")}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "reviews-v1-7f99cc4496-4xd2z", Namespace: "default",
+			OwnerReferences: owned("ReplicaSet", "reviews-v1-7f99cc4496")}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "reviews-v1-7f99cc4496-zv5rw", Namespace: "default",
+			OwnerReferences: owned("ReplicaSet", "reviews-v1-7f99cc4496")}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "data",
+			OwnerReferences: owned("StatefulSet", "db")}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ratings", Namespace: "default"}},
+	)
+
+	ms := &api.Manifest{IstioDistributions: []*api.IstioDistribution{
+		{Version: "1.8.3", Flavor: "tetrate", FlavorVersion: 0},
+		{Version: "1.8.1", Flavor: "tetrate", FlavorVersion: 0},
+	}}
+
+	actual, err := OutdatedProxies([]istioversion.ProxyInfo{
+		{ID: "reviews-v1-7f99cc4496-zv5rw.default", IstioVersion: "1.8.1-tetrate-v0"},
+		{ID: "ratings.default", IstioVersion: "1.8.3-tetrate-v0"},
+		{ID: "db-0.data", IstioVersion: "1.7.4-tetrate-v0"},
+		{ID: "reviews-v1-7f99cc4496-4xd2z.default", IstioVersion: "1.8.1-tetrate-v0"},
+		{ID: "debug.default", IstioVersion: "1.8.1-tetrate-v0"},
+	}, ms, NewOwnerResolver(cs))
+	require.NoError(t, err)
+	require.Equal(t, []OutdatedProxy{
+		{Namespace: "default", Pod: "reviews-v1-7f99cc4496-4xd2z", OwnerKind: "Deployment", OwnerName: "reviews-v1",
+			Current: "1.8.1-tetrate-v0", Recommended: "1.8.3-tetrate-v0"},
+		{Namespace: "default", Pod: "reviews-v1-7f99cc4496-zv5rw", OwnerKind: "Deployment", OwnerName: "reviews-v1",
+			Current: "1.8.1-tetrate-v0", Recommended: "1.8.3-tetrate-v0"},
+		{Namespace: "default", Pod: "debug", OwnerKind: "Pod", OwnerName: "debug",
+			Current: "1.8.1-tetrate-v0", Recommended: "1.8.3-tetrate-v0"},
+		{Namespace: "data", Pod: "db-0", OwnerKind: "StatefulSet", OwnerName: "db", Current: "1.7.4-tetrate-v0"},
+	}, actual)
+
+	buf := logger.ExecuteWithLock(func() {
+		PrintOutdatedProxies(actual)
+		PrintRemediation(actual)
+	})
+	require.Equal(t, `[Outdated proxies]
+- Deployment default/reviews-v1: 1.8.1-tetrate-v0 -> 1.8.3-tetrate-v0
+    reviews-v1-7f99cc4496-4xd2z
+    reviews-v1-7f99cc4496-zv5rw
+- Pod default/debug: 1.8.1-tetrate-v0 -> 1.8.3-tetrate-v0
+    debug
+- StatefulSet data/db: 1.7.4-tetrate-v0 -> (unsupported minor version)
+    db-0
+
+[Remediation]
+# Make sure the control plane of the recommended version is running and injecting the namespaces, then run:
+kubectl rollout restart deployment/reviews-v1 -n default
+# Pod default/debug cannot be restarted by kubectl rollout restart. Please recreate it manually
+kubectl rollout restart statefulset/db -n data
+
+`, buf.String())
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+// ClusterReport is the result of the checks against a cluster
+type ClusterReport struct {
+	Context string
+	// istioctl used for the cluster
+	Istioctl     string
+	ControlPlane []string
+	// {version -> number of proxies}
+	DataPlane map[string]int
+	// recommended upgrades in the form of "${minor version} -> ${latest patch}"
+	Recommendations []string
+	// minor versions no longer supported
+	Unsupported []string
+	Issues      Issues
+	// no Istio pod is running in the cluster
+	NotInstalled bool
+	Err          error
+}
+
+// Summarize runs the same checks as IstioVersion without printing, and returns the report
+func Summarize(iv istioversion.Version, manifest *api.Manifest) (*ClusterReport, error) {
+	ret := &ClusterReport{DataPlane: map[string]int{}}
+	if iv.ClientVersion != nil {
+		ret.Istioctl = iv.ClientVersion.Version
+	}
+	if dv := iv.DataPlaneVersion; dv != nil {
+		for _, p := range *dv {
+			ret.DataPlane[p.IstioVersion]++
+		}
+	}
+	if mv := iv.MeshVersion; mv != nil {
+		seen := map[string]bool{}
+		for _, m := range *mv {
+			if !seen[m.Info.Version] {
+				seen[m.Info.Version] = true
+				ret.ControlPlane = append(ret.ControlPlane, m.Info.Version)
+			}
+		}
+		sort.Strings(ret.ControlPlane)
+	}
+
+	analysis, err := Analyze(iv, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.Issues = analysis.Issues()
+	for _, m := range analysis.MinorVersions {
+		if m.Latest == nil || m.PastEOL {
+			ret.Unsupported = append(ret.Unsupported, m.Group)
+		} else if !m.Latest.Equal(m.Lowest) {
+			ret.Recommendations = append(ret.Recommendations, fmt.Sprintf("%s -> %s", m.Group, m.Latest.ToString()))
+		}
+	}
+	return ret, nil
+}
+
+// PrintFleetReport prints the reports of the clusters in a table
+func PrintFleetReport(reports []*ClusterReport) {
+	data := make([][]string, 0, len(reports))
+	for _, r := range reports {
+		if r.Err != nil {
+			data = append(data, []string{r.Context, "-", "-", "-", "-", "-", fmt.Sprintf("ERROR: %v", r.Err)})
+			continue
+		}
+
+		dp := make([]string, 0, len(r.DataPlane))
+		for v, n := range r.DataPlane {
+			dp = append(dp, fmt.Sprintf("%s (%d)", v, n))
+		}
+		sort.Strings(dp)
+
+		recommended := append([]string{}, r.Recommendations...)
+		for _, u := range r.Unsupported {
+			recommended = append(recommended, u+" (unsupported)")
+		}
+
+		security, status := "-", "OK"
+		if r.Issues.Security {
+			security = "SECURITY UPGRADE"
+		}
+		if r.Issues.Any() {
+			status = "ISSUE FOUND"
+		} else if r.NotInstalled {
+			status = "NOT INSTALLED"
+		}
+		data = append(data, []string{r.Context, r.Istioctl, orDash(r.ControlPlane), orDash(dp), orDash(recommended), security, status})
+	}
+
+	table := tablewriter.NewWriter(logger.GetWriter())
+	table.SetHeader([]string{"CONTEXT", "ISTIOCTL", "CONTROL PLANE", "DATA PLANE", "RECOMMENDED", "SECURITY", "STATUS"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t") // pad with tabs
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func orDash(in []string) string {
+	if len(in) == 0 {
+		return "-"
+	}
+	return strings.Join(in, ", ")
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func TestSummarize(t *testing.T) {
+	ms := &api.Manifest{IstioDistributions: []*api.IstioDistribution{
+		{Version: "1.8.3", Flavor: "tetrate", FlavorVersion: 0, IsSecurityPatch: true},
+		{Version: "1.8.1", Flavor: "tetrate", FlavorVersion: 0},
+	}}
+
+	t.Run("up to date", func(t *testing.T) {
+		actual, err := Summarize(istioversion.Version{
+			ClientVersion: &istioversion.BuildInfo{Version: "1.8.3-tetrate-v0"},
+			MeshVersion: &istioversion.MeshInfo{
+				istioversion.ServerInfo{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.3-tetrate-v0"}},
+			},
+			DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.8.3-tetrate-v0"}, {IstioVersion: "1.8.3-tetrate-v0"}},
+		}, ms)
+		require.NoError(t, err)
+		require.Equal(t, &ClusterReport{
+			Istioctl:     "1.8.3-tetrate-v0",
+			ControlPlane: []string{"1.8.3-tetrate-v0"},
+			DataPlane:    map[string]int{"1.8.3-tetrate-v0": 2},
+		}, actual)
+	})
+
+	t.Run("outdated", func(t *testing.T) {
+		actual, err := Summarize(istioversion.Version{
+			ClientVersion: &istioversion.BuildInfo{Version: "1.8.3-tetrate-v0"},
+			MeshVersion: &istioversion.MeshInfo{
+				istioversion.ServerInfo{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}},
+				istioversion.ServerInfo{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}},
+			},
+			DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.8.1-tetrate-v0"}, {IstioVersion: "1.7.4-tetrate-v0"}},
+		}, ms)
+		require.NoError(t, err)
+		require.Equal(t, &ClusterReport{
+			Istioctl:        "1.8.3-tetrate-v0",
+			ControlPlane:    []string{"1.8.1-tetrate-v0"},
+			DataPlane:       map[string]int{"1.8.1-tetrate-v0": 1, "1.7.4-tetrate-v0": 1},
+			Recommendations: []string{"1.8-tetrate -> 1.8.3-tetrate-v0"},
+			Unsupported:     []string{"1.7-tetrate"},
+			Issues:          Issues{Patch: true, MultiMinor: true, EOL: true, Security: true},
+		}, actual)
+	})
+}
+
+func TestPrintFleetReport(t *testing.T) {
+	buf := logger.ExecuteWithLock(func() {
+		PrintFleetReport([]*ClusterReport{
+			{Context: "dev", Istioctl: "1.8.3-tetrate-v0", ControlPlane: []string{"1.8.3-tetrate-v0"},
+				DataPlane: map[string]int{"1.8.3-tetrate-v0": 2}},
+			{Context: "prod", Istioctl: "1.8.3-tetrate-v0", ControlPlane: []string{"1.8.1-tetrate-v0"},
+				DataPlane:       map[string]int{"1.8.1-tetrate-v0": 3, "1.7.4-tetrate-v0": 1},
+				Recommendations: []string{"1.8-tetrate -> 1.8.3-tetrate-v0"}, Unsupported: []string{"1.7-tetrate"},
+				Issues: Issues{Patch: true, EOL: true, Security: true}},
+			{Context: "empty", Istioctl: "1.8.3-tetrate-v0", NotInstalled: true},
+			{Context: "unreachable", Err: errors.New("connection refused")},
+		})
+	})
+
+	var actual [][]string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var row []string
+		for _, cell := range strings.Split(strings.TrimRight(line, "\t"), "\t") {
+			row = append(row, strings.TrimSpace(cell))
+		}
+		actual = append(actual, row)
+	}
+	require.Equal(t, [][]string{
+		{"CONTEXT", "ISTIOCTL", "CONTROL PLANE", "DATA PLANE", "RECOMMENDED", "SECURITY", "STATUS"},
+		{"dev", "1.8.3-tetrate-v0", "1.8.3-tetrate-v0", "1.8.3-tetrate-v0 (2)", "-", "-", "OK"},
+		{"prod", "1.8.3-tetrate-v0", "1.8.1-tetrate-v0", "1.7.4-tetrate-v0 (1), 1.8.1-tetrate-v0 (3)",
+			"1.8-tetrate -> 1.8.3-tetrate-v0, 1.7-tetrate (unsupported)", "SECURITY UPGRADE", "ISSUE FOUND"},
+		{"empty", "1.8.3-tetrate-v0", "-", "-", "-", "-", "NOT INSTALLED"},
+		{"unreachable", "-", "-", "-", "-", "-", "ERROR: connection refused"},
+	}, actual)
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+// Category is the kind of the issues found by the checks
+type Category string
+
+const (
+	// the minor versions are reaching the end of life within the warning period
+	CategoryNearEOL Category = "near-eol"
+	// newer patches are available in the minor versions
+	CategoryPatch Category = "patch"
+	// multiple minor versions are running in the data plane or the control plane
+	CategoryMultiMinor Category = "multi-minor"
+	// gateways do not run the version of the control planes of their revisions
+	CategoryGatewaySkew Category = "gateway-skew"
+	// the minor versions are no longer supported
+	CategoryEOL Category = "eol"
+	// the newer patches include security fixes
+	CategorySecurity Category = "security"
+)
+
+// Categories lists all the categories in the ascending order of the severity
+var Categories = []Category{CategoryNearEOL, CategoryPatch, CategoryMultiMinor, CategoryGatewaySkew, CategoryEOL, CategorySecurity}
+
+// Issues holds the categories of the issues found by the checks
+type Issues struct {
+	NearEOL, Patch, MultiMinor, GatewaySkew, EOL, Security bool
+}
+
+// Any returns true if any issue is found
+func (i Issues) Any() bool {
+	return i != Issues{}
+}
+
+// Has returns true if the issue in the category is found
+func (i Issues) Has(c Category) bool {
+	switch c {
+	case CategoryNearEOL:
+		return i.NearEOL
+	case CategoryPatch:
+		return i.Patch
+	case CategoryMultiMinor:
+		return i.MultiMinor
+	case CategoryGatewaySkew:
+		return i.GatewaySkew
+	case CategoryEOL:
+		return i.EOL
+	case CategorySecurity:
+		return i.Security
+	}
+	return false
+}
+
+// Merge returns the union of the issues
+func (i Issues) Merge(j Issues) Issues {
+	return Issues{
+		NearEOL:     i.NearEOL || j.NearEOL,
+		Patch:       i.Patch || j.Patch,
+		MultiMinor:  i.MultiMinor || j.MultiMinor,
+		GatewaySkew: i.GatewaySkew || j.GatewaySkew,
+		EOL:         i.EOL || j.EOL,
+		Security:    i.Security || j.Security,
+	}
+}
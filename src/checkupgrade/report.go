@@ -0,0 +1,252 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	istioversion "istio.io/pkg/version"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// HealthReportAPIVersion is the version of the schema of HealthReport, which is bumped on incompatible changes
+	HealthReportAPIVersion = "getmesh.tetrate.io/v1alpha1"
+	HealthReportKind       = "MeshHealthReport"
+	// HealthReportName is the name of the ConfigMap to which the report is published
+	HealthReportName = "getmesh-health-report"
+	// HealthReportKey is the key of the report in the ConfigMap
+	HealthReportKey = "report.json"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "getmesh"
+)
+
+// the types of the conditions in HealthReport
+const (
+	ConditionSecurityPatchPending = "SecurityPatchPending"
+	ConditionPatchPending         = "PatchPending"
+	ConditionEndOfLife            = "EndOfLife"
+	ConditionNearEndOfLife        = "NearEndOfLife"
+	ConditionVersionSkew          = "VersionSkew"
+	ConditionControlPlaneMissing  = "ControlPlaneMissing"
+)
+
+// HealthReport is the result of check-upgrade published into the cluster
+type HealthReport struct {
+	APIVersion  string    `json:"apiVersion"`
+	Kind        string    `json:"kind"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// {version -> number of instances}
+	ControlPlane  map[string]int       `json:"controlPlane"`
+	DataPlane     map[string]int       `json:"dataPlane"`
+	MinorVersions []HealthMinorVersion `json:"minorVersions"`
+	Conditions    []HealthCondition    `json:"conditions"`
+}
+
+// HealthMinorVersion is the status of a minor version running in the mesh
+type HealthMinorVersion struct {
+	MinorVersion string `json:"minorVersion"`
+	Lowest       string `json:"lowest"`
+	// empty if the minor version is no longer supported
+	Latest   string `json:"latest,omitempty"`
+	Security bool   `json:"security"`
+	// the end of life date in the form of YYYY-MM-DD if known
+	EndOfLife string `json:"endOfLife,omitempty"`
+	// whether the end of life date is within the warning period
+	NearEndOfLife bool `json:"nearEndOfLife"`
+}
+
+// HealthCondition follows the conventions of the Kubernetes conditions
+type HealthCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason"`
+	Message            string    `json:"message"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// NewHealthReport builds the report from the versions, the analysis and the gateways skewed from their revisions
+func NewHealthReport(iv istioversion.Version, analysis *Analysis, skewedGateways []GatewayStatus, now time.Time) *HealthReport {
+	ret := &HealthReport{
+		APIVersion:    HealthReportAPIVersion,
+		Kind:          HealthReportKind,
+		GeneratedAt:   now.UTC(),
+		ControlPlane:  map[string]int{},
+		DataPlane:     map[string]int{},
+		MinorVersions: []HealthMinorVersion{},
+	}
+	if mv := iv.MeshVersion; mv != nil {
+		for _, m := range *mv {
+			ret.ControlPlane[m.Info.Version]++
+		}
+	}
+	if dv := iv.DataPlaneVersion; dv != nil {
+		for _, p := range *dv {
+			ret.DataPlane[p.IstioVersion]++
+		}
+	}
+
+	var security, patch, eol, nearEOL []string
+	for _, m := range analysis.MinorVersions {
+		mv := HealthMinorVersion{MinorVersion: m.Group, Lowest: m.Lowest.ToString(), Security: m.Security, NearEndOfLife: m.NearEOL}
+		if !m.EOL.IsZero() {
+			mv.EndOfLife = m.EOL.Format("2006-01-02")
+		}
+		if m.Latest == nil || m.PastEOL {
+			eol = append(eol, m.Group)
+		}
+		if m.NearEOL {
+			nearEOL = append(nearEOL, fmt.Sprintf("%s (%s)", m.Group, mv.EndOfLife))
+		}
+		if m.Latest != nil {
+			mv.Latest = m.Latest.ToString()
+			if !m.Latest.Equal(m.Lowest) {
+				patch = append(patch, fmt.Sprintf("%s -> %s", m.Group, mv.Latest))
+				if m.Security {
+					security = append(security, fmt.Sprintf("%s -> %s", m.Group, mv.Latest))
+				}
+			}
+		}
+		ret.MinorVersions = append(ret.MinorVersions, mv)
+	}
+
+	var running []string
+	for _, m := range analysis.MinorVersions {
+		running = append(running, m.Group)
+	}
+
+	ret.Conditions = []HealthCondition{
+		newCondition(ConditionSecurityPatchPending, security, "SecurityPatchAvailable",
+			"security patches are available: %s", "no security patch is pending"),
+		newCondition(ConditionPatchPending, patch, "PatchAvailable",
+			"newer patches are available: %s", "all the minor versions are running the latest patch"),
+		newCondition(ConditionEndOfLife, eol, "UnsupportedMinorVersion",
+			"minor versions no longer supported: %s", "all the minor versions are supported"),
+		newCondition(ConditionNearEndOfLife, nearEOL, "EndOfLifeApproaching",
+			"minor versions reaching the end of life: %s", "no minor version is reaching the end of life"),
+		versionSkewCondition(running, skewedGateways),
+		newCondition(ConditionControlPlaneMissing, controlPlaneMissing(ret.ControlPlane), "NoControlPlane",
+			"%s", "istiod is running"),
+	}
+	for i := range ret.Conditions {
+		ret.Conditions[i].LastTransitionTime = ret.GeneratedAt
+	}
+	return ret
+}
+
+// both the multiple minor versions and the gateways not running the version of their revisions are the skew
+func versionSkewCondition(running []string, skewedGateways []GatewayStatus) HealthCondition {
+	var msgs []string
+	reason := "MultipleMinorVersions"
+	multiMinor := len(minorVersions(running)) > 1
+	if multiMinor {
+		msgs = append(msgs, "multiple minor versions are running: "+strings.Join(running, ", "))
+	}
+	if len(skewedGateways) > 0 {
+		gws := make([]string, 0, len(skewedGateways))
+		for _, g := range skewedGateways {
+			gws = append(gws, fmt.Sprintf("%s (%s, revision %s)", g.ID(), g.Version, g.Revision))
+		}
+		sort.Strings(gws)
+		msgs = append(msgs, "gateways skewed from the control plane of their revisions: "+strings.Join(gws, ", "))
+		if !multiMinor {
+			reason = "GatewayRevisionSkew"
+		}
+	}
+
+	if len(msgs) == 0 {
+		return newCondition(ConditionVersionSkew, nil, "", "", "a single minor version is running and the gateways match their revisions")
+	}
+	return HealthCondition{Type: ConditionVersionSkew, Status: string(metav1.ConditionTrue), Reason: reason, Message: strings.Join(msgs, "; ")}
+}
+
+func controlPlaneMissing(controlPlane map[string]int) []string {
+	if len(controlPlane) == 0 {
+		return []string{"no istiod is running in the cluster"}
+	}
+	return nil
+}
+
+func newCondition(t string, found []string, reason, trueMsg, falseMsg string) HealthCondition {
+	if len(found) == 0 {
+		return HealthCondition{Type: t, Status: string(metav1.ConditionFalse), Reason: "AsExpected", Message: falseMsg}
+	}
+	sort.Strings(found)
+	return HealthCondition{Type: t, Status: string(metav1.ConditionTrue), Reason: reason, Message: fmt.Sprintf(trueMsg, strings.Join(found, ", "))}
+}
+
+// PublishHealthReport writes the report into the ConfigMap in the namespace.
+// The transition times of the conditions are kept from the previous report unless the statuses change
+func PublishHealthReport(kubeCli kubernetes.Interface, namespace string, report *HealthReport) error {
+	ctx := context.Background()
+	cms := kubeCli.CoreV1().ConfigMaps(namespace)
+	cm, err := cms.Get(ctx, HealthReportName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = nil
+	} else if err != nil {
+		return fmt.Errorf("error getting the ConfigMap %s/%s: %w", namespace, HealthReportName, err)
+	}
+
+	if cm != nil {
+		var prev HealthReport
+		if err := json.Unmarshal([]byte(cm.Data[HealthReportKey]), &prev); err == nil && prev.APIVersion == report.APIVersion {
+			keepTransitionTimes(report, &prev)
+		}
+	}
+
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling the report: %w", err)
+	}
+
+	if cm == nil {
+		cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name: HealthReportName, Namespace: namespace, Labels: map[string]string{managedByLabel: managedByValue},
+		}}
+		cm.Data = map[string]string{HealthReportKey: string(raw)}
+		if _, err := cms.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating the ConfigMap %s/%s: %w", namespace, HealthReportName, err)
+		}
+		return nil
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[HealthReportKey] = string(raw)
+	if _, err := cms.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating the ConfigMap %s/%s: %w", namespace, HealthReportName, err)
+	}
+	return nil
+}
+
+func keepTransitionTimes(report, prev *HealthReport) {
+	for i, c := range report.Conditions {
+		for _, p := range prev.Conditions {
+			if p.Type == c.Type && p.Status == c.Status {
+				report.Conditions[i].LastTransitionTime = p.LastTransitionTime
+			}
+		}
+	}
+}
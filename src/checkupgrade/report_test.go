@@ -0,0 +1,160 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/tetratelabs/getmesh/api"
+)
+
+func TestNewHealthReport(t *testing.T) {
+	iv := istioversion.Version{
+		MeshVersion: &istioversion.MeshInfo{
+			{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}},
+			{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}},
+		},
+		DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.8.1-tetrate-v0"}, {IstioVersion: "1.7.4-tetrate-v0"}},
+	}
+	ms := &api.Manifest{IstioDistributions: []*api.IstioDistribution{
+		{Version: "1.8.3", Flavor: "tetrate", FlavorVersion: 0, IsSecurityPatch: true},
+		{Version: "1.8.1", Flavor: "tetrate", FlavorVersion: 0},
+	}}
+	analysis, err := Analyze(iv, ms)
+	require.NoError(t, err)
+
+	now := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	gateways := []GatewayStatus{{Namespace: "istio-system", Workload: "istio-ingressgateway", Revision: "default", Version: "1.7.4-tetrate-v0", Replicas: 1}}
+	actual := NewHealthReport(iv, analysis, gateways, now)
+	require.Equal(t, &HealthReport{
+		APIVersion:   HealthReportAPIVersion,
+		Kind:         HealthReportKind,
+		GeneratedAt:  now,
+		ControlPlane: map[string]int{"1.8.1-tetrate-v0": 2},
+		DataPlane:    map[string]int{"1.8.1-tetrate-v0": 1, "1.7.4-tetrate-v0": 1},
+		MinorVersions: []HealthMinorVersion{
+			{MinorVersion: "1.7-tetrate", Lowest: "1.7.4-tetrate-v0"},
+			{MinorVersion: "1.8-tetrate", Lowest: "1.8.1-tetrate-v0", Latest: "1.8.3-tetrate-v0", Security: true},
+		},
+		Conditions: []HealthCondition{
+			{Type: ConditionSecurityPatchPending, Status: "True", Reason: "SecurityPatchAvailable",
+				Message: "security patches are available: 1.8-tetrate -> 1.8.3-tetrate-v0", LastTransitionTime: now},
+			{Type: ConditionPatchPending, Status: "True", Reason: "PatchAvailable",
+				Message: "newer patches are available: 1.8-tetrate -> 1.8.3-tetrate-v0", LastTransitionTime: now},
+			{Type: ConditionEndOfLife, Status: "True", Reason: "UnsupportedMinorVersion",
+				Message: "minor versions no longer supported: 1.7-tetrate", LastTransitionTime: now},
+			{Type: ConditionNearEndOfLife, Status: "False", Reason: "AsExpected",
+				Message: "no minor version is reaching the end of life", LastTransitionTime: now},
+			{Type: ConditionVersionSkew, Status: "True", Reason: "MultipleMinorVersions",
+				Message: "multiple minor versions are running: 1.7-tetrate, 1.8-tetrate; " +
+					"gateways skewed from the control plane of their revisions: istio-system/istio-ingressgateway (1.7.4-tetrate-v0, revision default)",
+				LastTransitionTime: now},
+			{Type: ConditionControlPlaneMissing, Status: "False", Reason: "AsExpected", Message: "istiod is running", LastTransitionTime: now},
+		},
+	}, actual)
+
+	t.Run("gateway skew", func(t *testing.T) {
+		iv := istioversion.Version{
+			MeshVersion: &istioversion.MeshInfo{{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}}},
+		}
+		analysis, err := Analyze(iv, ms)
+		require.NoError(t, err)
+
+		c := NewHealthReport(iv, analysis, nil, now).Conditions[4]
+		require.Equal(t, "False", c.Status)
+		c = NewHealthReport(iv, analysis, gateways, now).Conditions[4]
+		require.Equal(t, "True", c.Status)
+		require.Equal(t, "GatewayRevisionSkew", c.Reason)
+	})
+
+	t.Run("multiple flavors in the same minor version", func(t *testing.T) {
+		iv := istioversion.Version{
+			MeshVersion:      &istioversion.MeshInfo{{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}}},
+			DataPlaneVersion: &[]istioversion.ProxyInfo{{IstioVersion: "1.8.1"}, {IstioVersion: "1.8.1-tetrate-v0"}},
+		}
+		analysis, err := Analyze(iv, ms)
+		require.NoError(t, err)
+		require.False(t, analysis.MultiMinor)
+
+		c := NewHealthReport(iv, analysis, nil, now).Conditions[4]
+		require.Equal(t, "False", c.Status)
+	})
+
+	t.Run("no control plane", func(t *testing.T) {
+		analysis, err := Analyze(istioversion.Version{}, ms)
+		require.NoError(t, err)
+
+		c := NewHealthReport(istioversion.Version{}, analysis, nil, now).Conditions[5]
+		require.Equal(t, HealthCondition{Type: ConditionControlPlaneMissing, Status: "True", Reason: "NoControlPlane",
+			Message: "no istiod is running in the cluster", LastTransitionTime: now}, c)
+	})
+}
+
+func TestPublishHealthReport(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	first := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	report := func(now time.Time, security bool) *HealthReport {
+		ms := &api.Manifest{IstioDistributions: []*api.IstioDistribution{
+			{Version: "1.8.3", Flavor: "tetrate", FlavorVersion: 0, IsSecurityPatch: security},
+			{Version: "1.8.1", Flavor: "tetrate", FlavorVersion: 0},
+		}}
+		iv := istioversion.Version{
+			MeshVersion: &istioversion.MeshInfo{{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}}},
+		}
+		analysis, err := Analyze(iv, ms)
+		require.NoError(t, err)
+		return NewHealthReport(iv, analysis, nil, now)
+	}
+	published := func() *HealthReport {
+		cm, err := cs.CoreV1().ConfigMaps("istio-system").Get(context.Background(), HealthReportName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "getmesh", cm.Labels["app.kubernetes.io/managed-by"])
+
+		var ret HealthReport
+		require.NoError(t, json.Unmarshal([]byte(cm.Data[HealthReportKey]), &ret))
+		return &ret
+	}
+	conditions := func(r *HealthReport) map[string]HealthCondition {
+		ret := map[string]HealthCondition{}
+		for _, c := range r.Conditions {
+			ret[c.Type] = c
+		}
+		return ret
+	}
+
+	// create
+	require.NoError(t, PublishHealthReport(cs, "istio-system", report(first, false)))
+	actual := conditions(published())
+	require.Equal(t, "False", actual[ConditionSecurityPatchPending].Status)
+	require.Equal(t, "True", actual[ConditionPatchPending].Status)
+
+	// update: the transition time is kept only for the unchanged condition
+	second := first.Add(time.Hour)
+	require.NoError(t, PublishHealthReport(cs, "istio-system", report(second, true)))
+	p := published()
+	require.Equal(t, second, p.GeneratedAt)
+	actual = conditions(p)
+	require.Equal(t, "True", actual[ConditionSecurityPatchPending].Status)
+	require.Equal(t, second, actual[ConditionSecurityPatchPending].LastTransitionTime)
+	require.Equal(t, first, actual[ConditionPatchPending].LastTransitionTime)
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+// RevisionStatus is the control plane of a revision and the proxies attached to it
+type RevisionStatus struct {
+	Revision string
+	// versions of the istiod deployments of the revision. Empty if no istiod of the revision is running
+	ControlPlane []string
+	// {version -> number of proxies}
+	Sidecars map[string]int
+	Gateways []GatewayStatus
+}
+
+// GatewayStatus is the pods of a gateway workload running the same version
+type GatewayStatus struct {
+	Namespace, Workload string
+	Revision, Version   string
+	Replicas            int
+}
+
+// ID returns the human readable identifier of the gateway
+func (g *GatewayStatus) ID() string {
+	return g.Namespace + "/" + g.Workload
+}
+
+// Skewed returns true if the gateway does not run the version of the control plane of its revision
+func (r *RevisionStatus) Skewed(g GatewayStatus) bool {
+	for _, v := range r.ControlPlane {
+		if v == g.Version {
+			return false
+		}
+	}
+	return true
+}
+
+// SkewedGateways lists the gateways which do not run the version of the control plane of their revisions
+func SkewedGateways(revs []RevisionStatus) []GatewayStatus {
+	var ret []GatewayStatus
+	for _, r := range revs {
+		for _, g := range r.Gateways {
+			if r.Skewed(g) {
+				ret = append(ret, g)
+			}
+		}
+	}
+	return ret
+}
+
+// Revisions groups the control planes and the proxies by the revisions
+func Revisions(cps []ControlPlane, proxies []Proxy) []RevisionStatus {
+	revs := map[string]*RevisionStatus{}
+	get := func(rev string) *RevisionStatus {
+		r, ok := revs[rev]
+		if !ok {
+			r = &RevisionStatus{Revision: rev, Sidecars: map[string]int{}}
+			revs[rev] = r
+		}
+		return r
+	}
+
+	for _, cp := range cps {
+		r := get(cp.Revision)
+		if !containsString(r.ControlPlane, cp.Version) {
+			r.ControlPlane = append(r.ControlPlane, cp.Version)
+		}
+	}
+
+	gateways := map[GatewayStatus]int{}
+	for _, p := range proxies {
+		r := get(p.Revision)
+		if !p.Gateway {
+			r.Sidecars[p.Version]++
+			continue
+		}
+		gateways[GatewayStatus{Namespace: p.Namespace, Workload: p.Workload, Revision: p.Revision, Version: p.Version}]++
+	}
+	for g, n := range gateways {
+		g.Replicas = n
+		r := get(g.Revision)
+		r.Gateways = append(r.Gateways, g)
+	}
+
+	ret := make([]RevisionStatus, 0, len(revs))
+	for _, r := range revs {
+		sort.Strings(r.ControlPlane)
+		sort.Slice(r.Gateways, func(i, j int) bool {
+			if r.Gateways[i].ID() != r.Gateways[j].ID() {
+				return r.Gateways[i].ID() < r.Gateways[j].ID()
+			}
+			return r.Gateways[i].Version < r.Gateways[j].Version
+		})
+		ret = append(ret, *r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Revision < ret[j].Revision })
+	return ret
+}
+
+// PrintRevisions prints the breakdown by the revisions followed by the version skews of the gateways
+func PrintRevisions(revs []RevisionStatus) {
+	logger.Infof("[Revisions]\n")
+	for _, r := range revs {
+		cp := "none"
+		if len(r.ControlPlane) > 0 {
+			cp = strings.Join(r.ControlPlane, ", ")
+		}
+		logger.Infof("- %s: control plane %s\n", r.Revision, cp)
+
+		if len(r.Sidecars) > 0 {
+			counts := make([]string, 0, len(r.Sidecars))
+			for v, n := range r.Sidecars {
+				counts = append(counts, fmt.Sprintf("%s (%d proxies)", v, n))
+			}
+			sort.Strings(counts)
+			logger.Infof("    sidecars: %s\n", strings.Join(counts, ", "))
+		}
+		for _, g := range r.Gateways {
+			logger.Infof("    gateway %s: %s (%d proxies)\n", g.ID(), g.Version, g.Replicas)
+		}
+	}
+	logger.Infof("\n")
+
+	logger.Infof("[Gateway Check]\n")
+	skewed := SkewedGateways(revs)
+	if len(skewed) == 0 {
+		logger.Infof("- All the gateways run the same version as the control planes of their revisions\n\n")
+		return
+	}
+
+	for _, r := range revs {
+		for _, g := range r.Gateways {
+			if !r.Skewed(g) {
+				continue
+			}
+			if len(r.ControlPlane) == 0 {
+				logger.Infof("- The gateway %s runs %s but no control plane of its revision %s is running. "+
+					"We recommend attaching the gateway to a running revision\n", g.ID(), g.Version, r.Revision)
+			} else {
+				logger.Infof("- The gateway %s runs %s while the control plane of its revision %s runs %s. "+
+					"We recommend restarting or upgrading the gateway to match the control plane\n",
+					g.ID(), g.Version, r.Revision, strings.Join(r.ControlPlane, ", "))
+			}
+		}
+	}
+	logger.Infof("\n")
+}
+
+func containsString(in []string, s string) bool {
+	for _, v := range in {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func TestProxies(t *testing.T) {
+	pod := func(name string, labels, annotations map[string]string, args ...string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system", Labels: labels, Annotations: annotations},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "istio-proxy", Image: "containers.istio.tetratelabs.com/proxyv2:1.8.3-tetrate-v0", Args: args},
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	injected := map[string]string{"sidecar.istio.io/status": "{}"}
+
+	actual, err := Proxies(fake.NewSimpleClientset(
+		pod("ingress-1", map[string]string{"app": "istio-ingressgateway"}, nil, "proxy", "router"),
+		// gateways without the args are told by the label
+		pod("egress-1", map[string]string{"app": "istio-egressgateway", "istio": "egressgateway", "istio.io/rev": "canary"}, nil),
+		// manually injected sidecars without the annotation are not gateways
+		pod("manual-1", map[string]string{"app": "manual"}, nil),
+		// gateways injected with the gateway template
+		pod("gw-1", map[string]string{"app": "gw", "istio.io/rev": "canary"}, injected, "proxy", "router"),
+		pod("app-1", map[string]string{"istio.io/rev": "canary"}, injected, "proxy", "sidecar"),
+	))
+	require.NoError(t, err)
+	require.Equal(t, []Proxy{
+		{Namespace: "istio-system", Pod: "app-1", Workload: "app-1", Revision: "canary", Version: "1.8.3-tetrate-v0"},
+		{Namespace: "istio-system", Pod: "egress-1", Workload: "istio-egressgateway", Revision: "canary", Version: "1.8.3-tetrate-v0", Gateway: true},
+		{Namespace: "istio-system", Pod: "gw-1", Workload: "gw", Revision: "canary", Version: "1.8.3-tetrate-v0", Gateway: true},
+		{Namespace: "istio-system", Pod: "ingress-1", Workload: "istio-ingressgateway", Revision: "default", Version: "1.8.3-tetrate-v0", Gateway: true},
+		{Namespace: "istio-system", Pod: "manual-1", Workload: "manual", Revision: "default", Version: "1.8.3-tetrate-v0"},
+	}, actual)
+}
+
+func TestRevisions(t *testing.T) {
+	revs := Revisions(
+		[]ControlPlane{
+			{Revision: "default", Version: "1.8.1-tetrate-v0"},
+			{Revision: "canary", Version: "1.8.3-tetrate-v0"},
+		},
+		[]Proxy{
+			{Namespace: "default", Pod: "a", Revision: "default", Version: "1.8.1-tetrate-v0"},
+			{Namespace: "default", Pod: "b", Revision: "canary", Version: "1.8.3-tetrate-v0"},
+			{Namespace: "default", Pod: "c", Revision: "canary", Version: "1.8.3-tetrate-v0"},
+			{Namespace: "istio-system", Pod: "in-1", Workload: "istio-ingressgateway", Revision: "canary", Version: "1.8.1-tetrate-v0", Gateway: true},
+			{Namespace: "istio-system", Pod: "in-2", Workload: "istio-ingressgateway", Revision: "canary", Version: "1.8.1-tetrate-v0", Gateway: true},
+			{Namespace: "istio-system", Pod: "eg-1", Workload: "istio-egressgateway", Revision: "default", Version: "1.8.1-tetrate-v0", Gateway: true},
+			{Namespace: "istio-system", Pod: "old-1", Workload: "old-gateway", Revision: "1-7-4", Version: "1.7.4-tetrate-v0", Gateway: true},
+		},
+	)
+	require.Equal(t, []RevisionStatus{
+		{Revision: "1-7-4", Sidecars: map[string]int{}, Gateways: []GatewayStatus{
+			{Namespace: "istio-system", Workload: "old-gateway", Revision: "1-7-4", Version: "1.7.4-tetrate-v0", Replicas: 1},
+		}},
+		{Revision: "canary", ControlPlane: []string{"1.8.3-tetrate-v0"}, Sidecars: map[string]int{"1.8.3-tetrate-v0": 2},
+			Gateways: []GatewayStatus{
+				{Namespace: "istio-system", Workload: "istio-ingressgateway", Revision: "canary", Version: "1.8.1-tetrate-v0", Replicas: 2},
+			}},
+		{Revision: "default", ControlPlane: []string{"1.8.1-tetrate-v0"}, Sidecars: map[string]int{"1.8.1-tetrate-v0": 1},
+			Gateways: []GatewayStatus{
+				{Namespace: "istio-system", Workload: "istio-egressgateway", Revision: "default", Version: "1.8.1-tetrate-v0", Replicas: 1},
+			}},
+	}, revs)
+
+	require.Equal(t, []GatewayStatus{
+		{Namespace: "istio-system", Workload: "old-gateway", Revision: "1-7-4", Version: "1.7.4-tetrate-v0", Replicas: 1},
+		{Namespace: "istio-system", Workload: "istio-ingressgateway", Revision: "canary", Version: "1.8.1-tetrate-v0", Replicas: 2},
+	}, SkewedGateways(revs))
+
+	buf := logger.ExecuteWithLock(func() {
+		PrintRevisions(revs)
+	})
+	require.Equal(t, `[Revisions]
+- 1-7-4: control plane none
+    gateway istio-system/old-gateway: 1.7.4-tetrate-v0 (1 proxies)
+- canary: control plane 1.8.3-tetrate-v0
+    sidecars: 1.8.3-tetrate-v0 (2 proxies)
+    gateway istio-system/istio-ingressgateway: 1.8.1-tetrate-v0 (2 proxies)
+- default: control plane 1.8.1-tetrate-v0
+    sidecars: 1.8.1-tetrate-v0 (1 proxies)
+    gateway istio-system/istio-egressgateway: 1.8.1-tetrate-v0 (1 proxies)
+
+[Gateway Check]
+- The gateway istio-system/old-gateway runs 1.7.4-tetrate-v0 but no control plane of its revision 1-7-4 is running. We recommend attaching the gateway to a running revision
+- The gateway istio-system/istio-ingressgateway runs 1.8.1-tetrate-v0 while the control plane of its revision canary runs 1.8.3-tetrate-v0. We recommend restarting or upgrading the gateway to match the control plane
+
+`, buf.String())
+}
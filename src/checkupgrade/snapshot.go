@@ -0,0 +1,336 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+const (
+	snapshotDirSuffix  = "snapshots"
+	snapshotTimeFormat = "20060102T150405Z"
+	snapshotExt        = ".json"
+
+	// SnapshotPrevious refers to the latest snapshot saved for the same context
+	SnapshotPrevious = "previous"
+)
+
+// Snapshot is the version state of a mesh at a point in time, saved by "check-upgrade --save"
+type Snapshot struct {
+	Timestamp time.Time `json:"timestamp"`
+	// the kubeconfig context of the mesh, empty when running in the cluster
+	Context string `json:"context"`
+	// {version -> number of instances}
+	ControlPlane  map[string]int       `json:"controlPlane"`
+	DataPlane     map[string]int       `json:"dataPlane"`
+	MinorVersions []HealthMinorVersion `json:"minorVersions"`
+	// {minor version -> the time when the security patch was first found pending}
+	SecurityPendingSince map[string]time.Time `json:"securityPendingSince"`
+}
+
+// NewSnapshot builds the snapshot from the versions and the analysis.
+// The times since when the security patches are pending are carried over from prev if given
+func NewSnapshot(context string, iv istioversion.Version, analysis *Analysis, now time.Time, prev *Snapshot) *Snapshot {
+	report := NewHealthReport(iv, analysis, nil, now)
+	ret := &Snapshot{
+		Timestamp:            report.GeneratedAt,
+		Context:              context,
+		ControlPlane:         report.ControlPlane,
+		DataPlane:            report.DataPlane,
+		MinorVersions:        report.MinorVersions,
+		SecurityPendingSince: map[string]time.Time{},
+	}
+	for _, m := range ret.MinorVersions {
+		if !m.securityPending() {
+			continue
+		}
+		since := ret.Timestamp
+		if prev != nil {
+			if t, ok := prev.SecurityPendingSince[m.MinorVersion]; ok {
+				since = t
+			}
+		}
+		ret.SecurityPendingSince[m.MinorVersion] = since
+	}
+	return ret
+}
+
+func (m *HealthMinorVersion) securityPending() bool {
+	return m.Security && m.Latest != "" && m.Latest != m.Lowest
+}
+
+// outdatedProxies counts the proxies not running the latest patch of their minor versions
+func (s *Snapshot) outdatedProxies() int {
+	// the latest is in the full form, e.g. "1.9.5-istio-v0", while the data plane is keyed by the running version, e.g. "1.9.5"
+	latest := map[string]*api.IstioDistribution{}
+	for _, m := range s.MinorVersions {
+		if d, err := api.IstioDistributionFromString(m.Latest); err == nil {
+			latest[m.MinorVersion] = d
+		}
+	}
+
+	var ret int
+	for v, n := range s.DataPlane {
+		d, err := api.IstioDistributionFromString(v)
+		if err != nil {
+			continue
+		}
+		g, err := d.Group()
+		if err != nil {
+			continue
+		}
+		if l, ok := latest[g]; !ok || !l.Equal(d) {
+			ret += n
+		}
+	}
+	return ret
+}
+
+// SnapshotDir returns the directory where the snapshots are saved
+func SnapshotDir(homedir string) string {
+	return filepath.Join(homedir, snapshotDirSuffix)
+}
+
+// SaveSnapshot writes the snapshot into the directory, and returns the path of the file
+func SaveSnapshot(dir string, s *Snapshot) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating %s: %w", dir, err)
+	}
+
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling the snapshot: %w", err)
+	}
+
+	p := filepath.Join(dir, s.Timestamp.UTC().Format(snapshotTimeFormat)+snapshotExt)
+	if err := ioutil.WriteFile(p, raw, 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", p, err)
+	}
+	return p, nil
+}
+
+// LoadSnapshot reads the snapshot given by the path of the file, or the name of the file in the directory with or without the extension
+func LoadSnapshot(dir, name string) (*Snapshot, error) {
+	p := name
+	if _, err := os.Stat(p); err != nil {
+		p = filepath.Join(dir, strings.TrimSuffix(name, snapshotExt)+snapshotExt)
+	}
+
+	raw, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the snapshot %s: %w", name, err)
+	}
+
+	var ret Snapshot
+	if err := json.Unmarshal(raw, &ret); err != nil {
+		return nil, fmt.Errorf("error parsing the snapshot %s: %w", p, err)
+	}
+	return &ret, nil
+}
+
+// LatestSnapshot returns the latest snapshot of the context in the directory, or nil if not found
+func LatestSnapshot(dir, context string) (*Snapshot, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == snapshotExt {
+			names = append(names, f.Name())
+		}
+	}
+	// the names are the timestamps so that the latest comes first
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	for _, name := range names {
+		s, err := LoadSnapshot(dir, filepath.Join(dir, name))
+		if err != nil {
+			// a broken file, e.g. written partially, must not block the snapshots of all the contexts
+			logger.Warnf("skipping the snapshot: %v\n", err)
+			continue
+		}
+		if s.Context == context {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+// SnapshotDiff is the changes between two snapshots
+type SnapshotDiff struct {
+	From, To time.Time
+	// {version -> [number in From, number in To]} of the versions whose numbers changed
+	ControlPlane, DataPlane map[string][2]int
+	// the versions which are running only in To, and only in From
+	NewVersions, RemovedVersions []string
+	// the number of the proxies not running the latest patch
+	OutdatedFrom, OutdatedTo int
+	// the security patches still pending in To
+	SecurityPending []PendingSecurityPatch
+	// the minor versions whose security patches were pending in From, and are not in To
+	SecurityResolved []string
+}
+
+// PendingSecurityPatch is a security patch not yet applied to a minor version
+type PendingSecurityPatch struct {
+	MinorVersion, Latest string
+	Since                time.Time
+}
+
+// CompareSnapshots returns the changes from the snapshot from to the snapshot to
+func CompareSnapshots(from, to *Snapshot) *SnapshotDiff {
+	ret := &SnapshotDiff{
+		From:         from.Timestamp,
+		To:           to.Timestamp,
+		ControlPlane: diffCounts(from.ControlPlane, to.ControlPlane),
+		DataPlane:    diffCounts(from.DataPlane, to.DataPlane),
+		OutdatedFrom: from.outdatedProxies(),
+		OutdatedTo:   to.outdatedProxies(),
+	}
+
+	running := func(s *Snapshot, v string) bool { return s.ControlPlane[v] > 0 || s.DataPlane[v] > 0 }
+	for _, v := range versionsOf(to) {
+		if !running(from, v) {
+			ret.NewVersions = append(ret.NewVersions, v)
+		}
+	}
+	for _, v := range versionsOf(from) {
+		if !running(to, v) {
+			ret.RemovedVersions = append(ret.RemovedVersions, v)
+		}
+	}
+
+	for _, m := range to.MinorVersions {
+		if m.securityPending() {
+			ret.SecurityPending = append(ret.SecurityPending,
+				PendingSecurityPatch{MinorVersion: m.MinorVersion, Latest: m.Latest, Since: to.SecurityPendingSince[m.MinorVersion]})
+		}
+	}
+	for g := range from.SecurityPendingSince {
+		if _, ok := to.SecurityPendingSince[g]; !ok {
+			ret.SecurityResolved = append(ret.SecurityResolved, g)
+		}
+	}
+	sort.Strings(ret.SecurityResolved)
+	return ret
+}
+
+func diffCounts(from, to map[string]int) map[string][2]int {
+	ret := map[string][2]int{}
+	for v, n := range from {
+		if to[v] != n {
+			ret[v] = [2]int{n, to[v]}
+		}
+	}
+	for v, n := range to {
+		if _, ok := from[v]; !ok && n != 0 {
+			ret[v] = [2]int{0, n}
+		}
+	}
+	return ret
+}
+
+func versionsOf(s *Snapshot) []string {
+	set := map[string]struct{}{}
+	for v, n := range s.ControlPlane {
+		if n > 0 {
+			set[v] = struct{}{}
+		}
+	}
+	for v, n := range s.DataPlane {
+		if n > 0 {
+			set[v] = struct{}{}
+		}
+	}
+	return sortedKeys(set)
+}
+
+func sortedKeys(in map[string]struct{}) []string {
+	ret := make([]string, 0, len(in))
+	for k := range in {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// PrintSnapshotDiff prints the changes in the human readable form
+func PrintSnapshotDiff(d *SnapshotDiff) {
+	logger.Infof("[Changes since %s (%s ago)]\n", d.From.UTC().Format(time.RFC3339), formatDays(d.To.Sub(d.From)))
+	if len(d.ControlPlane) == 0 && len(d.DataPlane) == 0 {
+		logger.Infof("- No version changed\n")
+	}
+	printCounts("Control plane", d.ControlPlane)
+	printCounts("Data plane", d.DataPlane)
+	if len(d.NewVersions) > 0 {
+		logger.Infof("- New versions: %s\n", strings.Join(d.NewVersions, ", "))
+	}
+	if len(d.RemovedVersions) > 0 {
+		logger.Infof("- No longer running: %s\n", strings.Join(d.RemovedVersions, ", "))
+	}
+	logger.Infof("- Outdated proxies: %d -> %d", d.OutdatedFrom, d.OutdatedTo)
+	if upgraded := d.OutdatedFrom - d.OutdatedTo; upgraded > 0 {
+		logger.Infof(" (%d upgraded)", upgraded)
+	}
+	logger.Infof("\n")
+	for _, p := range d.SecurityPending {
+		logger.Infof("- The security patch for %s (-> %s) has been pending for %s since %s\n",
+			p.MinorVersion, p.Latest, formatDays(d.To.Sub(p.Since)), p.Since.UTC().Format(time.RFC3339))
+	}
+	for _, g := range d.SecurityResolved {
+		logger.Infof("- The security patch for %s has been applied\n", g)
+	}
+}
+
+func printCounts(title string, counts map[string][2]int) {
+	if len(counts) == 0 {
+		return
+	}
+	vs := make([]string, 0, len(counts))
+	for v := range counts {
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+
+	logger.Infof("- %s:\n", title)
+	for _, v := range vs {
+		c := counts[v]
+		logger.Infof("    %s: %d -> %d (%+d)\n", v, c[0], c[1], c[1]-c[0])
+	}
+}
+
+func formatDays(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
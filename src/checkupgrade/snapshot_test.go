@@ -0,0 +1,162 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func snapshotForTest(t *testing.T, context string, now time.Time, prev *Snapshot, cp string, dp ...string) *Snapshot {
+	ms := &api.Manifest{IstioDistributions: []*api.IstioDistribution{
+		{Version: "1.8.3", Flavor: "tetrate", FlavorVersion: 0, IsSecurityPatch: true},
+		{Version: "1.8.1", Flavor: "tetrate", FlavorVersion: 0},
+		{Version: "1.7.8", Flavor: "tetrate", FlavorVersion: 0, IsSecurityPatch: true},
+		{Version: "1.7.4", Flavor: "tetrate", FlavorVersion: 0},
+	}}
+	iv := istioversion.Version{
+		MeshVersion:      &istioversion.MeshInfo{{Component: "pilot", Info: istioversion.BuildInfo{Version: cp}}},
+		DataPlaneVersion: &[]istioversion.ProxyInfo{},
+	}
+	for _, v := range dp {
+		*iv.DataPlaneVersion = append(*iv.DataPlaneVersion, istioversion.ProxyInfo{IstioVersion: v})
+	}
+	analysis, err := Analyze(iv, ms)
+	require.NoError(t, err)
+	return NewSnapshot(context, iv, analysis, now, prev)
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(14 * 24 * time.Hour)
+
+	latest, err := LatestSnapshot(filepath.Join(dir, "not-exist"), "dev")
+	require.NoError(t, err)
+	require.Nil(t, latest)
+
+	from := snapshotForTest(t, "prod", first, nil, "1.7.4-tetrate-v0",
+		"1.7.4-tetrate-v0", "1.7.4-tetrate-v0", "1.8.1-tetrate-v0", "1.8.1-tetrate-v0", "1.8.1-tetrate-v0")
+	require.Equal(t, map[string]time.Time{"1.7-tetrate": first, "1.8-tetrate": first}, from.SecurityPendingSince)
+	p, err := SaveSnapshot(dir, from)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "20210501T000000Z.json"), p)
+
+	// the snapshots of the other contexts are ignored
+	_, err = SaveSnapshot(dir, snapshotForTest(t, "dev", second, nil, "1.8.3-tetrate-v0"))
+	require.NoError(t, err)
+
+	prev, err := LatestSnapshot(dir, "prod")
+	require.NoError(t, err)
+	require.Equal(t, from, prev)
+
+	// the broken snapshots are skipped with the warning
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "20210601T000000Z.json"), []byte(`{"timestamp":`), 0644))
+	warnings := logger.ExecuteWithLock(func() {
+		prev, err = LatestSnapshot(dir, "prod")
+		require.NoError(t, err)
+	})
+	require.Equal(t, from, prev)
+	require.Contains(t, warnings.String(), "skipping the snapshot: error parsing the snapshot")
+
+	for _, name := range []string{"20210501T000000Z", "20210501T000000Z.json", p} {
+		s, err := LoadSnapshot(dir, name)
+		require.NoError(t, err, name)
+		require.Equal(t, from, s, name)
+	}
+	_, err = LoadSnapshot(dir, "20210401T000000Z")
+	require.Error(t, err)
+
+	to := snapshotForTest(t, "prod", second, prev, "1.8.3-tetrate-v0",
+		"1.8.1-tetrate-v0", "1.8.3-tetrate-v0", "1.8.3-tetrate-v0", "1.8.3-tetrate-v0", "1.8.3-tetrate-v0")
+	require.Equal(t, map[string]time.Time{"1.8-tetrate": first}, to.SecurityPendingSince)
+
+	diff := CompareSnapshots(prev, to)
+	require.Equal(t, &SnapshotDiff{
+		From:         first,
+		To:           second,
+		ControlPlane: map[string][2]int{"1.7.4-tetrate-v0": {1, 0}, "1.8.3-tetrate-v0": {0, 1}},
+		DataPlane: map[string][2]int{
+			"1.7.4-tetrate-v0": {2, 0}, "1.8.1-tetrate-v0": {3, 1}, "1.8.3-tetrate-v0": {0, 4},
+		},
+		NewVersions:     []string{"1.8.3-tetrate-v0"},
+		RemovedVersions: []string{"1.7.4-tetrate-v0"},
+		OutdatedFrom:    5,
+		OutdatedTo:      1,
+		SecurityPending: []PendingSecurityPatch{
+			{MinorVersion: "1.8-tetrate", Latest: "1.8.3-tetrate-v0", Since: first},
+		},
+		SecurityResolved: []string{"1.7-tetrate"},
+	}, diff)
+
+	buf := logger.ExecuteWithLock(func() {
+		PrintSnapshotDiff(diff)
+	})
+	require.Equal(t, `[Changes since 2021-05-01T00:00:00Z (14 days ago)]
+- Control plane:
+    1.7.4-tetrate-v0: 1 -> 0 (-1)
+    1.8.3-tetrate-v0: 0 -> 1 (+1)
+- Data plane:
+    1.7.4-tetrate-v0: 2 -> 0 (-2)
+    1.8.1-tetrate-v0: 3 -> 1 (-2)
+    1.8.3-tetrate-v0: 0 -> 4 (+4)
+- New versions: 1.8.3-tetrate-v0
+- No longer running: 1.7.4-tetrate-v0
+- Outdated proxies: 5 -> 1 (4 upgraded)
+- The security patch for 1.8-tetrate (-> 1.8.3-tetrate-v0) has been pending for 14 days since 2021-05-01T00:00:00Z
+- The security patch for 1.7-tetrate has been applied
+`, buf.String())
+
+	buf = logger.ExecuteWithLock(func() {
+		PrintSnapshotDiff(CompareSnapshots(to, to))
+	})
+	require.Equal(t, `[Changes since 2021-05-15T00:00:00Z (0 days ago)]
+- No version changed
+- Outdated proxies: 1 -> 1
+- The security patch for 1.8-tetrate (-> 1.8.3-tetrate-v0) has been pending for 14 days since 2021-05-01T00:00:00Z
+`, buf.String())
+}
+
+func TestSnapshot_outdatedProxies(t *testing.T) {
+	ms := &api.Manifest{IstioDistributions: []*api.IstioDistribution{
+		{Version: "1.9.5", Flavor: "istio", FlavorVersion: 0},
+		{Version: "1.9.4", Flavor: "istio", FlavorVersion: 0},
+		{Version: "1.9.5", Flavor: "tetrate", FlavorVersion: 0},
+	}}
+	iv := istioversion.Version{
+		MeshVersion: &istioversion.MeshInfo{{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.9.5"}}},
+		DataPlaneVersion: &[]istioversion.ProxyInfo{
+			{IstioVersion: "1.9.5"}, {IstioVersion: "1.9.5"}, {IstioVersion: "1.9.4"}, {IstioVersion: "1.9.5-tetrate-v0"},
+		},
+	}
+	analysis, err := Analyze(iv, ms)
+	require.NoError(t, err)
+
+	// the upstream proxies running the latest patch, e.g. "1.9.5" for "1.9.5-istio-v0", are not outdated
+	s := NewSnapshot("prod", iv, analysis, time.Now(), nil)
+	require.Equal(t, 1, s.outdatedProxies())
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// jsonResult is the issue in the JSON output, which is read back as the baseline
+type jsonResult struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	ResourceType string `json:"resourceType"`
+	ErrorCode    string `json:"errorCode"`
+	Severity     string `json:"severity"`
+	Message      string `json:"message"`
+}
+
+// errorCodeNamespaceSkipped is the code of the entries in the JSON output for the namespaces not validated by Kiali
+// as the validation timed out. The issues in such namespaces of the baseline are unknown, so they are not reported as new
+const errorCodeNamespaceSkipped = "NamespaceSkipped"
+
+func toJSONResults(in []configValidationResult) []jsonResult {
+	ret := make([]jsonResult, len(in))
+	for i, r := range in {
+		ret[i] = jsonResult{
+			Namespace:    r.namespace,
+			Name:         r.name,
+			ResourceType: r.resourceType,
+			ErrorCode:    r.errorCode,
+			Severity:     r.severity.Name,
+			Message:      r.message,
+		}
+	}
+	return ret
+}
+
+// loadBaseline reads the JSON output of a previous run and returns the keys of the issues found in it
+func loadBaseline(path string) (map[string]struct{}, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the baseline %s: %w", path, err)
+	}
+
+	var results []jsonResult
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse the baseline %s: it must be the output of \"getmesh config-validate -o json\": %w",
+			path, err)
+	}
+
+	ret := make(map[string]struct{}, len(results))
+	for _, r := range results {
+		ret[baselineKey(r.Namespace, r.Name, r.ResourceType, r.ErrorCode)] = struct{}{}
+	}
+	return ret, nil
+}
+
+// skippedNamespaceResults returns the entries of the JSON output for the skipped namespaces
+func skippedNamespaceResults(namespaces []string, timeout time.Duration) []jsonResult {
+	ret := make([]jsonResult, len(namespaces))
+	for i, ns := range namespaces {
+		ret[i] = jsonResult{
+			Namespace:    ns,
+			Name:         ns,
+			ResourceType: "Namespace",
+			ErrorCode:    errorCodeNamespaceSkipped,
+			Severity:     SeverityLevelWarn.Name,
+			Message:      fmt.Sprintf("not validated by Kiali as the validation timed out after %s", timeout),
+		}
+	}
+	return ret
+}
+
+// baselineSkippedKey is the key of the namespace skipped in the baseline
+func baselineSkippedKey(namespace string) string {
+	return baselineKey(namespace, namespace, "Namespace", errorCodeNamespaceSkipped)
+}
+
+// baselineKey identifies the issue across runs. The message is not part of it since it may contain the file paths
+func baselineKey(namespace, name, resourceType, errorCode string) string {
+	return strings.Join([]string{namespace, name, strings.ToLower(resourceType), errorCode}, "/")
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadBaseline(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`[
+  {
+    "namespace": "bookinfo",
+    "name": "reviews",
+    "resourceType": "Virtualservice",
+    "errorCode": "KIA1107",
+    "severity": "Warning",
+    "message": "[reviews.yaml] Subset not found"
+  }
+]`)
+	require.NoError(t, err)
+
+	actual, err := loadBaseline(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{baselineKey("bookinfo", "reviews", "VirtualService", "KIA1107"): {}}, actual)
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("NAME RESOURCE TYPE"), 0644))
+	_, err = loadBaseline(f.Name())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "-o json")
+}
@@ -21,16 +21,20 @@ package configvalidator
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	kiali_kubernetes "github.com/kiali/kiali/kubernetes"
 	"golang.org/x/sync/singleflight"
 	"gopkg.in/yaml.v3"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -55,24 +59,30 @@ type kialiClientWrapper struct {
 	k8s                        *kubernetes.Clientset
 	sf                         singleflight.Group
 
+	localObjects
+}
+
+// localObjects is the objects parsed from the local yaml files
+type localObjects struct {
 	// read only
 	localIstioObjects        map[string]map[string]kiali_kubernetes.IstioObject
 	localIstioObjectFilesRef map[string]string
-}
 
-func init() {
-	istioTypes = make(map[string]struct{}, len(networkingTypes)+len(securityTypes))
-	for _, t := range networkingTypes {
-		istioTypes[t.objectKind] = struct{}{}
-	}
-	for _, t := range securityTypes {
-		istioTypes[t.objectKind] = struct{}{}
-	}
+	// the Kubernetes objects which are only used in the offline validation instead of the ones in the cluster
+	localNamespaces map[string]struct{}
+	// the labels of the Namespace objects
+	localNamespaceLabels map[string]map[string]string
+	localServices        []core_v1.Service
+	localDeployments     []apps_v1.Deployment
+	localStatefulSets    []apps_v1.StatefulSet
+	localPods            []core_v1.Pod
 }
 
-func (c *kialiClientWrapper) parseFilesAsKialiIstioObjects(files []string, namespace string) error {
+func (c *localObjects) parseFilesAsKialiIstioObjects(files []string, namespace string) error {
 	objs := map[string]map[string]kiali_kubernetes.IstioObject{}
 	refs := map[string]string{}
+	c.localNamespaces = map[string]struct{}{}
+	c.localNamespaceLabels = map[string]map[string]string{}
 	for _, f := range files {
 		raw, err := ioutil.ReadFile(f)
 		if err != nil {
@@ -82,15 +92,34 @@ func (c *kialiClientWrapper) parseFilesAsKialiIstioObjects(files []string, names
 		r := yaml.NewDecoder(bytes.NewReader(raw))
 		for {
 
-			var obj kiali_kubernetes.GenericIstioObject
-			if err := r.Decode(&obj); err != nil && err != io.EOF {
+			var node yaml.Node
+			if err := r.Decode(&node); err != nil && err != io.EOF {
 				return fmt.Errorf("failed to unmarshal %s: %v", f, err)
 			} else if err == io.EOF {
 				break
 			}
 
+			var obj kiali_kubernetes.GenericIstioObject
+			if err := node.Decode(&obj); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %v", f, err)
+			}
+
+			// TypeMeta only has the json tags
+			var tm struct {
+				APIVersion string `yaml:"apiVersion"`
+			}
+			if err := node.Decode(&tm); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %v", f, err)
+			}
+			obj.APIVersion = tm.APIVersion
+
+			// only the kinds known to Kiali are Istio objects here, and the others
+			// like the Gateway of the Gateway API are validated by istioctl analyze and the custom rules
 			kind := obj.GetObjectKind().GroupVersionKind().Kind
-			if _, ok := istioTypes[kind]; !ok {
+			if res, ok := configResourceOf(obj.APIVersion, kind); !ok || !res.kiali {
+				if err := c.addKubernetesObject(&node, kind, namespace); err != nil {
+					return fmt.Errorf("failed to unmarshal %s in %s: %v", kind, f, err)
+				}
 				continue
 			}
 
@@ -103,6 +132,7 @@ func (c *kialiClientWrapper) parseFilesAsKialiIstioObjects(files []string, names
 				logger.Infof("The object %s in %s does not have namespace so we assume it's applied to \"%s\" namespace \n",
 					obj.Name, f, obj.Namespace)
 			}
+			c.localNamespaces[obj.Namespace] = struct{}{}
 			key := kialiObjectListKey(obj.Namespace, kind)
 
 			m, ok := objs[key]
@@ -122,73 +152,85 @@ func (c *kialiClientWrapper) parseFilesAsKialiIstioObjects(files []string, names
 	return nil
 }
 
-var (
-	networkingTypes = []struct {
-		objectKind     string
-		collectionKind string
-	}{
-		{
-			objectKind:     kiali_kubernetes.GatewayType,
-			collectionKind: kiali_kubernetes.GatewayTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.VirtualServiceType,
-			collectionKind: kiali_kubernetes.VirtualServiceTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.DestinationRuleType,
-			collectionKind: kiali_kubernetes.DestinationRuleTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.ServiceEntryType,
-			collectionKind: kiali_kubernetes.ServiceentryTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.SidecarType,
-			collectionKind: kiali_kubernetes.SidecarTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.WorkloadEntryType,
-			collectionKind: kiali_kubernetes.WorkloadEntryTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.EnvoyFilterType,
-			collectionKind: kiali_kubernetes.EnvoyFilterTypeList,
-		},
+// addKubernetesObject keeps the Kubernetes objects referred by the Istio objects, e.g. the Services of the hosts.
+// The other kinds are ignored
+func (c *localObjects) addKubernetesObject(node *yaml.Node, kind, namespace string) error {
+	var into interface{}
+	switch kind {
+	case "Namespace":
+		into = &core_v1.Namespace{}
+	case "Service":
+		into = &core_v1.Service{}
+	case "Deployment":
+		into = &apps_v1.Deployment{}
+	case "StatefulSet":
+		into = &apps_v1.StatefulSet{}
+	case "Pod":
+		into = &core_v1.Pod{}
+	default:
+		return nil
 	}
-	securityTypes = []struct {
-		objectKind     string
-		collectionKind string
-	}{
-		{
-			objectKind:     kiali_kubernetes.PeerAuthenticationsType,
-			collectionKind: kiali_kubernetes.PeerAuthenticationsTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.AuthorizationPoliciesType,
-			collectionKind: kiali_kubernetes.AuthorizationPoliciesTypeList,
-		},
-		{
-			objectKind:     kiali_kubernetes.RequestAuthenticationsType,
-			collectionKind: kiali_kubernetes.RequestAuthenticationsTypeList,
-		},
+
+	// the Kubernetes types only have the json tags
+	var m map[string]interface{}
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, into); err != nil {
+		return err
 	}
 
-	istioTypes map[string]struct{}
-)
+	meta := into.(metav1.Object)
+	if kind == "Namespace" {
+		c.localNamespaces[meta.GetName()] = struct{}{}
+		c.localNamespaceLabels[meta.GetName()] = meta.GetLabels()
+		return nil
+	}
+	if meta.GetNamespace() == "" {
+		if namespace == "" {
+			namespace = "default"
+		}
+		meta.SetNamespace(namespace)
+	}
+	c.localNamespaces[meta.GetNamespace()] = struct{}{}
+
+	switch o := into.(type) {
+	case *core_v1.Service:
+		c.localServices = append(c.localServices, *o)
+	case *apps_v1.Deployment:
+		c.localDeployments = append(c.localDeployments, *o)
+	case *apps_v1.StatefulSet:
+		c.localStatefulSets = append(c.localStatefulSets, *o)
+	case *core_v1.Pod:
+		c.localPods = append(c.localPods, *o)
+	}
+	return nil
+}
 
-func newKialiClientWrapper(base *kiali_kubernetes.K8SClient, files []string, namespace string) (*kialiClientWrapper, error) {
+// newKialiScheme returns the scheme of the kinds in configResources validated by Kiali
+func newKialiScheme() *runtime.Scheme {
 	sc := runtime.NewScheme()
-	for _, nt := range networkingTypes {
-		sc.AddKnownTypeWithName(kiali_kubernetes.NetworkingGroupVersion.WithKind(nt.objectKind), &kiali_kubernetes.GenericIstioObject{})
-		sc.AddKnownTypeWithName(kiali_kubernetes.NetworkingGroupVersion.WithKind(nt.collectionKind), &kiali_kubernetes.GenericIstioObjectList{})
-	}
-	for _, rt := range securityTypes {
-		sc.AddKnownTypeWithName(kiali_kubernetes.SecurityGroupVersion.WithKind(rt.objectKind), &kiali_kubernetes.GenericIstioObject{})
-		sc.AddKnownTypeWithName(kiali_kubernetes.SecurityGroupVersion.WithKind(rt.collectionKind), &kiali_kubernetes.GenericIstioObjectList{})
+	for _, r := range configResources {
+		if !r.kiali {
+			continue
+		}
+		gv := r.GroupVersion()
+		sc.AddKnownTypeWithName(gv.WithKind(r.kind), &kiali_kubernetes.GenericIstioObject{})
+		sc.AddKnownTypeWithName(gv.WithKind(r.kind+"List"), &kiali_kubernetes.GenericIstioObjectList{})
 	}
 	metav1.AddToGroupVersion(sc, kiali_kubernetes.NetworkingGroupVersion)
 	metav1.AddToGroupVersion(sc, kiali_kubernetes.SecurityGroupVersion)
+	return sc
+}
+
+// newKialiClientWrapper creates the wrapper whose requests to the Istio APIs are bounded by timeout unless it's zero
+func newKialiClientWrapper(base *kiali_kubernetes.K8SClient, files []string, namespace string,
+	timeout time.Duration) (*kialiClientWrapper, error) {
+	sc := newKialiScheme()
 
 	k8s, err := util.GetK8sClient()
 	if err != nil {
@@ -212,6 +254,7 @@ func newKialiClientWrapper(base *kiali_kubernetes.K8SClient, files []string, nam
 		ContentType:          runtime.ContentTypeJSON,
 	}
 	config.APIPath = "/apis"
+	config.Timeout = timeout
 
 	if ret.networkingAPI, err = rest.RESTClientFor(config); err != nil {
 		return nil, fmt.Errorf("failed to create client for istio networking api: %w", err)
@@ -224,6 +267,7 @@ func newKialiClientWrapper(base *kiali_kubernetes.K8SClient, files []string, nam
 	}
 
 	config.APIPath = "/apis"
+	config.Timeout = timeout
 
 	config.ContentConfig = rest.ContentConfig{
 		GroupVersion:         &kiali_kubernetes.SecurityGroupVersion,
@@ -18,7 +18,9 @@ import (
 	"io/ioutil"
 	"testing"
 
+	kiali_kubernetes "github.com/kiali/kiali/kubernetes"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestParseFilesAsKialiIstioObjects(t *testing.T) {
@@ -70,6 +72,24 @@ spec:
             subset: v1
           weight: 10
 ---
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: d
+  namespace: default
+spec:
+  gatewayClassName: istio
+  listeners:
+    - name: http
+      port: 80
+      protocol: HTTP
+---
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: e
+  namespace: default
+---
 a: a
 ---
 b: b
@@ -97,7 +117,7 @@ spec:
       targetPort: 80
   selector:
     app: httpbin
-`) // ignored
+`) // not an Istio object but kept for the offline validation
 	require.NoError(t, err)
 
 	c := kialiClientWrapper{}
@@ -118,4 +138,30 @@ spec:
 		require.True(t, found)
 		require.Contains(t, c.localIstioObjectFilesRef, kialiObjectKey("default", exp, "VirtualService"))
 	}
+
+	// not validated by Kiali
+	require.Empty(t, c.localIstioObjects[kialiObjectListKey("default", "Gateway")])
+	require.NotContains(t, c.localIstioObjectFilesRef, kialiObjectKey("default", "d", "Gateway"))
+	require.NotContains(t, c.localIstioObjectFilesRef, kialiObjectKey("default", "e", "Telemetry"))
+
+	require.Len(t, c.localServices, 1)
+	require.Equal(t, "httpbin", c.localServices[0].Name)
+	require.Equal(t, map[string]struct{}{"default": {}, "healthy": {}}, c.localNamespaces)
+}
+
+func Test_newKialiScheme(t *testing.T) {
+	sc := newKialiScheme()
+	for _, gvk := range []schema.GroupVersionKind{
+		kiali_kubernetes.NetworkingGroupVersion.WithKind(kiali_kubernetes.GatewayType),
+		kiali_kubernetes.NetworkingGroupVersion.WithKind(kiali_kubernetes.ServiceentryTypeList),
+		kiali_kubernetes.NetworkingGroupVersion.WithKind(kiali_kubernetes.EnvoyFilterTypeList),
+		kiali_kubernetes.SecurityGroupVersion.WithKind(kiali_kubernetes.AuthorizationPoliciesTypeList),
+		kiali_kubernetes.SecurityGroupVersion.WithKind(kiali_kubernetes.RequestAuthenticationsType),
+	} {
+		require.True(t, sc.Recognizes(gvk), gvk.String())
+	}
+
+	// the kinds not validated by Kiali are not registered
+	require.False(t, sc.Recognizes(kiali_kubernetes.NetworkingGroupVersion.WithKind("WorkloadGroup")))
+	require.False(t, sc.Recognizes(schema.GroupVersionKind{Group: gatewayAPIGroup, Version: "v1beta1", Kind: "Gateway"}))
 }
@@ -0,0 +1,344 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"fmt"
+	"sort"
+
+	kiali_kubernetes "github.com/kiali/kiali/kubernetes"
+	osapps_v1 "github.com/openshift/api/apps/v1"
+	osproject_v1 "github.com/openshift/api/project/v1"
+	osroutes_v1 "github.com/openshift/api/route/v1"
+	apps_v1 "k8s.io/api/apps/v1"
+	auth_v1 "k8s.io/api/authorization/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1beta1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// kialiOfflineClient serves the objects in the local yaml files as if they were in the cluster,
+// so that Kiali validates them without the cluster. The lookups of the objects which cannot be given
+// by the files return empty results, and the mutations return the error
+type kialiOfflineClient struct {
+	localObjects
+}
+
+var _ kiali_kubernetes.ClientInterface = &kialiOfflineClient{}
+
+func errNotSupportedOffline(op string) error {
+	return fmt.Errorf("%s is not supported in the offline mode", op)
+}
+
+func newKialiOfflineClient(files []string, namespace string) (*kialiOfflineClient, error) {
+	ret := &kialiOfflineClient{}
+	if err := ret.parseFilesAsKialiIstioObjects(files, namespace); err != nil {
+		return nil, fmt.Errorf("failed to prase local yaml files: %s", err)
+	}
+	return ret, nil
+}
+
+// namespaceNames returns the sorted namespaces of the local objects
+func (c *kialiOfflineClient) namespaceNames() []string {
+	ret := make([]string, 0, len(c.localNamespaces))
+	for ns := range c.localNamespaces {
+		ret = append(ret, ns)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// namespaces returns the namespaces of the local objects with the labels given by the Namespace objects
+func (c *kialiOfflineClient) namespaces() []namespaceInfo {
+	names := c.namespaceNames()
+	ret := make([]namespaceInfo, len(names))
+	for i, ns := range names {
+		ret[i] = namespaceInfo{name: ns, labels: c.localNamespaceLabels[ns]}
+	}
+	return ret
+}
+
+func (c *kialiOfflineClient) GetServerVersion() (*version.Info, error) {
+	return &version.Info{}, nil
+}
+
+func (c *kialiOfflineClient) GetToken() string {
+	return ""
+}
+
+func (c *kialiOfflineClient) GetAuthInfo() *api.AuthInfo {
+	return &api.AuthInfo{}
+}
+
+func (c *kialiOfflineClient) GetTokenSubject(authInfo *api.AuthInfo) (string, error) {
+	return "", nil
+}
+
+func (c *kialiOfflineClient) GetSelfSubjectAccessReview(namespace, api, resourceType string, verbs []string) ([]*auth_v1.SelfSubjectAccessReview, error) {
+	return nil, errNotSupportedOffline("access review")
+}
+
+func (c *kialiOfflineClient) IsOpenShift() bool {
+	return false
+}
+
+func (c *kialiOfflineClient) GetProject(project string) (*osproject_v1.Project, error) {
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "projects"}, project)
+}
+
+func (c *kialiOfflineClient) GetProjects(labelSelector string) ([]osproject_v1.Project, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) UpdateProject(project string, jsonPatch string) (*osproject_v1.Project, error) {
+	return nil, errNotSupportedOffline("updating project")
+}
+
+func (c *kialiOfflineClient) GetRoute(namespace string, name string) (*osroutes_v1.Route, error) {
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "routes"}, name)
+}
+
+func (c *kialiOfflineClient) GetDeploymentConfig(namespace string, deploymentconfigName string) (*osapps_v1.DeploymentConfig, error) {
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "deploymentconfigs"}, deploymentconfigName)
+}
+
+func (c *kialiOfflineClient) GetDeploymentConfigs(namespace string) ([]osapps_v1.DeploymentConfig, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) IsIter8Api() bool {
+	return false
+}
+
+func (c *kialiOfflineClient) CreateIter8Experiment(namespace string, json string) (kiali_kubernetes.Iter8Experiment, error) {
+	return nil, errNotSupportedOffline("creating iter8 experiment")
+}
+
+func (c *kialiOfflineClient) UpdateIter8Experiment(namespace string, name string, json string) (kiali_kubernetes.Iter8Experiment, error) {
+	return nil, errNotSupportedOffline("updating iter8 experiment")
+}
+
+func (c *kialiOfflineClient) DeleteIter8Experiment(namespace string, name string) error {
+	return errNotSupportedOffline("deleting iter8 experiment")
+}
+
+func (c *kialiOfflineClient) GetIter8Experiment(namespace string, name string) (kiali_kubernetes.Iter8Experiment, error) {
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "experiments"}, name)
+}
+
+func (c *kialiOfflineClient) GetIter8Experiments(namespace string) ([]kiali_kubernetes.Iter8Experiment, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) Iter8MetricMap() ([]string, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) GetNamespace(namespace string) (*core_v1.Namespace, error) {
+	if _, ok := c.localNamespaces[namespace]; !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, namespace)
+	}
+	return &core_v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: c.localNamespaceLabels[namespace]}}, nil
+}
+
+func (c *kialiOfflineClient) UpdateNamespace(namespace string, jsonPatch string) (*core_v1.Namespace, error) {
+	return nil, errNotSupportedOffline("updating namespace")
+}
+
+func (c *kialiOfflineClient) GetNamespaces(labelSelector string) ([]core_v1.Namespace, error) {
+	names := c.namespaceNames()
+	ret := make([]core_v1.Namespace, len(names))
+	for i, ns := range names {
+		ret[i] = core_v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns, Labels: c.localNamespaceLabels[ns]}}
+	}
+	return ret, nil
+}
+
+func (c *kialiOfflineClient) GetIstioObjects(namespace, resourceType, labelSelector string) ([]kiali_kubernetes.IstioObject, error) {
+	locals := c.localIstioObjects[kialiObjectListKey(namespace, kiali_kubernetes.PluralType[resourceType])]
+	ret := make([]kiali_kubernetes.IstioObject, 0, len(locals))
+	for _, l := range locals {
+		ret = append(ret, l)
+	}
+	return ret, nil
+}
+
+func (c *kialiOfflineClient) GetIstioObject(namespace, resourceType, name string) (kiali_kubernetes.IstioObject, error) {
+	kind := kiali_kubernetes.PluralType[resourceType]
+	if o, ok := c.localIstioObjects[kialiObjectListKey(namespace, kind)][kialiObjectKey(namespace, name, kind)]; ok {
+		return o, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: resourceType}, name)
+}
+
+func (c *kialiOfflineClient) CreateIstioObject(api, namespace, resourceType, json string) (kiali_kubernetes.IstioObject, error) {
+	return nil, errNotSupportedOffline("creating istio object")
+}
+
+func (c *kialiOfflineClient) UpdateIstioObject(api, namespace, resourceType, name, jsonPatch string) (kiali_kubernetes.IstioObject, error) {
+	return nil, errNotSupportedOffline("updating istio object")
+}
+
+func (c *kialiOfflineClient) DeleteIstioObject(api, namespace, resourceType, name string) error {
+	return errNotSupportedOffline("deleting istio object")
+}
+
+// GetProxyStatus returns no proxy since nothing is running offline
+func (c *kialiOfflineClient) GetProxyStatus() ([]*kiali_kubernetes.ProxyStatus, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) GetConfigDump(namespace, podName string) (*kiali_kubernetes.ConfigDump, error) {
+	return nil, errNotSupportedOffline("getting config dump")
+}
+
+func (c *kialiOfflineClient) GetServices(namespace string, selectorLabels map[string]string) ([]core_v1.Service, error) {
+	var ret []core_v1.Service
+	for _, s := range c.localServices {
+		if s.Namespace == namespace && labels.SelectorFromSet(selectorLabels).Matches(labels.Set(s.Labels)) {
+			ret = append(ret, s)
+		}
+	}
+	return ret, nil
+}
+
+func (c *kialiOfflineClient) GetService(namespace, serviceName string) (*core_v1.Service, error) {
+	for _, s := range c.localServices {
+		if s.Namespace == namespace && s.Name == serviceName {
+			return &s, nil
+		}
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "services"}, serviceName)
+}
+
+func (c *kialiOfflineClient) GetDeployments(namespace string) ([]apps_v1.Deployment, error) {
+	var ret []apps_v1.Deployment
+	for _, d := range c.localDeployments {
+		if d.Namespace == namespace {
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
+
+func (c *kialiOfflineClient) GetDeployment(namespace string, deploymentName string) (*apps_v1.Deployment, error) {
+	for _, d := range c.localDeployments {
+		if d.Namespace == namespace && d.Name == deploymentName {
+			return &d, nil
+		}
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, deploymentName)
+}
+
+func (c *kialiOfflineClient) GetDeploymentsByLabel(namespace string, labelSelector string) ([]apps_v1.Deployment, error) {
+	sel, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []apps_v1.Deployment
+	for _, d := range c.localDeployments {
+		if d.Namespace == namespace && sel.Matches(labels.Set(d.Labels)) {
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
+
+func (c *kialiOfflineClient) GetStatefulSet(namespace string, statefulsetName string) (*apps_v1.StatefulSet, error) {
+	for _, s := range c.localStatefulSets {
+		if s.Namespace == namespace && s.Name == statefulsetName {
+			return &s, nil
+		}
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, statefulsetName)
+}
+
+func (c *kialiOfflineClient) GetStatefulSets(namespace string) ([]apps_v1.StatefulSet, error) {
+	var ret []apps_v1.StatefulSet
+	for _, s := range c.localStatefulSets {
+		if s.Namespace == namespace {
+			ret = append(ret, s)
+		}
+	}
+	return ret, nil
+}
+
+func (c *kialiOfflineClient) GetPods(namespace, labelSelector string) ([]core_v1.Pod, error) {
+	sel, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []core_v1.Pod
+	for _, p := range c.localPods {
+		if p.Namespace == namespace && sel.Matches(labels.Set(p.Labels)) {
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+func (c *kialiOfflineClient) GetPod(namespace, name string) (*core_v1.Pod, error) {
+	for _, p := range c.localPods {
+		if p.Namespace == namespace && p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+}
+
+func (c *kialiOfflineClient) GetPodLogs(namespace, name string, opts *core_v1.PodLogOptions) (*kiali_kubernetes.PodLogs, error) {
+	return nil, errNotSupportedOffline("getting pod logs")
+}
+
+func (c *kialiOfflineClient) GetEndpoints(namespace string, serviceName string) (*core_v1.Endpoints, error) {
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: "endpoints"}, serviceName)
+}
+
+func (c *kialiOfflineClient) GetSecrets(namespace string, labelSelector string) ([]core_v1.Secret, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) UpdateWorkload(namespace string, workloadName string, workloadType string, jsonPatch string) error {
+	return errNotSupportedOffline("updating workload")
+}
+
+func (c *kialiOfflineClient) GetReplicaSets(namespace string) ([]apps_v1.ReplicaSet, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) GetReplicationControllers(namespace string) ([]core_v1.ReplicationController, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) GetJobs(namespace string) ([]batch_v1.Job, error) {
+	return nil, nil
+}
+
+func (c *kialiOfflineClient) GetCronJobs(namespace string) ([]batch_v1beta1.CronJob, error) {
+	return nil, nil
+}
+
+// GetConfigMap returns the empty ConfigMap so that the default mesh config is used
+func (c *kialiOfflineClient) GetConfigMap(namespace, configName string) (*core_v1.ConfigMap, error) {
+	return &core_v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: configName, Namespace: namespace}}, nil
+}
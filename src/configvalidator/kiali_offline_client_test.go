@@ -0,0 +1,258 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kiali_business "github.com/kiali/kiali/business"
+	"github.com/stretchr/testify/require"
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func TestKialiOfflineConfigValidations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f := filepath.Join(dir, "reviews.yaml")
+	require.NoError(t, ioutil.WriteFile(f, []byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: reviews
+  namespace: bookinfo
+spec:
+  ports:
+    - name: http
+      port: 9080
+  selector:
+    app: reviews
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: reviews
+  namespace: bookinfo
+spec:
+  host: reviews
+  subsets:
+    - name: v1
+      labels:
+        version: v1
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: reviews
+  namespace: bookinfo
+spec:
+  hosts:
+    - reviews
+  http:
+    - route:
+        - destination:
+            host: reviews
+            subset: v2
+`), 0644))
+
+	for _, ns := range []string{"", "bookinfo"} {
+		cv := &ConfigValidator{namespace: ns, files: []string{f}, offline: true}
+		actual, err := cv.kialiConfigValidations()
+		require.NoError(t, err)
+
+		var found bool
+		for _, r := range actual {
+			if r.name == "reviews" && r.namespace == "bookinfo" && r.errorCode == "KIA1107" {
+				require.Contains(t, r.message, "["+f+"]")
+				found = true
+			}
+		}
+		require.True(t, found, actual)
+	}
+}
+
+func TestConfigValidator_kialiAllNamespaceConfigValidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the same missing subset in every namespace
+	var files []string
+	for _, ns := range []string{"team-a", "team-b", "team-c", "sandbox"} {
+		f := filepath.Join(dir, ns+".yaml")
+		require.NoError(t, ioutil.WriteFile(f, []byte(`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: `+ns+`
+  labels:
+    team: "true"
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: reviews
+  namespace: `+ns+`
+spec:
+  hosts:
+    - reviews
+  http:
+    - route:
+        - destination:
+            host: reviews
+            subset: v2
+`), 0644))
+		files = append(files, f)
+	}
+
+	client, err := newKialiOfflineClient(files, "")
+	require.NoError(t, err)
+	newService := func() kiali_business.IstioValidationsService {
+		return kiali_business.NewWithBackends(client, nil, nil).Validations
+	}
+
+	t.Run("all", func(t *testing.T) {
+		defer func(f func() bool) { stdoutIsTerminal = f }(stdoutIsTerminal)
+		stdoutIsTerminal = func() bool { return true }
+
+		cv := &ConfigValidator{concurrency: 2}
+		var actual []configValidationResult
+		buf := logger.ExecuteWithLock(func() {
+			actual, err = cv.kialiAllNamespaceConfigValidation(client.namespaces(), newService)
+		})
+		require.NoError(t, err)
+		require.Contains(t, buf.String(), "Validated 4/4 namespaces by Kiali")
+		require.Nil(t, cv.selectedNamespaces)
+
+		namespaces := map[string]struct{}{}
+		for _, r := range actual {
+			namespaces[r.namespace] = struct{}{}
+		}
+		require.Len(t, namespaces, 4)
+	})
+
+	t.Run("no terminal", func(t *testing.T) {
+		defer func(f func() bool) { stdoutIsTerminal = f }(stdoutIsTerminal)
+		stdoutIsTerminal = func() bool { return false }
+
+		cv := &ConfigValidator{concurrency: 2}
+		buf := logger.ExecuteWithLock(func() {
+			_, err = cv.kialiAllNamespaceConfigValidation(client.namespaces(), newService)
+		})
+		require.NoError(t, err)
+		require.Empty(t, buf.String())
+	})
+
+	t.Run("selected", func(t *testing.T) {
+		cv := &ConfigValidator{concurrency: 8, output: OutputJSON,
+			namespaceSelection: NamespaceSelection{Include: []string{"team-*"}, Exclude: []string{"team-c"}}}
+		require.NoError(t, cv.namespaceSelection.init())
+
+		var actual []configValidationResult
+		buf := logger.ExecuteWithLock(func() {
+			actual, err = cv.kialiAllNamespaceConfigValidation(client.namespaces(), newService)
+		})
+		require.NoError(t, err)
+		require.Empty(t, buf.String())
+		require.Equal(t, map[string]struct{}{"team-a": {}, "team-b": {}}, cv.selectedNamespaces)
+		for _, r := range actual {
+			require.Contains(t, cv.selectedNamespaces, r.namespace)
+		}
+
+		// the results of the other validations are filtered as well
+		cv.outputThreshold = SeverityLevelInfo
+		require.Equal(t, []configValidationResult{{namespace: "team-a"}},
+			cv.filterResults([]configValidationResult{{namespace: "team-a"}, {namespace: "team-c"}}))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		slow := &slowKialiClient{kialiOfflineClient: client, delay: time.Second}
+		cv := &ConfigValidator{concurrency: 4, namespaceTimeout: 10 * time.Millisecond}
+		var actual []configValidationResult
+		buf := logger.ExecuteWithLock(func() {
+			actual, err = cv.kialiAllNamespaceConfigValidation(client.namespaces(), func() kiali_business.IstioValidationsService {
+				return kiali_business.NewWithBackends(slow, nil, nil).Validations
+			})
+		})
+		require.NoError(t, err)
+		require.Empty(t, actual)
+		require.Equal(t, []string{"sandbox", "team-a", "team-b", "team-c"}, cv.skippedNamespaces)
+		// warned along with the results
+		require.NotContains(t, buf.String(), "WARNING")
+	})
+}
+
+type slowKialiClient struct {
+	*kialiOfflineClient
+	delay time.Duration
+}
+
+func (c *slowKialiClient) GetNamespace(namespace string) (*core_v1.Namespace, error) {
+	time.Sleep(c.delay)
+	return c.kialiOfflineClient.GetNamespace(namespace)
+}
+
+func TestKialiOfflineClient_namespaceNames(t *testing.T) {
+	c := &kialiOfflineClient{localObjects: localObjects{
+		localNamespaces: map[string]struct{}{"foo": {}, "bar": {}},
+	}}
+	require.Equal(t, []string{"bar", "foo"}, c.namespaceNames())
+
+	_, err := c.GetNamespace("foo")
+	require.NoError(t, err)
+	_, err = c.GetNamespace("baz")
+	require.Error(t, err)
+}
+
+func TestKialiOfflineClient_workloads(t *testing.T) {
+	c := &kialiOfflineClient{localObjects: localObjects{
+		localDeployments: []apps_v1.Deployment{
+			{ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "bookinfo", Labels: map[string]string{"app": "reviews"}}},
+		},
+		localPods: []core_v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "reviews-0", Namespace: "bookinfo"}}},
+	}}
+
+	d, err := c.GetDeployment("bookinfo", "reviews")
+	require.NoError(t, err)
+	require.Equal(t, "reviews", d.Name)
+	_, err = c.GetDeployment("default", "reviews")
+	require.True(t, errors.IsNotFound(err))
+
+	ds, err := c.GetDeploymentsByLabel("bookinfo", "app=reviews")
+	require.NoError(t, err)
+	require.Len(t, ds, 1)
+
+	_, err = c.GetPod("bookinfo", "reviews-0")
+	require.NoError(t, err)
+	_, err = c.GetStatefulSet("bookinfo", "reviews")
+	require.True(t, errors.IsNotFound(err))
+	_, err = c.GetProject("bookinfo")
+	require.True(t, errors.IsNotFound(err))
+
+	// the mutations are rejected instead of panicking
+	_, err = c.UpdateNamespace("bookinfo", "{}")
+	require.EqualError(t, err, "updating namespace is not supported in the offline mode")
+	require.Error(t, c.UpdateWorkload("bookinfo", "reviews", "Deployment", "{}"))
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NamespaceSelection selects the namespaces validated when validating all namespaces
+type NamespaceSelection struct {
+	// the glob patterns of the namespaces to validate, e.g. "team-*". All namespaces if empty
+	Include []string
+	// the glob patterns of the namespaces not to validate, which take precedence over Include
+	Exclude []string
+	// the label selector of the namespaces to validate, e.g. "istio-injection=enabled"
+	LabelSelector string
+
+	selector labels.Selector
+}
+
+type namespaceInfo struct {
+	name   string
+	labels map[string]string
+}
+
+func (s *NamespaceSelection) init() error {
+	for _, p := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern %q: %w", p, err)
+		}
+	}
+
+	var err error
+	if s.LabelSelector != "" {
+		if s.selector, err = labels.Parse(s.LabelSelector); err != nil {
+			return fmt.Errorf("invalid namespace selector %q: %w", s.LabelSelector, err)
+		}
+	}
+	return nil
+}
+
+func (s *NamespaceSelection) empty() bool {
+	return len(s.Include) == 0 && len(s.Exclude) == 0 && s.LabelSelector == ""
+}
+
+// selects returns the sorted names of the selected namespaces
+func (s *NamespaceSelection) selects(in []namespaceInfo) []string {
+	ret := make([]string, 0, len(in))
+	for _, ns := range in {
+		if (len(s.Include) == 0 || matchesAny(s.Include, ns.name)) && !matchesAny(s.Exclude, ns.name) &&
+			(s.selector == nil || s.selector.Matches(labels.Set(ns.labels))) {
+			ret = append(ret, ns.name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		// the patterns are validated beforehand
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceSelection_selects(t *testing.T) {
+	in := []namespaceInfo{
+		{name: "team-a-sandbox", labels: map[string]string{"istio-injection": "enabled"}},
+		{name: "team-a-prod", labels: map[string]string{"istio-injection": "enabled"}},
+		{name: "team-a-legacy"},
+		{name: "team-b-prod", labels: map[string]string{"istio-injection": "enabled"}},
+		{name: "kube-system"},
+	}
+
+	for _, c := range []struct {
+		name string
+		s    NamespaceSelection
+		exp  []string
+	}{
+		{name: "all", exp: []string{"kube-system", "team-a-legacy", "team-a-prod", "team-a-sandbox", "team-b-prod"}},
+		{name: "include", s: NamespaceSelection{Include: []string{"team-a-*", "kube-system"}},
+			exp: []string{"kube-system", "team-a-legacy", "team-a-prod", "team-a-sandbox"}},
+		{name: "exclude", s: NamespaceSelection{Include: []string{"team-*"}, Exclude: []string{"*-sandbox", "*-legacy"}},
+			exp: []string{"team-a-prod", "team-b-prod"}},
+		{name: "label selector", s: NamespaceSelection{Exclude: []string{"team-b-*"}, LabelSelector: "istio-injection=enabled"},
+			exp: []string{"team-a-prod", "team-a-sandbox"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			require.NoError(t, c.s.init())
+			require.Equal(t, c.exp, c.s.selects(in))
+		})
+	}
+
+	require.Error(t, (&NamespaceSelection{Include: []string{"team-["}}).init())
+	require.Error(t, (&NamespaceSelection{LabelSelector: "a in (b"}).init())
+}
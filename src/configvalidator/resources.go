@@ -0,0 +1,92 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"fmt"
+	"strings"
+
+	kiali_kubernetes "github.com/kiali/kiali/kubernetes"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// configResource is the kind of the configs read from the local files and the cluster
+type configResource struct {
+	schema.GroupVersionResource
+	kind string
+	// validated by Kiali as well, and registered in the scheme of the Kiali client.
+	// The others are only validated by istioctl analyze and the custom rules
+	kiali bool
+}
+
+const gatewayAPIGroup = "gateway.networking.k8s.io"
+
+var configResources = []configResource{
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource(kiali_kubernetes.Gateways), kind: kiali_kubernetes.GatewayType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource(kiali_kubernetes.VirtualServices), kind: kiali_kubernetes.VirtualServiceType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource(kiali_kubernetes.DestinationRules), kind: kiali_kubernetes.DestinationRuleType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource(kiali_kubernetes.ServiceEntries), kind: kiali_kubernetes.ServiceEntryType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource(kiali_kubernetes.Sidecars), kind: kiali_kubernetes.SidecarType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource(kiali_kubernetes.WorkloadEntries), kind: kiali_kubernetes.WorkloadEntryType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource(kiali_kubernetes.EnvoyFilters), kind: kiali_kubernetes.EnvoyFilterType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.SecurityGroupVersion.WithResource(kiali_kubernetes.PeerAuthentications), kind: kiali_kubernetes.PeerAuthenticationsType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.SecurityGroupVersion.WithResource(kiali_kubernetes.AuthorizationPolicies), kind: kiali_kubernetes.AuthorizationPoliciesType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.SecurityGroupVersion.WithResource(kiali_kubernetes.RequestAuthentications), kind: kiali_kubernetes.RequestAuthenticationsType, kiali: true},
+	{GroupVersionResource: kiali_kubernetes.NetworkingGroupVersion.WithResource("workloadgroups"), kind: "WorkloadGroup"},
+	{GroupVersionResource: schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "proxyconfigs"}, kind: "ProxyConfig"},
+	{GroupVersionResource: schema.GroupVersionResource{Group: "telemetry.istio.io", Version: "v1alpha1", Resource: "telemetries"}, kind: "Telemetry"},
+	{GroupVersionResource: schema.GroupVersionResource{Group: "extensions.istio.io", Version: "v1alpha1", Resource: "wasmplugins"}, kind: "WasmPlugin"},
+	{GroupVersionResource: schema.GroupVersionResource{Group: gatewayAPIGroup, Version: "v1beta1", Resource: "gateways"}, kind: "Gateway"},
+	{GroupVersionResource: schema.GroupVersionResource{Group: gatewayAPIGroup, Version: "v1beta1", Resource: "httproutes"}, kind: "HTTPRoute"},
+}
+
+// name returns the kind, which is qualified by the group unless it's Istio's, e.g. Gateway.gateway.networking.k8s.io
+func (r configResource) name() string {
+	if strings.HasSuffix(r.Group, ".istio.io") {
+		return r.kind
+	}
+	return r.kind + "." + r.Group
+}
+
+func (r configResource) groupKind() schema.GroupKind {
+	return schema.GroupKind{Group: r.Group, Kind: r.kind}
+}
+
+// lookupConfigResource returns the resource of the kind, which can be qualified by the group as "Kind.group".
+// Istio's takes precedence over the others on the same kind without the group, e.g. Gateway
+func lookupConfigResource(name string) (configResource, error) {
+	gk := schema.ParseGroupKind(name)
+	for _, r := range configResources {
+		if r.kind == gk.Kind && (gk.Group == "" || gk.Group == r.Group) {
+			return r, nil
+		}
+	}
+	return configResource{}, fmt.Errorf("unsupported kind %q", name)
+}
+
+// configResourceOf returns the resource of the object's apiVersion and kind, and false if it's not supported
+func configResourceOf(apiVersion, kind string) (configResource, bool) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return configResource{}, false
+	}
+
+	for _, r := range configResources {
+		if r.Group == gv.Group && r.kind == kind {
+			return r, true
+		}
+	}
+	return configResource{}, false
+}
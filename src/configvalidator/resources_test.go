@@ -0,0 +1,70 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lookupConfigResource(t *testing.T) {
+	for _, c := range []struct{ in, expGroup, expName string }{
+		{in: "Gateway", expGroup: "networking.istio.io", expName: "Gateway"},
+		{in: "Gateway.networking.istio.io", expGroup: "networking.istio.io", expName: "Gateway"},
+		{in: "Gateway.gateway.networking.k8s.io", expGroup: "gateway.networking.k8s.io", expName: "Gateway.gateway.networking.k8s.io"},
+		{in: "HTTPRoute", expGroup: "gateway.networking.k8s.io", expName: "HTTPRoute.gateway.networking.k8s.io"},
+		{in: "WorkloadGroup", expGroup: "networking.istio.io", expName: "WorkloadGroup"},
+		{in: "ProxyConfig", expGroup: "networking.istio.io", expName: "ProxyConfig"},
+		{in: "Telemetry", expGroup: "telemetry.istio.io", expName: "Telemetry"},
+		{in: "WasmPlugin", expGroup: "extensions.istio.io", expName: "WasmPlugin"},
+	} {
+		t.Run(c.in, func(t *testing.T) {
+			actual, err := lookupConfigResource(c.in)
+			require.NoError(t, err)
+			require.Equal(t, c.expGroup, actual.Group)
+			require.Equal(t, c.expName, actual.name())
+		})
+	}
+
+	for _, in := range []string{"Service", "HTTPRoute.networking.istio.io"} {
+		_, err := lookupConfigResource(in)
+		require.Error(t, err)
+	}
+}
+
+func Test_configResourceOf(t *testing.T) {
+	actual, ok := configResourceOf("networking.istio.io/v1beta1", "Gateway")
+	require.True(t, ok)
+	require.True(t, actual.kiali)
+
+	actual, ok = configResourceOf("gateway.networking.k8s.io/v1beta1", "Gateway")
+	require.True(t, ok)
+	require.False(t, actual.kiali)
+	require.Equal(t, "gateways", actual.Resource)
+
+	actual, ok = configResourceOf("telemetry.istio.io/v1alpha1", "Telemetry")
+	require.True(t, ok)
+	require.False(t, actual.kiali)
+
+	for _, c := range []struct{ apiVersion, kind string }{
+		{apiVersion: "v1", kind: "Service"},
+		{apiVersion: "", kind: "Gateway"},
+		{apiVersion: "a/b/c", kind: "Gateway"},
+	} {
+		_, ok = configResourceOf(c.apiVersion, c.kind)
+		require.False(t, ok)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Rule is the custom validation rule applied to the Istio objects in addition to istioctl analyze and Kiali
+type Rule interface {
+	// Code returns the error code of the found issues, which must not be prefixed by 'IST' or 'KIA'
+	Code() string
+	// Kinds returns the kinds of the objects the rule applies to.
+	// The kinds other than Istio's are qualified by the group, e.g. Gateway.gateway.networking.k8s.io
+	Kinds() []string
+	Severity() Severity
+	// Check returns the messages of the issues found in the object, or nil if the object complies with the rule
+	Check(obj *unstructured.Unstructured) ([]string, error)
+}
+
+// ruleResources returns the resources of the kinds to which the rules apply
+func ruleResources(rules []Rule) ([]configResource, error) {
+	var ret []configResource
+	seen := map[schema.GroupKind]struct{}{}
+	for _, r := range rules {
+		for _, k := range r.Kinds() {
+			res, err := lookupConfigResource(k)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule %s: %w", r.Code(), err)
+			}
+			if _, ok := seen[res.groupKind()]; !ok {
+				seen[res.groupKind()] = struct{}{}
+				ret = append(ret, res)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// ruleAppliesTo returns true if the rule applies to the kind of the resource
+func ruleAppliesTo(r Rule, res configResource) bool {
+	for _, k := range r.Kinds() {
+		if l, err := lookupConfigResource(k); err == nil && l.groupKind() == res.groupKind() {
+			return true
+		}
+	}
+	return false
+}
+
+// celRule is the declarative rule whose expression is written in CEL (https://github.com/google/cel-spec).
+// The object is bound to "object", and the issue is found when the expression evaluates to false.
+type celRule struct {
+	CodeValue     string   `yaml:"code"`
+	KindsValue    []string `yaml:"kinds"`
+	SeverityValue string   `yaml:"severity"`
+	Message       string   `yaml:"message"`
+	Expression    string   `yaml:"expression"`
+
+	severity Severity
+	program  cel.Program
+}
+
+type ruleFile struct {
+	Rules []*celRule `yaml:"rules"`
+}
+
+// LoadRules reads the declarative rules in the rule file
+func LoadRules(path string) ([]Rule, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the rule file %s: %w", path, err)
+	}
+
+	var f ruleFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse the rule file %s: %w", path, err)
+	}
+
+	env, err := cel.NewEnv(cel.Declarations(decls.NewVar("object", decls.Dyn)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	ret := make([]Rule, len(f.Rules))
+	for i, r := range f.Rules {
+		if err := r.compile(env); err != nil {
+			return nil, fmt.Errorf("invalid rule #%d in %s: %w", i+1, path, err)
+		}
+		ret[i] = r
+	}
+	return ret, nil
+}
+
+func (r *celRule) compile(env *cel.Env) error {
+	if err := validateRuleCode(r.CodeValue); err != nil {
+		return err
+	}
+
+	if len(r.KindsValue) == 0 {
+		return errors.New("kinds is required")
+	}
+	for _, k := range r.KindsValue {
+		if _, err := lookupConfigResource(k); err != nil {
+			return err
+		}
+	}
+
+	if r.SeverityValue == "" {
+		r.severity = SeverityLevelWarn
+	} else if s, ok := strToSeverityLevel[r.SeverityValue]; ok && s != SeverityLevelUnknown {
+		r.severity = s
+	} else {
+		return fmt.Errorf("invalid severity %q: must be one of %v", r.SeverityValue, SeverityNames)
+	}
+
+	if r.Message == "" {
+		return errors.New("message is required")
+	}
+
+	ast, iss := env.Compile(r.Expression)
+	if iss != nil && iss.Err() != nil {
+		return fmt.Errorf("invalid expression: %w", iss.Err())
+	}
+	if !proto.Equal(ast.ResultType(), decls.Bool) {
+		return fmt.Errorf("expression must evaluate to bool but %s", checker.FormatCheckedType(ast.ResultType()))
+	}
+
+	var err error
+	if r.program, err = env.Program(ast); err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+	return nil
+}
+
+func validateRuleCode(code string) error {
+	if code == "" {
+		return errors.New("code is required")
+	}
+
+	for _, p := range []string{"IST", "KIA"} {
+		if strings.HasPrefix(strings.ToUpper(code), p) {
+			return fmt.Errorf("code %s must not be prefixed by %s which is used by the built-in validations", code, p)
+		}
+	}
+	return nil
+}
+
+func (r *celRule) Code() string {
+	return r.CodeValue
+}
+
+func (r *celRule) Kinds() []string {
+	return r.KindsValue
+}
+
+func (r *celRule) Severity() Severity {
+	return r.severity
+}
+
+func (r *celRule) Check(obj *unstructured.Unstructured) ([]string, error) {
+	out, _, err := r.program.Eval(map[string]interface{}{"object": obj.Object})
+	if err != nil {
+		return nil, err
+	}
+
+	ok, isBool := out.Value().(bool)
+	if !isBool {
+		return nil, fmt.Errorf("expression evaluated to %v which is not bool", out.Value())
+	} else if ok {
+		return nil, nil
+	}
+	return []string{r.Message}, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testRules = `
+rules:
+- code: ACME001
+  kinds: [VirtualService]
+  message: every HTTP route must set the timeout
+  expression: "!has(object.spec.http) || object.spec.http.all(r, has(r.timeout))"
+- code: ACME002
+  kinds: [Gateway]
+  severity: Error
+  message: wildcard hosts are only allowed in the Gateways in istio-system
+  expression: "object.metadata.namespace == 'istio-system' || object.spec.servers.all(s, s.hosts.all(h, !h.contains('*')))"
+`
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("ok", func(t *testing.T) {
+		p := filepath.Join(dir, "ok.yaml")
+		require.NoError(t, ioutil.WriteFile(p, []byte(testRules), 0644))
+
+		actual, err := LoadRules(p)
+		require.NoError(t, err)
+		require.Len(t, actual, 2)
+		require.Equal(t, "ACME001", actual[0].Code())
+		require.Equal(t, SeverityLevelWarn, actual[0].Severity())
+		require.Equal(t, []string{"Gateway"}, actual[1].Kinds())
+		require.Equal(t, SeverityLevelError, actual[1].Severity())
+
+		resources, err := ruleResources(actual)
+		require.NoError(t, err)
+		require.Equal(t, []string{"VirtualService", "Gateway"}, []string{resources[0].name(), resources[1].name()})
+	})
+
+	for _, c := range []struct{ name, rule, exp string }{
+		{name: "no code", rule: "kinds: [Gateway]\n  message: m\n  expression: 'true'", exp: "code is required"},
+		{name: "reserved code", rule: "code: KIA9999\n  kinds: [Gateway]\n  message: m\n  expression: 'true'", exp: "must not be prefixed by KIA"},
+		{name: "no kinds", rule: "code: ACME001\n  message: m\n  expression: 'true'", exp: "kinds is required"},
+		{name: "unknown kind", rule: "code: ACME001\n  kinds: [Service]\n  message: m\n  expression: 'true'", exp: "unsupported kind"},
+		{name: "invalid severity", rule: "code: ACME001\n  kinds: [Gateway]\n  severity: Fatal\n  message: m\n  expression: 'true'", exp: "invalid severity"},
+		{name: "no message", rule: "code: ACME001\n  kinds: [Gateway]\n  expression: 'true'", exp: "message is required"},
+		{name: "syntax error", rule: "code: ACME001\n  kinds: [Gateway]\n  message: m\n  expression: 'object.spec.'", exp: "invalid expression"},
+		{name: "not bool", rule: "code: ACME001\n  kinds: [Gateway]\n  message: m\n  expression: '1 + 1'", exp: "must evaluate to bool"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			p := filepath.Join(dir, "invalid.yaml")
+			require.NoError(t, ioutil.WriteFile(p, []byte("rules:\n- "+c.rule+"\n"), 0644))
+			_, err := LoadRules(p)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.exp)
+		})
+	}
+}
+
+func TestCelRule_Check(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(testRules)
+	require.NoError(t, err)
+
+	rules, err := LoadRules(f.Name())
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		name string
+		rule Rule
+		obj  map[string]interface{}
+		exp  []string
+	}{
+		{
+			name: "timeout set",
+			rule: rules[0],
+			obj: map[string]interface{}{"spec": map[string]interface{}{
+				"http": []interface{}{map[string]interface{}{"timeout": "10s"}},
+			}},
+		},
+		{
+			name: "timeout missing",
+			rule: rules[0],
+			obj: map[string]interface{}{"spec": map[string]interface{}{
+				"http": []interface{}{map[string]interface{}{"timeout": "10s"}, map[string]interface{}{}},
+			}},
+			exp: []string{"every HTTP route must set the timeout"},
+		},
+		{
+			name: "tcp only",
+			rule: rules[0],
+			obj:  map[string]interface{}{"spec": map[string]interface{}{"tcp": []interface{}{}}},
+		},
+		{
+			name: "wildcard in istio-system",
+			rule: rules[1],
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"namespace": "istio-system"},
+				"spec": map[string]interface{}{"servers": []interface{}{
+					map[string]interface{}{"hosts": []interface{}{"*"}},
+				}},
+			},
+		},
+		{
+			name: "wildcard in bookinfo",
+			rule: rules[1],
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"namespace": "bookinfo"},
+				"spec": map[string]interface{}{"servers": []interface{}{
+					map[string]interface{}{"hosts": []interface{}{"bookinfo.example.com"}},
+					map[string]interface{}{"hosts": []interface{}{"*.example.com"}},
+				}},
+			},
+			exp: []string{"wildcard hosts are only allowed in the Gateways in istio-system"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := c.rule.Check(&unstructured.Unstructured{Object: c.obj})
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+		})
+	}
+
+	// no such key
+	_, err = rules[1].Check(&unstructured.Unstructured{Object: map[string]interface{}{}})
+	require.Error(t, err)
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+// SuppressionFileName is the suppression file read from the current directory by default
+const SuppressionFileName = ".getmesh-validate.yaml"
+
+// Suppression hides the known issues matching all of the non-empty fields of code, namespace, name and resourceType
+type Suppression struct {
+	Code         string `yaml:"code"`
+	Namespace    string `yaml:"namespace"`
+	Name         string `yaml:"name"`
+	ResourceType string `yaml:"resourceType"`
+	// why the issue is accepted, which is mandatory
+	Reason string `yaml:"reason"`
+	// the date (e.g. 2021-12-31) from which the suppression is no longer applied
+	Expires string `yaml:"expires"`
+
+	expires time.Time
+}
+
+type suppressionFile struct {
+	Suppressions []Suppression `yaml:"suppressions"`
+}
+
+// LoadSuppressions reads the suppression file. The missing default suppression file is not an error.
+func LoadSuppressions(path string) ([]Suppression, error) {
+	raw, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == SuppressionFileName {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the suppression file %s: %w", path, err)
+	}
+
+	var f suppressionFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse the suppression file %s: %w", path, err)
+	}
+
+	for i := range f.Suppressions {
+		if err := f.Suppressions[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid suppression #%d in %s: %w", i+1, path, err)
+		}
+	}
+	return f.Suppressions, nil
+}
+
+// activeSuppressions returns the suppressions which have not expired at now, and warns the expired ones
+func activeSuppressions(in []Suppression, now time.Time) []Suppression {
+	var ret []Suppression
+	for _, s := range in {
+		if !s.expires.IsZero() && !now.Before(s.expires) {
+			logger.Warnf("The suppression of %s expired on %s so the matching issues are reported again\n",
+				s, s.expires.Format("2006-01-02"))
+			continue
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func (s *Suppression) validate() error {
+	if s.Code == "" && s.Namespace == "" && s.Name == "" && s.ResourceType == "" {
+		return errors.New("at least one of code, namespace, name or resourceType is required")
+	}
+	if strings.TrimSpace(s.Reason) == "" {
+		return errors.New("reason is required")
+	}
+
+	if s.Expires != "" {
+		t, err := time.Parse("2006-01-02", s.Expires)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, s.Expires); err != nil {
+				return fmt.Errorf("invalid expires %q: must be a date like 2021-12-31", s.Expires)
+			}
+		}
+		s.expires = t
+	}
+	return nil
+}
+
+func (s Suppression) matches(r configValidationResult) bool {
+	return (s.Code == "" || strings.EqualFold(s.Code, r.errorCode)) &&
+		(s.Namespace == "" || s.Namespace == r.namespace) &&
+		(s.Name == "" || s.Name == r.name) &&
+		(s.ResourceType == "" || strings.EqualFold(s.ResourceType, r.resourceType))
+}
+
+func (s Suppression) String() string {
+	var ret []string
+	for _, f := range []struct{ key, value string }{
+		{"code", s.Code}, {"namespace", s.Namespace}, {"name", s.Name}, {"resourceType", s.ResourceType},
+	} {
+		if f.value != "" {
+			ret = append(ret, f.key+"="+f.value)
+		}
+	}
+	return strings.Join(ret, ",")
+}
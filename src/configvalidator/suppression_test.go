@@ -0,0 +1,114 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+func TestLoadSuppressions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	t.Run("missing default file", func(t *testing.T) {
+		actual, err := LoadSuppressions(SuppressionFileName)
+		require.NoError(t, err)
+		require.Nil(t, actual)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadSuppressions(filepath.Join(dir, "non-exist.yaml"))
+		require.Error(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		p := filepath.Join(dir, "ok.yaml")
+		require.NoError(t, ioutil.WriteFile(p, []byte(`
+suppressions:
+- code: KIA0302
+  namespace: bookinfo
+  reason: deployed by another team
+- name: reviews
+  resourceType: VirtualService
+  reason: migrating
+  expires: 2021-06-01
+`), 0644))
+
+		actual, err := LoadSuppressions(p)
+		require.NoError(t, err)
+		require.Len(t, actual, 2)
+		require.Equal(t, "code=KIA0302,namespace=bookinfo", actual[0].String())
+		require.Equal(t, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), actual[1].expires)
+	})
+
+	for _, c := range []struct{ name, content, exp string }{
+		{name: "no reason", content: "suppressions:\n- code: KIA0302\n", exp: "reason is required"},
+		{name: "no matcher", content: "suppressions:\n- reason: foo\n", exp: "at least one of"},
+		{name: "invalid expires", content: "suppressions:\n- code: KIA0302\n  reason: foo\n  expires: tomorrow\n", exp: "invalid expires"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			p := filepath.Join(dir, "invalid.yaml")
+			require.NoError(t, ioutil.WriteFile(p, []byte(c.content), 0644))
+			_, err := LoadSuppressions(p)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.exp)
+		})
+	}
+}
+
+func Test_activeSuppressions(t *testing.T) {
+	in := []Suppression{
+		{Code: "KIA0302", Reason: "foo"},
+		{Code: "IST0101", Reason: "bar", expires: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	var actual []Suppression
+	buf := logger.ExecuteWithLock(func() {
+		actual = activeSuppressions(in, time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC))
+	})
+	require.Equal(t, in, actual)
+	require.Empty(t, buf.String())
+
+	buf = logger.ExecuteWithLock(func() {
+		actual = activeSuppressions(in, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	})
+	require.Equal(t, in[:1], actual)
+	require.Contains(t, buf.String(), "The suppression of code=IST0101 expired on 2021-06-01")
+}
+
+func TestSuppression_matches(t *testing.T) {
+	r := configValidationResult{namespace: "bookinfo", name: "reviews", resourceType: "virtualservice", errorCode: "KIA1107"}
+	for _, c := range []struct {
+		s   Suppression
+		exp bool
+	}{
+		{s: Suppression{Code: "KIA1107"}, exp: true},
+		{s: Suppression{Code: "kia1107", ResourceType: "VirtualService"}, exp: true},
+		{s: Suppression{Namespace: "bookinfo", Name: "reviews"}, exp: true},
+		{s: Suppression{Code: "KIA1107", Namespace: "default"}, exp: false},
+		{s: Suppression{Name: "ratings"}, exp: false},
+	} {
+		require.Equal(t, c.exp, c.s.matches(r), c.s.String())
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+// configObject is the object of the config resources in the local file or the cluster
+type configObject struct {
+	*unstructured.Unstructured
+	resource configResource
+	// the local file of the object, or empty if it's in the cluster
+	file string
+}
+
+func (o configObject) key() string {
+	return kialiObjectKey(o.GetNamespace(), o.GetName(), o.resource.name())
+}
+
+// customRuleValidations applies the custom rules to the objects in the cluster and the local files
+func (cv *ConfigValidator) customRuleValidations() ([]configValidationResult, error) {
+	if len(cv.rules) == 0 {
+		return nil, nil
+	}
+
+	resources, err := ruleResources(cv.rules)
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := cv.configObjects(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []configValidationResult
+	for _, obj := range objs {
+		for _, r := range cv.rules {
+			if !ruleAppliesTo(r, obj.resource) {
+				continue
+			}
+
+			msgs, err := r.Check(obj.Unstructured)
+			if err != nil {
+				logger.Warnf("failed to apply the rule %s to %s %s/%s: %v\n",
+					r.Code(), obj.resource.name(), obj.GetNamespace(), obj.GetName(), err)
+				continue
+			}
+
+			for _, msg := range msgs {
+				if obj.file != "" {
+					msg = fmt.Sprintf("[%s] %s", obj.file, msg)
+				}
+				ret = append(ret, configValidationResult{
+					name:         obj.GetName(),
+					namespace:    obj.GetNamespace(),
+					resourceType: obj.resource.name(),
+					severity:     r.Severity(),
+					message:      msg,
+					errorCode:    r.Code(),
+				})
+			}
+		}
+	}
+	return ret, nil
+}
+
+// configObjects returns the objects of the resources in the local files and, unless offline, in the cluster.
+// The objects in the cluster are overridden by the local ones.
+func (cv *ConfigValidator) configObjects(resources []configResource) ([]configObject, error) {
+	locals, err := parseFilesAsConfigObjects(cv.files, cv.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prase local yaml files: %w", err)
+	}
+
+	var ret []configObject
+	overridden := map[string]struct{}{}
+	for _, obj := range locals {
+		for _, res := range resources {
+			if obj.resource.groupKind() == res.groupKind() {
+				ret = append(ret, obj)
+				overridden[obj.key()] = struct{}{}
+			}
+		}
+	}
+
+	if cv.offline {
+		return ret, nil
+	}
+
+	served, err := cv.servedGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, res := range resources {
+		gvr, ok := servedResource(served, res)
+		if !ok {
+			// the CRD is not installed
+			continue
+		}
+
+		list, err := cv.dynamic.Resource(gvr).Namespace(cv.namespace).List(context.Background(), metav1.ListOptions{})
+		if errors.IsNotFound(err) {
+			// the CRD is not installed
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", res.name(), err)
+		}
+
+		for i := range list.Items {
+			obj := configObject{Unstructured: &list.Items[i], resource: res}
+			obj.SetAPIVersion(gvr.GroupVersion().String())
+			obj.SetKind(res.kind)
+			if _, ok := overridden[obj.key()]; !ok {
+				ret = append(ret, obj)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// servedGroups returns {group -> versions} served by the cluster, or nil if the discovery is not available
+func (cv *ConfigValidator) servedGroups() (map[string]metav1.APIGroup, error) {
+	if cv.kubeCli == nil {
+		return nil, nil
+	}
+
+	list, err := cv.kubeCli.Discovery().ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the API groups: %w", err)
+	}
+
+	ret := make(map[string]metav1.APIGroup, len(list.Groups))
+	for _, g := range list.Groups {
+		ret[g.Name] = g
+	}
+	return ret, nil
+}
+
+// servedResource returns the resource in the version served by the cluster, e.g. v1alpha2 or v1 of the Gateway API,
+// and false if its group is not served. The version of the resource is preferred if served, otherwise the preferred one
+func servedResource(served map[string]metav1.APIGroup, res configResource) (schema.GroupVersionResource, bool) {
+	gvr := res.GroupVersionResource
+	if served == nil {
+		return gvr, true
+	}
+
+	g, ok := served[res.Group]
+	if !ok {
+		return gvr, false
+	}
+	for _, v := range g.Versions {
+		if v.Version == res.Version {
+			return gvr, true
+		}
+	}
+	gvr.Version = g.PreferredVersion.Version
+	return gvr, gvr.Version != ""
+}
+
+// parseFilesAsConfigObjects parses the objects of configResources in the files
+// with the default namespace just like parseFilesAsKialiIstioObjects
+func parseFilesAsConfigObjects(files []string, namespace string) ([]configObject, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	var ret []configObject
+	for _, f := range files {
+		raw, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		r := yaml.NewDecoder(bytes.NewReader(raw))
+		for {
+			var m map[string]interface{}
+			if err := r.Decode(&m); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s: %v", f, err)
+			}
+
+			apiVersion, _ := m["apiVersion"].(string)
+			kind, _ := m["kind"].(string)
+			res, ok := configResourceOf(apiVersion, kind)
+			if !ok {
+				continue
+			}
+
+			// round trip via json so that the numbers are typed as the objects in the cluster
+			j, err := json.Marshal(m)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s: %v", f, err)
+			}
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(j); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s: %v", f, err)
+			}
+
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(namespace)
+			}
+			ret = append(ret, configObject{Unstructured: obj, resource: res, file: f})
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,310 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configvalidator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testGateway(namespace, name string, hosts ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1alpha3",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec": map[string]interface{}{"servers": []interface{}{
+			map[string]interface{}{"hosts": hosts},
+		}},
+	}}
+}
+
+func TestConfigValidator_customRuleValidations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(p, []byte(testRules), 0644))
+	rules, err := LoadRules(p)
+	require.NoError(t, err)
+
+	gateway, err := lookupConfigResource("Gateway")
+	require.NoError(t, err)
+	virtualService, err := lookupConfigResource("VirtualService")
+	require.NoError(t, err)
+	gvr := gateway.GroupVersionResource
+	dyn := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gvr:                                 "GatewayList",
+		virtualService.GroupVersionResource: "VirtualServiceList",
+	})
+	// created via the client since the fake tracker guesses the resource of Gateway as "gatewaies"
+	for _, gw := range []*unstructured.Unstructured{
+		testGateway("istio-system", "ingress", "*"),
+		testGateway("bookinfo", "bookinfo-gateway", "*"),
+		testGateway("bookinfo", "fixed-gateway", "*"),
+	} {
+		_, err := dyn.Resource(gvr).Namespace(gw.GetNamespace()).Create(context.Background(), gw, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	// the local file fixes one of the Gateways in the cluster, and adds the VirtualService violating the rule
+	f := filepath.Join(dir, "bookinfo.yaml")
+	require.NoError(t, ioutil.WriteFile(f, []byte(`
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: fixed-gateway
+  namespace: bookinfo
+spec:
+  servers:
+    - port:
+        number: 80
+      hosts:
+        - bookinfo.example.com
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: reviews
+spec:
+  hosts:
+    - reviews
+  http:
+    - route:
+        - destination:
+            host: reviews
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: reviews
+`), 0644))
+
+	t.Run("online", func(t *testing.T) {
+		cv := &ConfigValidator{rules: rules, dynamic: dyn, files: []string{f}}
+		actual, err := cv.customRuleValidations()
+		require.NoError(t, err)
+		require.ElementsMatch(t, []configValidationResult{
+			{name: "reviews", namespace: "default", resourceType: "VirtualService", errorCode: "ACME001",
+				severity: SeverityLevelWarn, message: "[" + f + "] every HTTP route must set the timeout"},
+			{name: "bookinfo-gateway", namespace: "bookinfo", resourceType: "Gateway", errorCode: "ACME002",
+				severity: SeverityLevelError, message: "wildcard hosts are only allowed in the Gateways in istio-system"},
+		}, actual)
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		cv := &ConfigValidator{rules: rules, files: []string{f}, namespace: "bookinfo", offline: true}
+		actual, err := cv.customRuleValidations()
+		require.NoError(t, err)
+		require.Equal(t, []configValidationResult{
+			{name: "reviews", namespace: "bookinfo", resourceType: "VirtualService", errorCode: "ACME001",
+				severity: SeverityLevelWarn, message: "[" + f + "] every HTTP route must set the timeout"},
+		}, actual)
+	})
+
+	t.Run("no rules", func(t *testing.T) {
+		actual, err := (&ConfigValidator{}).customRuleValidations()
+		require.NoError(t, err)
+		require.Empty(t, actual)
+	})
+}
+
+func TestConfigValidator_customRuleValidations_resources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(p, []byte(`
+rules:
+- code: ACME010
+  kinds: [Gateway]
+  message: the Istio Gateway must not be used
+  expression: "false"
+- code: ACME011
+  kinds: [Gateway.gateway.networking.k8s.io, HTTPRoute]
+  message: must be labeled with the team
+  expression: "has(object.metadata.labels) && 'team' in object.metadata.labels"
+- code: ACME012
+  kinds: [Telemetry, WasmPlugin, WorkloadGroup, ProxyConfig]
+  message: must not be in the default namespace
+  expression: "object.metadata.namespace != 'default'"
+`), 0644))
+	rules, err := LoadRules(p)
+	require.NoError(t, err)
+
+	f := filepath.Join(dir, "apps.yaml")
+	require.NoError(t, ioutil.WriteFile(f, []byte(`
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: gw
+  namespace: apps
+spec:
+  gatewayClassName: istio
+---
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: HTTPRoute
+metadata:
+  name: route
+  namespace: apps
+  labels:
+    team: a
+---
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: telemetry
+---
+apiVersion: extensions.istio.io/v1alpha1
+kind: WasmPlugin
+metadata:
+  name: wasm
+  namespace: apps
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: WorkloadGroup
+metadata:
+  name: vm
+---
+apiVersion: networking.istio.io/v1beta1
+kind: ProxyConfig
+metadata:
+  name: proxy
+  namespace: apps
+`), 0644))
+
+	cv := &ConfigValidator{rules: rules, files: []string{f}, offline: true}
+	actual, err := cv.customRuleValidations()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []configValidationResult{
+		{name: "gw", namespace: "apps", resourceType: "Gateway.gateway.networking.k8s.io", errorCode: "ACME011",
+			severity: SeverityLevelWarn, message: "[" + f + "] must be labeled with the team"},
+		{name: "telemetry", namespace: "default", resourceType: "Telemetry", errorCode: "ACME012",
+			severity: SeverityLevelWarn, message: "[" + f + "] must not be in the default namespace"},
+		{name: "vm", namespace: "default", resourceType: "WorkloadGroup", errorCode: "ACME012",
+			severity: SeverityLevelWarn, message: "[" + f + "] must not be in the default namespace"},
+	}, actual)
+}
+
+func TestConfigValidator_configObjects_gatewayAPI(t *testing.T) {
+	gateway, err := lookupConfigResource("Gateway.gateway.networking.k8s.io")
+	require.NoError(t, err)
+	istioGateway, err := lookupConfigResource("Gateway")
+	require.NoError(t, err)
+	dyn := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		gateway.GroupVersionResource:      "GatewayList",
+		istioGateway.GroupVersionResource: "GatewayList",
+	})
+	gw := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1beta1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": "gw", "namespace": "apps"},
+	}}
+	_, err = dyn.Resource(gateway.GroupVersionResource).Namespace("apps").Create(context.Background(), gw, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cv := &ConfigValidator{dynamic: dyn}
+	actual, err := cv.configObjects([]configResource{gateway})
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, "gw", actual[0].GetName())
+	require.Equal(t, gateway, actual[0].resource)
+	require.Empty(t, actual[0].file)
+
+	// the Gateway of the Gateway API is not listed as the Istio Gateway
+	actual, err = cv.configObjects([]configResource{istioGateway})
+	require.NoError(t, err)
+	require.Empty(t, actual)
+}
+
+func TestConfigValidator_configObjects_servedVersion(t *testing.T) {
+	gateway, err := lookupConfigResource("Gateway.gateway.networking.k8s.io")
+	require.NoError(t, err)
+	telemetry, err := lookupConfigResource("Telemetry")
+	require.NoError(t, err)
+
+	// the cluster only serves v1 of the Gateway API, and Telemetry is not installed
+	served := gateway.GroupVersionResource
+	served.Version = "v1"
+	dyn := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		served: "GatewayList",
+	})
+	_, err = dyn.Resource(served).Namespace("apps").Create(context.Background(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": "gw", "namespace": "apps"},
+	}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	kubeCli := fake.NewSimpleClientset()
+	kubeCli.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "gateway.networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "gateways", Kind: "Gateway"}}},
+	}
+
+	cv := &ConfigValidator{dynamic: dyn, kubeCli: kubeCli}
+	actual, err := cv.configObjects([]configResource{gateway, telemetry})
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, "gw", actual[0].GetName())
+	require.Equal(t, "gateway.networking.k8s.io/v1", actual[0].GetAPIVersion())
+	require.Equal(t, gateway, actual[0].resource)
+}
+
+func Test_servedResource(t *testing.T) {
+	gateway, err := lookupConfigResource("Gateway.gateway.networking.k8s.io")
+	require.NoError(t, err)
+
+	versions := func(preferred string, vs ...string) metav1.APIGroup {
+		g := metav1.APIGroup{Name: gatewayAPIGroup, PreferredVersion: metav1.GroupVersionForDiscovery{Version: preferred}}
+		for _, v := range vs {
+			g.Versions = append(g.Versions, metav1.GroupVersionForDiscovery{Version: v})
+		}
+		return g
+	}
+
+	for _, c := range []struct {
+		name    string
+		served  map[string]metav1.APIGroup
+		version string
+		ok      bool
+	}{
+		{name: "no discovery", version: "v1beta1", ok: true},
+		{name: "not installed", served: map[string]metav1.APIGroup{}},
+		{name: "served", served: map[string]metav1.APIGroup{gatewayAPIGroup: versions("v1", "v1", "v1beta1")},
+			version: "v1beta1", ok: true},
+		{name: "v1alpha2 only", served: map[string]metav1.APIGroup{gatewayAPIGroup: versions("v1alpha2", "v1alpha2")},
+			version: "v1alpha2", ok: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			gvr, ok := servedResource(c.served, gateway)
+			require.Equal(t, c.ok, ok)
+			if ok {
+				require.Equal(t, c.version, gvr.Version)
+			}
+		})
+	}
+}
@@ -26,7 +26,22 @@ import (
 )
 
 func (cv *ConfigValidator) istioAnalyseValidations() ([]configValidationResult, error) {
+	out := new(bytes.Buffer)
+	stde := new(bytes.Buffer)
+	err := istioctl.ExecWithWriters(cv.getmeshHomedir, cv.istioAnalyzeArgs(), out, stde)
+	if err != nil && !strings.Contains(stde.String(), "Error: Analyzers found issues when") {
+		return nil, errors.New(stde.String())
+	}
+
+	return parseIstioctlAnalyzeResult(out), nil
+}
+
+func (cv *ConfigValidator) istioAnalyzeArgs() []string {
 	var analyzeCommandArgs = []string{"analyze", "--color=false"}
+	if cv.offline {
+		// analyze the local files only
+		analyzeCommandArgs = append(analyzeCommandArgs, "--use-kube=false")
+	}
 
 	if cv.allNamespaces() {
 		analyzeCommandArgs = append(analyzeCommandArgs, "--all-namespaces")
@@ -38,15 +53,7 @@ func (cv *ConfigValidator) istioAnalyseValidations() ([]configValidationResult,
 		analyzeCommandArgs = append(analyzeCommandArgs, "-R")
 		analyzeCommandArgs = append(analyzeCommandArgs, cv.files...)
 	}
-
-	out := new(bytes.Buffer)
-	stde := new(bytes.Buffer)
-	err := istioctl.ExecWithWriters(cv.getmeshHomedir, analyzeCommandArgs, out, stde)
-	if err != nil && !strings.Contains(stde.String(), "Error: Analyzers found issues when") {
-		return nil, errors.New(stde.String())
-	}
-
-	return parseIstioctlAnalyzeResult(out), nil
+	return analyzeCommandArgs
 }
 
 func parseIstioctlAnalyzeResult(r io.Reader) []configValidationResult {
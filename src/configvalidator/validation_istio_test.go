@@ -67,3 +67,11 @@ func TestParseIstioctlAnalyzeResult(t *testing.T) {
 			parseIstioctlAnalyzeResult(bytes.NewBufferString(c.in)), c.in)
 	}
 }
+
+func TestConfigValidator_istioAnalyzeArgs(t *testing.T) {
+	cv := &ConfigValidator{namespace: "bookinfo", files: []string{"a.yaml"}}
+	require.Equal(t, []string{"analyze", "--color=false", "--namespace=bookinfo", "-R", "a.yaml"}, cv.istioAnalyzeArgs())
+
+	cv = &ConfigValidator{files: []string{"a.yaml"}, offline: true}
+	require.Equal(t, []string{"analyze", "--color=false", "--use-kube=false", "--all-namespaces", "-R", "a.yaml"}, cv.istioAnalyzeArgs())
+}
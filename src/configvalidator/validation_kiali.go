@@ -16,9 +16,14 @@ package configvalidator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	kiali_business "github.com/kiali/kiali/business"
 	kiali_config "github.com/kiali/kiali/config"
@@ -26,9 +31,9 @@ import (
 	kiali_log "github.com/kiali/kiali/log"
 	kiali_models "github.com/kiali/kiali/models"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 
 	"github.com/tetratelabs/getmesh/src/util"
+	"github.com/tetratelabs/getmesh/src/util/logger"
 )
 
 func init() {
@@ -38,66 +43,164 @@ func init() {
 
 // KialiConfigValidations returns Istio Validation Summary for the selected namespace and service requested.
 func (cv *ConfigValidator) kialiConfigValidations() ([]configValidationResult, error) {
+	if cv.offline {
+		return cv.kialiOfflineConfigValidations()
+	}
+
 	config, err := util.GetK8sConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubeconf")
 	}
 
+	// bound each request of the validation of each namespace,
+	// so that a given-up validation doesn't keep querying the API server
+	var timeout time.Duration
+	if cv.allNamespaces() {
+		timeout = cv.namespaceTimeout
+	}
+	config.Timeout = timeout
 	raw, err := kiali_kubernetes.NewClientFromConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate kiali client")
 	}
 
-	wrapper, err := newKialiClientWrapper(raw, cv.files, cv.namespace)
+	wrapper, err := newKialiClientWrapper(raw, cv.files, cv.namespace, timeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create wrapper of Kiali client: %w", err)
 	}
 
-	kialiBackend := kiali_business.NewWithBackends(wrapper, nil, nil)
 	var ret []configValidationResult
 	if cv.allNamespaces() {
-		ret, err = kialiAllNamespaceConfigValidation(cv.kubeCli, kialiBackend.Validations)
+		var namespaces []namespaceInfo
+		if namespaces, err = cv.clusterNamespaces(); err != nil {
+			return nil, err
+		}
+		ret, err = cv.kialiAllNamespaceConfigValidation(namespaces, func() kiali_business.IstioValidationsService {
+			return kiali_business.NewWithBackends(wrapper, nil, nil).Validations
+		})
 	} else {
-		ret, err = kialiSingleNamespaceConfigValidation(kialiBackend.Validations, cv.namespace)
+		ret, err = kialiSingleNamespaceConfigValidation(kiali_business.NewWithBackends(wrapper, nil, nil).Validations, cv.namespace)
 	}
 
 	if err != nil {
 		return nil, err
 	}
+	return withFileReferences(ret, wrapper.localIstioObjectFilesRef), nil
+}
 
-	for i, r := range ret {
-		// check if there's file reference for local file validations, and if found, append the information to messages
-		// just like Istioctl analyze does for local files
-		key := kialiObjectKey(r.namespace, r.name, r.resourceType)
-		if f, ok := wrapper.localIstioObjectFilesRef[key]; ok {
-			ret[i].message = fmt.Sprintf("[%s] %s", f, r.message)
-		}
+func (cv *ConfigValidator) clusterNamespaces() ([]namespaceInfo, error) {
+	nsList, err := cv.kubeCli.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
 	}
-	return ret, nil
 
+	ret := make([]namespaceInfo, len(nsList.Items))
+	for i, ns := range nsList.Items {
+		ret[i] = namespaceInfo{name: ns.Name, labels: ns.Labels}
+	}
+	return ret, nil
 }
 
-func kialiAllNamespaceConfigValidation(kubeCli kubernetes.Interface,
-	kialiIstioValidationService kiali_business.IstioValidationsService) ([]configValidationResult, error) {
-	nsList, err := kubeCli.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+// kialiOfflineConfigValidations validates the local files by Kiali without accessing the cluster
+func (cv *ConfigValidator) kialiOfflineConfigValidations() ([]configValidationResult, error) {
+	client, err := newKialiOfflineClient(cv.files, cv.namespace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		return nil, fmt.Errorf("failed to create offline Kiali client: %w", err)
+	}
+
+	var ret []configValidationResult
+	if cv.allNamespaces() {
+		ret, err = cv.kialiAllNamespaceConfigValidation(client.namespaces(), func() kiali_business.IstioValidationsService {
+			return kiali_business.NewWithBackends(client, nil, nil).Validations
+		})
+	} else {
+		ret, err = kialiSingleNamespaceConfigValidation(kiali_business.NewWithBackends(client, nil, nil).Validations, cv.namespace)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return withFileReferences(ret, client.localIstioObjectFilesRef), nil
+}
+
+// withFileReferences appends the file names to the messages of the results for the local objects
+// just like Istioctl analyze does for local files
+func withFileReferences(results []configValidationResult, refs map[string]string) []configValidationResult {
+	for i, r := range results {
+		key := kialiObjectKey(r.namespace, r.name, r.resourceType)
+		if f, ok := refs[key]; ok {
+			results[i].message = fmt.Sprintf("[%s] %s", f, r.message)
+		}
+	}
+	return results
+}
+
+// kialiAllNamespaceConfigValidation validates the selected namespaces concurrently up to cv.concurrency.
+// newService is called per namespace since Kiali's business layer is not safe for concurrent use.
+// The selected namespaces are kept in cv.selectedNamespaces to filter the other validations' results.
+func (cv *ConfigValidator) kialiAllNamespaceConfigValidation(namespaces []namespaceInfo,
+	newService func() kiali_business.IstioValidationsService) ([]configValidationResult, error) {
+	names := cv.namespaceSelection.selects(namespaces)
+	if !cv.namespaceSelection.empty() {
+		cv.selectedNamespaces = make(map[string]struct{}, len(names))
+		for _, ns := range names {
+			cv.selectedNamespaces[ns] = struct{}{}
+		}
+	}
+
+	concurrency := cv.concurrency
+	if concurrency < 1 {
+		concurrency = 1
 	}
 
 	var (
 		vs        = kiali_models.IstioValidations{}
 		errorList []error
+		skipped   []string
+		done      int
+		progress  = cv.output != OutputJSON && stdoutIsTerminal()
+		mux       sync.Mutex
+		wg        sync.WaitGroup
+		sem       = make(chan struct{}, concurrency)
 	)
 
-	for _, ns := range nsList.Items {
-		validations, err := kialiIstioValidationService.GetValidations(ns.Name, "")
-		if err != nil && !strings.Contains(err.Error(), "excluded for Kiali") {
-			errorList = append(errorList, err)
-			continue
-		}
+	for _, ns := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ns string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			validations, err := validateNamespaceWithTimeout(newService(), ns, cv.namespaceTimeout)
+
+			mux.Lock()
+			defer mux.Unlock()
+			done++
+			if progress {
+				logger.Infof("\rValidated %d/%d namespaces by Kiali", done, len(names))
+			}
 
-		vs.MergeValidations(validations)
+			if errors.Is(err, errNamespaceTimeout) {
+				skipped = append(skipped, ns)
+				return
+			}
+			if err != nil && !strings.Contains(err.Error(), "excluded for Kiali") {
+				errorList = append(errorList, err)
+				return
+			}
+			vs.MergeValidations(validations)
+		}(ns)
 	}
+	wg.Wait()
+	if len(names) > 0 && progress {
+		logger.Infof("\n\n")
+	}
+
+	// reported along with the results
+	sort.Strings(skipped)
+	cv.skippedNamespaces = skipped
 
 	if len(errorList) != 0 {
 		return nil, util.HandleMultipleErrors(errorList)
@@ -106,6 +209,43 @@ func kialiAllNamespaceConfigValidation(kubeCli kubernetes.Interface,
 	return convertKialiModelToResult(vs), nil
 }
 
+// errNamespaceTimeout is returned when the validation of a namespace is given up
+var errNamespaceTimeout = errors.New("validation timed out")
+
+// stdoutIsTerminal is a variable for test purpose
+var stdoutIsTerminal = func() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// validateNamespaceWithTimeout gives up the validation of the namespace after timeout unless it's zero.
+// The given-up validation keeps running in the background as Kiali's validation is not cancellable,
+// but each of its requests to the API server is bounded by the same timeout.
+func validateNamespaceWithTimeout(svc kiali_business.IstioValidationsService, namespace string,
+	timeout time.Duration) (kiali_models.IstioValidations, error) {
+	if timeout <= 0 {
+		return svc.GetValidations(namespace, "")
+	}
+
+	type result struct {
+		vs  kiali_models.IstioValidations
+		err error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		vs, err := svc.GetValidations(namespace, "")
+		ch <- result{vs, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.vs, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("namespace %s: %w after %s", namespace, errNamespaceTimeout, timeout)
+	}
+}
+
 func kialiSingleNamespaceConfigValidation(kialiIstioValidationService kiali_business.IstioValidationsService,
 	namespace string) ([]configValidationResult, error) {
 	vs, err := kialiIstioValidationService.GetValidations(namespace, "")
@@ -15,9 +15,15 @@
 package configvalidator
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/tetratelabs/getmesh/src/util"
@@ -26,6 +32,16 @@ import (
 
 var ErrConfigIssuesFound = errors.New("getmesh config validation exit with istio config issues")
 
+// ErrNamespacesSkipped is returned when some namespaces are not validated by Kiali as the validation timed out,
+// regardless of the issues found in the others
+var ErrNamespacesSkipped = errors.New("getmesh config validation skipped the namespaces timed out")
+
+// the output formats
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
 // ConfigValidator is general structure for validating istio config
 // preset in current live-cluster
 type ConfigValidator struct {
@@ -34,41 +50,161 @@ type ConfigValidator struct {
 	getmeshHomedir  string
 	outputThreshold Severity
 	files           []string
+	// offline validates the files without accessing the cluster
+	offline bool
+	output  string
+
+	// the custom rules and the client to list the objects they apply to
+	rules   []Rule
+	dynamic dynamic.Interface
+
+	// the options of validating all namespaces
+	namespaceSelection NamespaceSelection
+	concurrency        int
+	namespaceTimeout   time.Duration
+	// the namespaces selected by namespaceSelection, or nil if all namespaces are validated
+	selectedNamespaces map[string]struct{}
+	// the namespaces not validated by Kiali as the validation timed out
+	skippedNamespaces []string
+
+	suppressions []Suppression
+	// the keys of the issues found in the baseline
+	baseline                       map[string]struct{}
+	suppressedCount, baselineCount int
 }
 
-// InitConfigValidator initialize the ConfigValidator struct.
-func New(homedir, namespace, outputThreshold string, files []string) (*ConfigValidator, error) {
-	kubeCli, err := util.GetK8sClient()
-	if err != nil {
-		return nil, fmt.Errorf("error getting k8s client: %w", err)
-	}
+// Options is the options of the config validator given by the command line
+type Options struct {
+	Namespace       string
+	OutputThreshold string
+	// the yaml files or the directories
+	Files []string
+	// validate the files without accessing the cluster
+	Offline bool
+	// table or json
+	Output string
+	// the suppression file, which may not exist if it's SuppressionFileName
+	SuppressionFile string
+	// the JSON output of a previous run, whose issues are not reported
+	BaselineFile string
+	// the files of the declarative custom rules
+	RuleFiles []string
+	// the custom rules implemented in Go, which are applied along with the ones in RuleFiles
+	Rules []Rule
+	// the namespaces validated when Namespace is empty
+	NamespaceSelection NamespaceSelection
+	// the number of the namespaces validated concurrently by Kiali
+	Concurrency int
+	// the timeout of validating each namespace by Kiali, or no timeout if zero
+	NamespaceTimeout time.Duration
+}
 
-	files, err = extractYamlFilePaths(files)
+// InitConfigValidator initialize the ConfigValidator struct.
+func New(homedir string, opts Options) (*ConfigValidator, error) {
+	files, err := extractYamlFilePaths(opts.Files)
 	if err != nil {
 		return nil, fmt.Errorf("error walking thourgh paths: %v", err)
 	}
 
-	sv, ok := strToSeverityLevel[outputThreshold]
+	sv, ok := strToSeverityLevel[opts.OutputThreshold]
 	if !ok {
-		return nil, fmt.Errorf("invalid output-threshold %s", outputThreshold)
+		return nil, fmt.Errorf("invalid output-threshold %s", opts.OutputThreshold)
+	}
+
+	selection := opts.NamespaceSelection
+	if !selection.empty() && opts.Namespace != "" {
+		return nil, errors.New("the namespace selection can't be used with a specific namespace")
+	} else if err := selection.init(); err != nil {
+		return nil, err
+	}
+
+	output := opts.Output
+	if output == "" {
+		output = OutputTable
+	} else if output != OutputTable && output != OutputJSON {
+		return nil, fmt.Errorf("invalid output %q: must be %s or %s", output, OutputTable, OutputJSON)
+	}
+
+	var suppressions []Suppression
+	if opts.SuppressionFile != "" {
+		if suppressions, err = LoadSuppressions(opts.SuppressionFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var baseline map[string]struct{}
+	if opts.BaselineFile != "" {
+		if baseline, err = loadBaseline(opts.BaselineFile); err != nil {
+			return nil, err
+		}
+	}
+
+	rules := opts.Rules
+	for _, f := range opts.RuleFiles {
+		rs, err := LoadRules(f)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rs...)
+	}
+
+	var (
+		kubeCli kubernetes.Interface
+		dyn     dynamic.Interface
+	)
+	if opts.Offline {
+		if len(files) == 0 {
+			return nil, errors.New("--offline requires the yaml files or directories to validate")
+		}
+	} else {
+		config, err := util.GetK8sConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error getting k8s config: %w", err)
+		}
+		if kubeCli, err = kubernetes.NewForConfig(config); err != nil {
+			return nil, fmt.Errorf("error getting k8s client: %w", err)
+		}
+		if dyn, err = dynamic.NewForConfig(config); err != nil {
+			return nil, fmt.Errorf("error getting k8s dynamic client: %w", err)
+		}
 	}
 
 	return &ConfigValidator{
-		kubeCli:         kubeCli,
-		namespace:       namespace,
-		getmeshHomedir:  homedir,
-		outputThreshold: sv,
-		files:           files,
+		kubeCli:            kubeCli,
+		namespace:          opts.Namespace,
+		getmeshHomedir:     homedir,
+		outputThreshold:    sv,
+		files:              files,
+		offline:            opts.Offline,
+		output:             output,
+		rules:              rules,
+		dynamic:            dyn,
+		namespaceSelection: selection,
+		concurrency:        opts.Concurrency,
+		namespaceTimeout:   opts.NamespaceTimeout,
+		suppressions:       suppressions,
+		baseline:           baseline,
 	}, nil
 }
 
 func (cv *ConfigValidator) Validate() error {
+	w := logger.GetWriter()
+	if cv.output == OutputJSON {
+		// keep the progress messages out of the JSON
+		logger.SetWriter(os.Stderr)
+		defer logger.SetWriter(w)
+	}
+
 	msg := "Running the config validator"
+	if cv.offline {
+		msg += " offline"
+	}
 	if !cv.allNamespaces() {
 		msg += " for namespace=" + cv.namespace
 	}
 
 	logger.Infof(msg + ". This may take some time...\n\n")
+	cv.suppressions = activeSuppressions(cv.suppressions, time.Now())
 
 	kvs, err := cv.kialiConfigValidations()
 	if err != nil {
@@ -80,10 +216,46 @@ func (cv *ConfigValidator) Validate() error {
 		return fmt.Errorf("error istioctl validation: %w", err)
 	}
 
-	results := cv.filterResults(append(ivs, kvs...))
+	cvs, err := cv.customRuleValidations()
+	if err != nil {
+		return fmt.Errorf("error custom rule validation: %w", err)
+	}
+
+	return cv.report(w, append(append(ivs, kvs...), cvs...))
+}
+
+// report prints the issues left after the filters and returns ErrConfigIssuesFound if any,
+// or ErrNamespacesSkipped if some namespaces are not validated
+func (cv *ConfigValidator) report(w io.Writer, all []configValidationResult) error {
+	results := cv.filterResults(all)
+	defer cv.printFilteredCounts()
+
+	ret := ErrConfigIssuesFound
+	if len(results) == 0 {
+		ret = nil
+	}
+	if len(cv.skippedNamespaces) > 0 {
+		ret = ErrNamespacesSkipped
+	}
+
+	if cv.output == OutputJSON {
+		formatValidationResults(results)
+		raw, err := json.MarshalIndent(append(toJSONResults(results),
+			skippedNamespaceResults(cv.skippedNamespaces, cv.namespaceTimeout)...), "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling the results: %w", err)
+		}
+		fmt.Fprintf(w, "%s\n", raw)
+		return ret
+	}
+
 	if len(results) == 0 {
-		logger.Infof("Your Istio configurations are healthy. Configuration issues not found.\n")
-		return nil
+		if len(cv.skippedNamespaces) > 0 {
+			logger.Infof("Configuration issues not found in the validated namespaces.\n")
+		} else {
+			logger.Infof("Your Istio configurations are healthy. Configuration issues not found.\n")
+		}
+		return ret
 	}
 
 	if cv.allNamespaces() {
@@ -97,19 +269,68 @@ The error codes of the found issues are prefixed by 'IST' or 'KIA'. For the deta
 - https://istio.io/latest/docs/reference/config/analysis/ for 'IST' error codes
 - https://kiali.io/documentation/latest/validations/ for 'KIA' error codes
 `)
-	return ErrConfigIssuesFound
+	if len(cv.rules) > 0 {
+		logger.Infof("- the custom rules for the other error codes\n")
+	}
+	return ret
+}
+
+// printFilteredCounts prints the numbers of the issues filtered out and the namespaces skipped.
+// They go to stderr with "-o json"
+func (cv *ConfigValidator) printFilteredCounts() {
+	if cv.suppressedCount > 0 {
+		logger.Infof("\n%d issue(s) suppressed by the suppression file.\n", cv.suppressedCount)
+	}
+	if cv.baselineCount > 0 {
+		logger.Infof("\n%d issue(s) already found in the baseline are not reported.\n", cv.baselineCount)
+	}
+	if len(cv.skippedNamespaces) > 0 {
+		logger.Infof("\n")
+		logger.Warnf("%d namespace(s) not validated by Kiali as the validation timed out after %s: %s\n",
+			len(cv.skippedNamespaces), cv.namespaceTimeout, strings.Join(cv.skippedNamespaces, ", "))
+	}
 }
 
 func (cv *ConfigValidator) filterResults(in []configValidationResult) []configValidationResult {
+	cv.suppressedCount, cv.baselineCount = 0, 0
 	out := make([]configValidationResult, 0, len(in))
 	for _, r := range in {
-		if (cv.allNamespaces() || (r.namespace == cv.namespace)) && (r.severity.level <= cv.outputThreshold.level) {
-			out = append(out, r)
+		if !(cv.allNamespaces() || (r.namespace == cv.namespace)) || (r.severity.level > cv.outputThreshold.level) {
+			continue
+		}
+
+		if _, ok := cv.selectedNamespaces[r.namespace]; cv.selectedNamespaces != nil && r.namespace != "" && !ok {
+			continue
+		}
+
+		if cv.suppressed(r) {
+			cv.suppressedCount++
+			continue
+		}
+
+		if _, ok := cv.baseline[baselineKey(r.namespace, r.name, r.resourceType, r.errorCode)]; ok {
+			cv.baselineCount++
+			continue
+		}
+		// the issues of the namespace skipped in the baseline are unknown, so they are not new either
+		if _, ok := cv.baseline[baselineSkippedKey(r.namespace)]; ok && r.namespace != "" {
+			cv.baselineCount++
+			continue
 		}
+		out = append(out, r)
 	}
 	return out
 }
 
+func (cv *ConfigValidator) suppressed(r configValidationResult) bool {
+	for _, s := range cv.suppressions {
+		if s.matches(r) {
+			return true
+		}
+	}
+	return false
+}
+
 func (cv *ConfigValidator) allNamespaces() bool {
 	return cv.namespace == ""
 }
@@ -15,9 +15,17 @@
 package configvalidator
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/src/util/logger"
 )
 
 func TestConfigValidator_filterResults(t *testing.T) {
@@ -63,6 +71,112 @@ func TestConfigValidator_filterResults(t *testing.T) {
 	})
 }
 
+func TestConfigValidator_filterResults_suppressions_baseline(t *testing.T) {
+	in := []configValidationResult{
+		{namespace: "bookinfo", name: "bookinfo-gateway", resourceType: "gateway", errorCode: "KIA0302"},
+		{namespace: "bookinfo", name: "reviews", resourceType: "virtualservice", errorCode: "KIA1107"},
+		{namespace: "bookinfo", name: "ratings", resourceType: "virtualservice", errorCode: "KIA1107"},
+	}
+
+	cv := &ConfigValidator{
+		suppressions: []Suppression{{Code: "KIA0302", Reason: "foo"}},
+		baseline:     map[string]struct{}{baselineKey("bookinfo", "reviews", "VirtualService", "KIA1107"): {}},
+	}
+	require.Equal(t, in[2:], cv.filterResults(in))
+	require.Equal(t, 1, cv.suppressedCount)
+	require.Equal(t, 1, cv.baselineCount)
+
+	buf := logger.ExecuteWithLock(func() {
+		require.Equal(t, ErrConfigIssuesFound, cv.report(logger.GetWriter(), in))
+	})
+	require.Contains(t, buf.String(), "ratings")
+	require.NotContains(t, buf.String(), "reviews")
+	require.Contains(t, buf.String(), "1 issue(s) suppressed by the suppression file")
+	require.Contains(t, buf.String(), "1 issue(s) already found in the baseline are not reported")
+}
+
+func TestConfigValidator_report_json(t *testing.T) {
+	in := []configValidationResult{
+		{namespace: "bookinfo", name: "reviews", resourceType: "VIRTUALSERVICE", errorCode: "KIA1107",
+			severity: SeverityLevelWarn, message: "Subset not found"},
+	}
+
+	cv := &ConfigValidator{output: OutputJSON, outputThreshold: SeverityLevelInfo}
+	buf := new(bytes.Buffer)
+	require.Equal(t, ErrConfigIssuesFound, cv.report(buf, in))
+
+	var actual []jsonResult
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &actual))
+	require.Equal(t, []jsonResult{{Namespace: "bookinfo", Name: "reviews", ResourceType: "Virtualservice",
+		ErrorCode: "KIA1107", Severity: "Warning", Message: "Subset not found"}}, actual)
+
+	// the output as the baseline hides the same issues
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.Write(buf.Bytes())
+	require.NoError(t, err)
+
+	cv.baseline, err = loadBaseline(f.Name())
+	require.NoError(t, err)
+	buf.Reset()
+	logger.ExecuteWithLock(func() {
+		require.NoError(t, cv.report(buf, in))
+	})
+	require.Equal(t, "[]\n", buf.String())
+}
+
+func TestConfigValidator_report_skippedNamespaces(t *testing.T) {
+	in := []configValidationResult{
+		{namespace: "bookinfo", name: "reviews", resourceType: "VIRTUALSERVICE", errorCode: "KIA1107",
+			severity: SeverityLevelWarn, message: "Subset not found"},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		cv := &ConfigValidator{outputThreshold: SeverityLevelInfo,
+			skippedNamespaces: []string{"bookinfo"}, namespaceTimeout: time.Minute}
+		buf := logger.ExecuteWithLock(func() {
+			require.Equal(t, ErrNamespacesSkipped, cv.report(logger.GetWriter(), nil))
+		})
+		require.Contains(t, buf.String(), "Configuration issues not found in the validated namespaces.")
+		require.NotContains(t, buf.String(), "healthy")
+		require.Equal(t, 1, strings.Count(buf.String(),
+			"[WARNING] 1 namespace(s) not validated by Kiali as the validation timed out after 1m0s: bookinfo"))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		cv := &ConfigValidator{output: OutputJSON, outputThreshold: SeverityLevelInfo,
+			skippedNamespaces: []string{"bookinfo"}, namespaceTimeout: time.Minute}
+		buf := new(bytes.Buffer)
+		logger.ExecuteWithLock(func() {
+			require.Equal(t, ErrNamespacesSkipped, cv.report(buf, nil))
+		})
+
+		var actual []jsonResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &actual))
+		require.Equal(t, []jsonResult{{Namespace: "bookinfo", Name: "bookinfo", ResourceType: "Namespace",
+			ErrorCode: "NamespaceSkipped", Severity: "Warning",
+			Message: "not validated by Kiali as the validation timed out after 1m0s"}}, actual)
+
+		// the issues of the namespace skipped in the baseline are not reported as new
+		f, err := ioutil.TempFile("", "")
+		require.NoError(t, err)
+		defer os.Remove(f.Name())
+		_, err = f.Write(buf.Bytes())
+		require.NoError(t, err)
+
+		cv = &ConfigValidator{output: OutputJSON, outputThreshold: SeverityLevelInfo}
+		cv.baseline, err = loadBaseline(f.Name())
+		require.NoError(t, err)
+		buf.Reset()
+		logger.ExecuteWithLock(func() {
+			require.NoError(t, cv.report(buf, in))
+		})
+		require.Equal(t, "[]\n", buf.String())
+		require.Equal(t, 1, cv.baselineCount)
+	})
+}
+
 func TestConfigValidator_allNamespaces(t *testing.T) {
 	require.True(t, (&ConfigValidator{namespace: ""}).allNamespaces())
 	require.False(t, (&ConfigValidator{namespace: "default"}).allNamespaces())
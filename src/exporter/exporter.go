@@ -0,0 +1,225 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/checkupgrade"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+const namespace = "getmesh"
+
+// Exporter periodically runs the check-upgrade analysis and exposes the results as Prometheus metrics
+type Exporter struct {
+	// Versions returns the versions running in the mesh, or nil if Istio is not installed
+	Versions func() (*istioversion.Version, error)
+	// ControlPlanes returns the istiod deployments with their revisions
+	ControlPlanes func() ([]checkupgrade.ControlPlane, error)
+	Manifest      func() (*api.Manifest, error)
+
+	now      func() time.Time
+	registry *prometheus.Registry
+	// guards the updates of the vectors so that a scrape never sees a half-refreshed state
+	mux sync.Mutex
+
+	proxies              *prometheus.GaugeVec
+	controlPlaneReplicas *prometheus.GaugeVec
+	eolDays              *prometheus.GaugeVec
+	nearEOL              *prometheus.GaugeVec
+	supported            *prometheus.GaugeVec
+	patchPending         *prometheus.GaugeVec
+	securityPending      *prometheus.GaugeVec
+	outdatedProxies      *prometheus.GaugeVec
+	checkSuccess         prometheus.Gauge
+	checkTimestamp       prometheus.Gauge
+}
+
+// New creates the Exporter with the metrics registered
+func New(versions func() (*istioversion.Version, error), controlPlanes func() ([]checkupgrade.ControlPlane, error),
+	manifest func() (*api.Manifest, error)) *Exporter {
+	gaugeVec := func(name, help string, labels ...string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	}
+
+	e := &Exporter{
+		Versions:      versions,
+		ControlPlanes: controlPlanes,
+		Manifest:      manifest,
+		now:           time.Now,
+		registry:      prometheus.NewRegistry(),
+
+		proxies: gaugeVec("data_plane_proxies",
+			"Number of the proxies per version", "version"),
+		controlPlaneReplicas: gaugeVec("control_plane_replicas",
+			"Number of the ready istiod replicas per revision and version", "revision", "version"),
+		eolDays: gaugeVec("minor_version_eol_days",
+			"Days until the end of life of the running minor version. Negative after the end of life", "minor_version"),
+		nearEOL: gaugeVec("minor_version_near_eol",
+			"1 if the running minor version is reaching the end of life within the warning period", "minor_version"),
+		supported: gaugeVec("minor_version_supported",
+			"1 if the running minor version is listed in the manifest", "minor_version"),
+		patchPending: gaugeVec("patch_pending",
+			"1 if a newer patch is available for the running minor version", "minor_version", "latest"),
+		securityPending: gaugeVec("security_patch_pending",
+			"1 if the newer patches for the running minor version include security fixes", "minor_version", "latest"),
+		outdatedProxies: gaugeVec("outdated_proxies",
+			"Number of the proxies not running the latest patch of their minor version", "minor_version"),
+		checkSuccess: prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: "check_success",
+			Help: "1 if the last check succeeded"}),
+		checkTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: "check_timestamp_seconds",
+			Help: "Unix time of the last successful check"}),
+	}
+
+	e.registry.MustRegister(e.proxies, e.controlPlaneReplicas, e.eolDays, e.nearEOL, e.supported, e.patchPending,
+		e.securityPending, e.outdatedProxies, e.checkSuccess, e.checkTimestamp)
+	return e
+}
+
+// Handler serves the metrics
+func (e *Exporter) Handler() http.Handler {
+	h := promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.mux.Lock()
+		defer e.mux.Unlock()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Run refreshes the metrics every interval until the context is done
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := e.Refresh(); err != nil {
+			logger.Warnf("failed to refresh the metrics: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Refresh runs the checks and updates the metrics. The metrics of the last successful check are kept on errors
+func (e *Exporter) Refresh() error {
+	iv, cps, ms, err := e.fetch()
+	if err != nil {
+		e.checkSuccess.Set(0)
+		return err
+	}
+
+	var analysis *checkupgrade.Analysis
+	if iv != nil {
+		if analysis, err = checkupgrade.AnalyzeAt(*iv, ms, e.now()); err != nil {
+			e.checkSuccess.Set(0)
+			return err
+		}
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	for _, v := range []*prometheus.GaugeVec{e.proxies, e.controlPlaneReplicas, e.eolDays, e.nearEOL, e.supported,
+		e.patchPending, e.securityPending, e.outdatedProxies} {
+		v.Reset()
+	}
+
+	for _, cp := range cps {
+		e.controlPlaneReplicas.WithLabelValues(cp.Revision, cp.Version).Add(float64(cp.ReadyReplicas))
+	}
+
+	if analysis != nil {
+		e.updateMinorVersions(iv, analysis)
+	}
+
+	e.checkSuccess.Set(1)
+	e.checkTimestamp.Set(float64(e.now().Unix()))
+	return nil
+}
+
+func (e *Exporter) fetch() (*istioversion.Version, []checkupgrade.ControlPlane, *api.Manifest, error) {
+	iv, err := e.Versions()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error collecting the versions: %w", err)
+	}
+	cps, err := e.ControlPlanes()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error listing the control planes: %w", err)
+	}
+	ms, err := e.Manifest()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error fetching the manifest: %w", err)
+	}
+	return iv, cps, ms, nil
+}
+
+func (e *Exporter) updateMinorVersions(iv *istioversion.Version, analysis *checkupgrade.Analysis) {
+	latest := make(map[string]*api.IstioDistribution, len(analysis.MinorVersions))
+	for _, m := range analysis.MinorVersions {
+		latest[m.Group] = m.Latest
+		e.outdatedProxies.WithLabelValues(m.Group).Set(0)
+
+		if !m.EOL.IsZero() {
+			e.eolDays.WithLabelValues(m.Group).Set(m.EOL.Sub(e.now()).Hours() / 24)
+			var near float64
+			if m.NearEOL {
+				near = 1
+			}
+			e.nearEOL.WithLabelValues(m.Group).Set(near)
+		}
+
+		if m.Latest == nil {
+			e.supported.WithLabelValues(m.Group).Set(0)
+			continue
+		}
+		e.supported.WithLabelValues(m.Group).Set(1)
+
+		var pending, security float64
+		if !m.Latest.Equal(m.Lowest) {
+			pending = 1
+			if m.Security {
+				security = 1
+			}
+		}
+		e.patchPending.WithLabelValues(m.Group, m.Latest.ToString()).Set(pending)
+		e.securityPending.WithLabelValues(m.Group, m.Latest.ToString()).Set(security)
+	}
+
+	if iv.DataPlaneVersion == nil {
+		return
+	}
+	for _, p := range *iv.DataPlaneVersion {
+		e.proxies.WithLabelValues(p.IstioVersion).Inc()
+
+		// the versions are already verified by the analysis
+		d, _ := api.IstioDistributionFromString(p.IstioVersion)
+		g, _ := d.Group()
+		if l := latest[g]; l == nil || !l.Equal(d) {
+			e.outdatedProxies.WithLabelValues(g).Inc()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/checkupgrade"
+)
+
+func TestExporter(t *testing.T) {
+	iv := &istioversion.Version{
+		MeshVersion: &istioversion.MeshInfo{
+			{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.8.1-tetrate-v0"}},
+			{Component: "pilot", Info: istioversion.BuildInfo{Version: "1.7.4-tetrate-v0"}},
+		},
+		DataPlaneVersion: &[]istioversion.ProxyInfo{
+			{ID: "a.default", IstioVersion: "1.8.1-tetrate-v0"},
+			{ID: "b.default", IstioVersion: "1.8.3-tetrate-v0"},
+			{ID: "c.default", IstioVersion: "1.7.4-tetrate-v0"},
+		},
+	}
+	cps := []checkupgrade.ControlPlane{
+		{Namespace: "istio-system", Name: "istiod", Revision: "default", Version: "1.7.4-tetrate-v0", ReadyReplicas: 2},
+		{Namespace: "istio-system", Name: "istiod-1-8-1", Revision: "1-8-1", Version: "1.8.1-tetrate-v0", ReadyReplicas: 1},
+	}
+	ms := &api.Manifest{
+		IstioMinorVersionsEolDates: map[string]string{"1.8": "2021-05-11", "1.7": "2021-04-01"},
+		IstioDistributions: []*api.IstioDistribution{
+			{Version: "1.8.3", Flavor: "tetrate", FlavorVersion: 0, IsSecurityPatch: true},
+			{Version: "1.8.1", Flavor: "tetrate", FlavorVersion: 0},
+		},
+	}
+
+	var versionsErr error
+	e := New(
+		func() (*istioversion.Version, error) { return iv, versionsErr },
+		func() ([]checkupgrade.ControlPlane, error) { return cps, nil },
+		func() (*api.Manifest, error) { return ms, nil },
+	)
+	e.now = func() time.Time { return time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC) }
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		e.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		body, err := ioutil.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	require.NoError(t, e.Refresh())
+	actual := scrape()
+	for _, exp := range []string{
+		`getmesh_data_plane_proxies{version="1.7.4-tetrate-v0"} 1`,
+		`getmesh_data_plane_proxies{version="1.8.1-tetrate-v0"} 1`,
+		`getmesh_data_plane_proxies{version="1.8.3-tetrate-v0"} 1`,
+		`getmesh_control_plane_replicas{revision="1-8-1",version="1.8.1-tetrate-v0"} 1`,
+		`getmesh_control_plane_replicas{revision="default",version="1.7.4-tetrate-v0"} 2`,
+		`getmesh_minor_version_eol_days{minor_version="1.7-tetrate"} -30`,
+		`getmesh_minor_version_eol_days{minor_version="1.8-tetrate"} 10`,
+		`getmesh_minor_version_near_eol{minor_version="1.7-tetrate"} 0`,
+		`getmesh_minor_version_near_eol{minor_version="1.8-tetrate"} 1`,
+		`getmesh_minor_version_supported{minor_version="1.7-tetrate"} 0`,
+		`getmesh_minor_version_supported{minor_version="1.8-tetrate"} 1`,
+		`getmesh_patch_pending{latest="1.8.3-tetrate-v0",minor_version="1.8-tetrate"} 1`,
+		`getmesh_security_patch_pending{latest="1.8.3-tetrate-v0",minor_version="1.8-tetrate"} 1`,
+		`getmesh_outdated_proxies{minor_version="1.7-tetrate"} 1`,
+		`getmesh_outdated_proxies{minor_version="1.8-tetrate"} 1`,
+		`getmesh_check_success 1`,
+		`getmesh_check_timestamp_seconds 1.6198272e+09`,
+	} {
+		require.Contains(t, actual, exp+"\n")
+	}
+
+	// the metrics of the last successful check are kept
+	versionsErr = errors.New("unreachable")
+	require.Error(t, e.Refresh())
+	actual = scrape()
+	require.Contains(t, actual, "getmesh_check_success 0\n")
+	require.Contains(t, actual, `getmesh_data_plane_proxies{version="1.8.1-tetrate-v0"} 1`+"\n")
+
+	// not installed
+	iv, versionsErr, cps = nil, nil, nil
+	require.NoError(t, e.Refresh())
+	actual = scrape()
+	require.Contains(t, actual, "getmesh_check_success 1\n")
+	require.NotContains(t, actual, "getmesh_data_plane_proxies{")
+	require.NotContains(t, actual, "getmesh_control_plane_replicas{")
+}
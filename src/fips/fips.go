@@ -0,0 +1,324 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fips
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/checkupgrade"
+)
+
+// the categories of the checks
+const (
+	CategoryIstioctl     = "istioctl"
+	CategoryControlPlane = "control plane"
+	CategoryGateway      = "gateway"
+	CategorySidecar      = "sidecar"
+	CategoryMeshConfig   = "mesh config"
+	CategoryGatewayTLS   = "gateway tls"
+)
+
+// the cipher suites and the curves approved in the FIPS builds of Envoy
+var (
+	approvedCipherSuites = []string{
+		"ECDHE-ECDSA-AES128-GCM-SHA256",
+		"ECDHE-RSA-AES128-GCM-SHA256",
+		"ECDHE-ECDSA-AES256-GCM-SHA384",
+		"ECDHE-RSA-AES256-GCM-SHA384",
+		"AES128-GCM-SHA256",
+		"AES256-GCM-SHA384",
+	}
+	approvedCurves = []string{"P-256", "P-384"}
+)
+
+var gatewayGVR = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "gateways"}
+
+// Check is the result of a single item verified
+type Check struct {
+	Category string `json:"category"`
+	Target   string `json:"target"`
+	// the version or the setting verified
+	Value  string `json:"value"`
+	Passed bool   `json:"passed"`
+	// why the check failed
+	Reason string `json:"reason,omitempty"`
+}
+
+// Report is the result of the FIPS compliance verification
+type Report struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Passed      bool      `json:"passed"`
+	Checks      []Check   `json:"checks"`
+}
+
+// Failed returns the checks which did not pass
+func (r *Report) Failed() []Check {
+	var ret []Check
+	for _, c := range r.Checks {
+		if !c.Passed {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Print writes the checks in a table followed by the result
+func (r *Report) Print(w io.Writer) {
+	data := make([][]string, 0, len(r.Checks))
+	for _, c := range r.Checks {
+		result := "PASS"
+		if !c.Passed {
+			result = "FAIL: " + c.Reason
+		}
+		data = append(data, []string{c.Category, c.Target, c.Value, result})
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"CATEGORY", "TARGET", "VALUE", "RESULT"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t") // pad with tabs
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+
+	if r.Passed {
+		fmt.Fprintf(w, "\nResult: PASS (%d checks)\n", len(r.Checks))
+	} else {
+		fmt.Fprintf(w, "\nResult: FAIL (%d of %d checks failed)\n", len(r.Failed()), len(r.Checks))
+	}
+}
+
+// Inspector verifies that the mesh is consistently running the FIPS builds
+type Inspector struct {
+	KubeCli kubernetes.Interface
+	Dynamic dynamic.Interface
+	// the namespace of the mesh config, e.g. istio-system
+	IstioNamespace string
+}
+
+// Inspect runs all the checks against the active istioctl, which is nil if not fetched, and the mesh in the cluster
+func (i *Inspector) Inspect(istioctl *api.IstioDistribution, now time.Time) (*Report, error) {
+	ret := &Report{GeneratedAt: now.UTC()}
+
+	if istioctl == nil {
+		ret.Checks = append(ret.Checks, Check{Category: CategoryIstioctl, Target: "active", Value: "-", Reason: "no istioctl is fetched"})
+	} else {
+		ret.Checks = append(ret.Checks, checkVersion(CategoryIstioctl, "active", istioctl.ToString()))
+	}
+
+	cps, err := checkupgrade.ControlPlanes(i.KubeCli)
+	if err != nil {
+		return nil, err
+	}
+	if len(cps) == 0 {
+		ret.Checks = append(ret.Checks, Check{Category: CategoryControlPlane, Target: "-", Value: "-", Reason: "no istiod is running"})
+	}
+	for _, cp := range cps {
+		ret.Checks = append(ret.Checks, checkVersion(CategoryControlPlane,
+			fmt.Sprintf("%s/%s (revision %s)", cp.Namespace, cp.Name, cp.Revision), cp.Version))
+	}
+
+	proxies, err := checkupgrade.Proxies(i.KubeCli)
+	if err != nil {
+		return nil, err
+	}
+	ret.Checks = append(ret.Checks, checkProxies(proxies)...)
+
+	mc, err := i.checkMeshConfig()
+	if err != nil {
+		return nil, err
+	}
+	ret.Checks = append(ret.Checks, mc...)
+
+	gws, err := i.checkGateways()
+	if err != nil {
+		return nil, err
+	}
+	ret.Checks = append(ret.Checks, gws...)
+
+	ret.Passed = len(ret.Failed()) == 0
+	return ret, nil
+}
+
+func checkVersion(category, target, version string) Check {
+	ret := Check{Category: category, Target: target, Value: version}
+	d, err := api.IstioDistributionFromString(version)
+	if err != nil {
+		ret.Reason = fmt.Sprintf("cannot parse the version: %v", err)
+	} else if d.Flavor != api.IstioDistributionFlavorTetrateFIPS {
+		ret.Reason = fmt.Sprintf("not a %s build", api.IstioDistributionFlavorTetrateFIPS)
+	} else {
+		ret.Passed = true
+	}
+	return ret
+}
+
+// checkProxies verifies the proxies grouped by the workloads and the versions
+func checkProxies(proxies []checkupgrade.Proxy) []Check {
+	type key struct {
+		category, target, version string
+	}
+	counts := map[key]int{}
+	var keys []key
+	for _, p := range proxies {
+		k := key{category: CategorySidecar, target: p.Namespace + "/" + p.Workload, version: p.Version}
+		if p.Gateway {
+			k.category = CategoryGateway
+		}
+		if counts[k] == 0 {
+			keys = append(keys, k)
+		}
+		counts[k]++
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].category != keys[j].category {
+			// gateways first
+			return keys[i].category < keys[j].category
+		} else if keys[i].target != keys[j].target {
+			return keys[i].target < keys[j].target
+		}
+		return keys[i].version < keys[j].version
+	})
+
+	ret := make([]Check, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, checkVersion(k.category, fmt.Sprintf("%s (%d proxies)", k.target, counts[k]), k.version))
+	}
+	return ret
+}
+
+// meshConfig is the part of the Istio MeshConfig relevant to FIPS
+type meshConfig struct {
+	TLSDefaults struct {
+		CipherSuites []string `yaml:"cipherSuites"`
+		EcdhCurves   []string `yaml:"ecdhCurves"`
+	} `yaml:"tlsDefaults"`
+}
+
+// checkMeshConfig verifies the TLS settings of the mesh configs, i.e. the "mesh" in the ConfigMaps "istio" and "istio-${revision}"
+func (i *Inspector) checkMeshConfig() ([]Check, error) {
+	cms, err := i.KubeCli.CoreV1().ConfigMaps(i.IstioNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ConfigMaps in %s: %w", i.IstioNamespace, err)
+	}
+
+	var ret []Check
+	for _, cm := range cms.Items {
+		raw, ok := cm.Data["mesh"]
+		if !ok || (cm.Name != "istio" && !strings.HasPrefix(cm.Name, "istio-")) {
+			continue
+		}
+
+		target := cm.Namespace + "/" + cm.Name
+		var mc meshConfig
+		if err := yaml.Unmarshal([]byte(raw), &mc); err != nil {
+			ret = append(ret, Check{Category: CategoryMeshConfig, Target: target, Value: "-",
+				Reason: fmt.Sprintf("cannot parse the mesh config: %v", err)})
+			continue
+		}
+		ret = append(ret,
+			checkSettings(CategoryMeshConfig, target+" tlsDefaults.cipherSuites", mc.TLSDefaults.CipherSuites, approvedCipherSuites),
+			checkSettings(CategoryMeshConfig, target+" tlsDefaults.ecdhCurves", mc.TLSDefaults.EcdhCurves, approvedCurves),
+		)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Target < ret[j].Target })
+	return ret, nil
+}
+
+// checkGateways verifies the cipher suites of the servers of the Gateway resources
+func (i *Inspector) checkGateways() ([]Check, error) {
+	gws, err := i.Dynamic.Resource(gatewayGVR).List(context.Background(), metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		// the CRD is not installed
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error listing Gateways: %w", err)
+	}
+
+	var ret []Check
+	for _, gw := range gws.Items {
+		servers, _, err := unstructured.NestedSlice(gw.Object, "spec", "servers")
+		if err != nil {
+			return nil, fmt.Errorf("error reading the servers of Gateway %s/%s: %w", gw.GetNamespace(), gw.GetName(), err)
+		}
+		for n, s := range servers {
+			server, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			suites, found, err := unstructured.NestedStringSlice(server, "tls", "cipherSuites")
+			if err != nil || !found {
+				continue
+			}
+			ret = append(ret, checkSettings(CategoryGatewayTLS,
+				fmt.Sprintf("%s/%s servers[%d]", gw.GetNamespace(), gw.GetName(), n), suites, approvedCipherSuites))
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Target < ret[j].Target })
+	return ret, nil
+}
+
+// checkSettings passes when all the values are approved. Empty values pass since the defaults of the FIPS builds are approved
+func checkSettings(category, target string, values, approved []string) Check {
+	ret := Check{Category: category, Target: target, Value: "default", Passed: true}
+	if len(values) == 0 {
+		return ret
+	}
+	ret.Value = strings.Join(values, ", ")
+
+	var rejected []string
+	for _, v := range values {
+		if !contains(approved, v) {
+			rejected = append(rejected, v)
+		}
+	}
+	if len(rejected) > 0 {
+		ret.Passed = false
+		ret.Reason = "not approved for FIPS: " + strings.Join(rejected, ", ")
+	}
+	return ret
+}
+
+func contains(in []string, s string) bool {
+	for _, v := range in {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fips
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/tetratelabs/getmesh/api"
+)
+
+func newTestInspector(t *testing.T, version, mesh string, gatewayCipherSuites ...interface{}) *Inspector {
+	istiod := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "istiod", Namespace: "istio-system", Labels: map[string]string{"app": "istiod"}},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "discovery", Image: "containers.istio.tetratelabs.com/pilot:1.9.5-tetratefips-v0"}},
+		}}},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	pod := func(name, app, image string, args ...string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": app}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "istio-proxy", Image: "containers.istio.tetratelabs.com/proxyv2:" + image, Args: args},
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio", Namespace: "istio-system"},
+		Data:       map[string]string{"mesh": mesh},
+	}
+	// not a mesh config
+	root := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-ca-root-cert", Namespace: "istio-system"},
+		Data:       map[string]string{"root-cert.pem": "..."},
+	}
+
+	gw := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"servers": []interface{}{
+				map[string]interface{}{"port": map[string]interface{}{"number": int64(80)}},
+				map[string]interface{}{"tls": map[string]interface{}{"cipherSuites": gatewayCipherSuites}},
+			},
+		},
+	}}
+	gw.SetGroupVersionKind(schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1beta1", Kind: "Gateway"})
+	gw.SetNamespace("default")
+	gw.SetName("bookinfo-gateway")
+
+	dyn := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gatewayGVR: "GatewayList"})
+	// created via the client since the fake tracker guesses the resource of Gateway as "gatewaies"
+	_, err := dyn.Resource(gatewayGVR).Namespace("default").Create(context.Background(), gw, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	return &Inspector{
+		KubeCli: fake.NewSimpleClientset(istiod, cm, root,
+			pod("reviews-1", "reviews", version, "proxy", "sidecar"),
+			pod("reviews-2", "reviews", version, "proxy", "sidecar"),
+			pod("ingress-1", "istio-ingressgateway", "1.9.5-tetratefips-v0", "proxy", "router"),
+		),
+		Dynamic:        dyn,
+		IstioNamespace: "istio-system",
+	}
+}
+
+func TestInspector_Inspect(t *testing.T) {
+	now := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	fipsIstioctl := &api.IstioDistribution{Version: "1.9.5", Flavor: api.IstioDistributionFlavorTetrateFIPS}
+
+	t.Run("pass", func(t *testing.T) {
+		i := newTestInspector(t, "1.9.5-tetratefips-v0", "tlsDefaults:\n  ecdhCurves: [P-256]\n", "ECDHE-RSA-AES256-GCM-SHA384")
+		r, err := i.Inspect(fipsIstioctl, now)
+		require.NoError(t, err)
+		require.Equal(t, &Report{GeneratedAt: now, Passed: true, Checks: []Check{
+			{Category: CategoryIstioctl, Target: "active", Value: "1.9.5-tetratefips-v0", Passed: true},
+			{Category: CategoryControlPlane, Target: "istio-system/istiod (revision default)", Value: "1.9.5-tetratefips-v0", Passed: true},
+			{Category: CategoryGateway, Target: "default/istio-ingressgateway (1 proxies)", Value: "1.9.5-tetratefips-v0", Passed: true},
+			{Category: CategorySidecar, Target: "default/reviews (2 proxies)", Value: "1.9.5-tetratefips-v0", Passed: true},
+			{Category: CategoryMeshConfig, Target: "istio-system/istio tlsDefaults.cipherSuites", Value: "default", Passed: true},
+			{Category: CategoryMeshConfig, Target: "istio-system/istio tlsDefaults.ecdhCurves", Value: "P-256", Passed: true},
+			{Category: CategoryGatewayTLS, Target: "default/bookinfo-gateway servers[1]", Value: "ECDHE-RSA-AES256-GCM-SHA384", Passed: true},
+		}}, r)
+
+		buf := new(bytes.Buffer)
+		r.Print(buf)
+		require.Contains(t, buf.String(), "\nResult: PASS (7 checks)\n")
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		i := newTestInspector(t, "1.9.5-tetrate-v0", "tlsDefaults:\n  cipherSuites: [ECDHE-RSA-AES128-GCM-SHA256, ECDHE-RSA-CHACHA20-POLY1305]\n",
+			"ECDHE-RSA-AES128-SHA")
+		r, err := i.Inspect(nil, now)
+		require.NoError(t, err)
+		require.False(t, r.Passed)
+		require.Equal(t, []Check{
+			{Category: CategoryIstioctl, Target: "active", Value: "-", Reason: "no istioctl is fetched"},
+			{Category: CategorySidecar, Target: "default/reviews (2 proxies)", Value: "1.9.5-tetrate-v0", Reason: "not a tetratefips build"},
+			{Category: CategoryMeshConfig, Target: "istio-system/istio tlsDefaults.cipherSuites",
+				Value: "ECDHE-RSA-AES128-GCM-SHA256, ECDHE-RSA-CHACHA20-POLY1305", Reason: "not approved for FIPS: ECDHE-RSA-CHACHA20-POLY1305"},
+			{Category: CategoryGatewayTLS, Target: "default/bookinfo-gateway servers[1]", Value: "ECDHE-RSA-AES128-SHA",
+				Reason: "not approved for FIPS: ECDHE-RSA-AES128-SHA"},
+		}, r.Failed())
+
+		buf := new(bytes.Buffer)
+		r.Print(buf)
+		require.Contains(t, buf.String(), "FAIL: not a tetratefips build")
+		require.Contains(t, buf.String(), "\nResult: FAIL (4 of 7 checks failed)\n")
+	})
+
+	t.Run("no control plane", func(t *testing.T) {
+		i := &Inspector{
+			KubeCli:        fake.NewSimpleClientset(),
+			Dynamic:        fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gatewayGVR: "GatewayList"}),
+			IstioNamespace: "istio-system",
+		}
+		r, err := i.Inspect(fipsIstioctl, now)
+		require.NoError(t, err)
+		require.Equal(t, []Check{
+			{Category: CategoryControlPlane, Target: "-", Value: "-", Reason: "no istiod is running"},
+		}, r.Failed())
+	})
+}
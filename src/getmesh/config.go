@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/tetratelabs/getmesh/api"
 )
@@ -31,44 +32,68 @@ var GlobalConfigMux sync.Mutex
 type Config struct {
 	IstioDistribution *api.IstioDistribution `json:"istio_distribution"`
 	DefaultHub        string                 `json:"default_hub,omitempty"`
+	ManifestURL       string                 `json:"manifest_url,omitempty"`
+	DefaultFlavor     string                 `json:"default_flavor,omitempty"`
+	EOLWarningDays    int                    `json:"eol_warning_days,omitempty"`
+	NonInteractive    bool                   `json:"non_interactive,omitempty"`
+	WarningPolicy     string                 `json:"warning_policy,omitempty"`
+	HTTPProxy         string                 `json:"http_proxy,omitempty"`
+	HTTPSProxy        string                 `json:"https_proxy,omitempty"`
+	NoProxy           string                 `json:"no_proxy,omitempty"`
+
+	// defaults injected into "getmesh istioctl install" unless explicitly given
+	DefaultInstallFile      string   `json:"default_install_file,omitempty"`
+	DefaultTag              string   `json:"default_tag,omitempty"`
+	DefaultProfile          string   `json:"default_profile,omitempty"`
+	DefaultImagePullSecrets []string `json:"default_image_pull_secrets,omitempty"`
+	DefaultMeshConfig       []string `json:"default_mesh_config,omitempty"`
+	SkipHubVerification     bool     `json:"skip_hub_verification,omitempty"`
 }
 
-var currentConfig Config
+var (
+	currentConfig Config
+	// {key -> value} given by GETMESH_* environment variables which take precedence over config.json
+	envOverrides = map[string]string{}
+)
 
 // for switch
 func SetIstioVersion(homedir string, d *api.IstioDistribution) error {
-	configPath := getConfigPath(homedir)
 	currentConfig.IstioDistribution = d
-	raw, err := json.Marshal(currentConfig)
-	if err != nil {
-		return fmt.Errorf("error marshaling config: %v", err)
-	}
-	if err := ioutil.WriteFile(configPath, raw, 0644); err != nil {
-		return fmt.Errorf("error writing configuration at %s: %v", configPath, err)
-	}
-	return nil
+	return writeConfig(homedir)
 }
 
 // for default-hub
 func SetDefaultHub(homedir, hub string) error {
-	configPath := getConfigPath(homedir)
-	currentConfig.DefaultHub = hub
-	raw, err := json.Marshal(currentConfig)
-	if err != nil {
-		return fmt.Errorf("error marshaling config: %v", err)
-	}
-	if err := ioutil.WriteFile(configPath, raw, 0644); err != nil {
-		return fmt.Errorf("error writing configuration at %s: %v", configPath, err)
+	if hub == "" {
+		return UnsetConfigValue(homedir, "default-hub")
 	}
-	return nil
+	return SetConfigValue(homedir, "default-hub", hub)
 }
 
 // for istio cmd
 func GetActiveConfig() Config {
-	return currentConfig
+	ret := currentConfig
+	for name, v := range envOverrides {
+		if k, ok := configKeys[name]; ok {
+			k.set(&ret, v)
+		}
+	}
+	return ret
+}
+
+// EOLWarningStart returns the time from which we warn about the end of life, configurable via "getmesh config set eol-warning-days"
+func EOLWarningStart(eol time.Time) time.Time {
+	if days := GetActiveConfig().EOLWarningDays; days > 0 {
+		return eol.UTC().AddDate(0, 0, -days)
+	}
+	return eol.UTC().AddDate(0, -1, 0)
 }
 
 func InitConfig(homedir string) error {
+	if err := loadEnvOverrides(); err != nil {
+		return err
+	}
+
 	configPath := getConfigPath(homedir)
 	_, err := os.Stat(configPath)
 	if err == nil {
@@ -79,17 +104,41 @@ func InitConfig(homedir string) error {
 		if err := json.Unmarshal(raw, &currentConfig); err != nil {
 			return fmt.Errorf("error unmarshalling configuration for %s: %v", configPath, err)
 		}
+		ignoreInvalidConfigValues(configPath)
 		return nil
 	} else if !os.IsNotExist(err) && err != nil {
 		return fmt.Errorf("failed to open configuration file at %s: %v", configPath, err)
 	}
 
 	currentConfig = Config{}
+	return writeConfig(homedir)
+}
+
+// ExportProxyEnv exports the configured proxies as the standard environment variables
+// so that they are respected by both getmesh itself and the child processes such as istioctl
+func ExportProxyEnv() error {
+	c := GetActiveConfig()
+	for _, e := range []struct{ name, value string }{
+		{name: "HTTP_PROXY", value: c.HTTPProxy},
+		{name: "HTTPS_PROXY", value: c.HTTPSProxy},
+		{name: "NO_PROXY", value: c.NoProxy},
+	} {
+		if e.value == "" {
+			continue
+		}
+		if err := os.Setenv(e.name, e.value); err != nil {
+			return fmt.Errorf("error setting %s: %v", e.name, err)
+		}
+	}
+	return nil
+}
+
+func writeConfig(homedir string) error {
+	configPath := getConfigPath(homedir)
 	raw, err := json.Marshal(currentConfig)
 	if err != nil {
 		return fmt.Errorf("error marshaling config: %v", err)
 	}
-
 	if err := ioutil.WriteFile(configPath, raw, 0644); err != nil {
 		return fmt.Errorf("error writing configuration at %s: %v", configPath, err)
 	}
@@ -0,0 +1,395 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package getmesh
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+const (
+	WarningPolicyPrompt = "prompt"
+	WarningPolicyFail   = "fail"
+)
+
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceFile    = "config"
+	ConfigSourceEnv     = "env"
+)
+
+// configKey describes a single setting accessible via "getmesh config" command
+type configKey struct {
+	description string
+	validate    func(string) error
+	get         func(*Config) string
+	// set must accept the empty string as "unset"
+	set func(*Config, string)
+}
+
+// ConfigKeyNames is the ordered list of the keys accessible via "getmesh config" command
+var ConfigKeyNames = []string{
+	"manifest-url",
+	"default-hub",
+	"skip-hub-verification",
+	"default-install-file",
+	"default-tag",
+	"default-profile",
+	"default-image-pull-secrets",
+	"default-mesh-config",
+	"default-flavor",
+	"eol-warning-days",
+	"non-interactive",
+	"warning-policy",
+	"http-proxy",
+	"https-proxy",
+	"no-proxy",
+}
+
+var configKeys = map[string]configKey{
+	"manifest-url": {
+		description: "URL of manifest.json listing the available Istio distributions",
+		validate:    validateURL,
+		get:         func(c *Config) string { return c.ManifestURL },
+		set:         func(c *Config, v string) { c.ManifestURL = v },
+	},
+	"default-hub": {
+		description: `hub passed to "getmesh istioctl install" via "--set hub=" unless explicitly given`,
+		validate:    validateHub,
+		get:         func(c *Config) string { return c.DefaultHub },
+		set:         func(c *Config, v string) { c.DefaultHub = v },
+	},
+	"skip-hub-verification": {
+		description: `set to true to skip verifying that the default hub serves the images required by "getmesh istioctl install"`,
+		validate:    validateBool,
+		get: func(c *Config) string {
+			if !c.SkipHubVerification {
+				return ""
+			}
+			return strconv.FormatBool(c.SkipHubVerification)
+		},
+		set: func(c *Config, v string) { c.SkipHubVerification, _ = strconv.ParseBool(v) },
+	},
+	"default-install-file": {
+		description: `absolute path to the IstioOperator yaml passed to "getmesh istioctl install" via "-f" before the given files`,
+		validate:    validateInstallFile,
+		get:         func(c *Config) string { return c.DefaultInstallFile },
+		set:         func(c *Config, v string) { c.DefaultInstallFile = v },
+	},
+	"default-tag": {
+		description: `tag passed to "getmesh istioctl install" via "--set tag=" unless explicitly given`,
+		validate:    validateNoWhiteSpace,
+		get:         func(c *Config) string { return c.DefaultTag },
+		set:         func(c *Config, v string) { c.DefaultTag = v },
+	},
+	"default-profile": {
+		description: `profile passed to "getmesh istioctl install" via "--set profile=" unless explicitly given`,
+		validate:    validateNoWhiteSpace,
+		get:         func(c *Config) string { return c.DefaultProfile },
+		set:         func(c *Config, v string) { c.DefaultProfile = v },
+	},
+	"default-image-pull-secrets": {
+		description: `comma-separated secret names passed to "getmesh istioctl install" via "--set values.global.imagePullSecrets[i]=" unless explicitly given`,
+		validate:    validateImagePullSecrets,
+		get:         func(c *Config) string { return strings.Join(c.DefaultImagePullSecrets, ",") },
+		set:         func(c *Config, v string) { c.DefaultImagePullSecrets = splitList(v) },
+	},
+	"default-mesh-config": {
+		description: `comma-separated key=value pairs passed to "getmesh istioctl install" via "--set meshConfig.<key>=<value>" unless explicitly given`,
+		validate:    validateMeshConfig,
+		get:         func(c *Config) string { return strings.Join(c.DefaultMeshConfig, ",") },
+		set:         func(c *Config, v string) { c.DefaultMeshConfig = splitList(v) },
+	},
+	"default-flavor": {
+		description: `flavor used by "getmesh fetch" when --flavor is not given. Defaults to "tetrate"`,
+		validate:    validateFlavor,
+		get:         func(c *Config) string { return c.DefaultFlavor },
+		set:         func(c *Config, v string) { c.DefaultFlavor = v },
+	},
+	"eol-warning-days": {
+		description: "number of days before the end of life of a minor version from which getmesh warns. Defaults to one month",
+		validate:    validatePositiveInt,
+		get: func(c *Config) string {
+			if c.EOLWarningDays == 0 {
+				return ""
+			}
+			return strconv.Itoa(c.EOLWarningDays)
+		},
+		set: func(c *Config, v string) { c.EOLWarningDays, _ = strconv.Atoi(v) },
+	},
+	"non-interactive": {
+		description: "set to true to skip all the confirmation prompts. Also enabled by --non-interactive flag or the absence of TTY",
+		validate:    validateBool,
+		get: func(c *Config) string {
+			if !c.NonInteractive {
+				return ""
+			}
+			return strconv.FormatBool(c.NonInteractive)
+		},
+		set: func(c *Config, v string) { c.NonInteractive, _ = strconv.ParseBool(v) },
+	},
+	"warning-policy": {
+		description: `action on warnings which otherwise ask for confirmation: "prompt" (default) or "fail" to exit with the distinct code`,
+		validate:    validateWarningPolicy,
+		get:         func(c *Config) string { return c.WarningPolicy },
+		set:         func(c *Config, v string) { c.WarningPolicy = v },
+	},
+	"http-proxy": {
+		description: "proxy used for HTTP requests made by getmesh and istioctl",
+		validate:    validateURL,
+		get:         func(c *Config) string { return c.HTTPProxy },
+		set:         func(c *Config, v string) { c.HTTPProxy = v },
+	},
+	"https-proxy": {
+		description: "proxy used for HTTPS requests made by getmesh and istioctl",
+		validate:    validateURL,
+		get:         func(c *Config) string { return c.HTTPSProxy },
+		set:         func(c *Config, v string) { c.HTTPSProxy = v },
+	},
+	"no-proxy": {
+		description: "comma-separated list of hosts excluded from proxying",
+		validate:    func(string) error { return nil },
+		get:         func(c *Config) string { return c.NoProxy },
+		set:         func(c *Config, v string) { c.NoProxy = v },
+	},
+}
+
+// ConfigKeyDescription returns the human readable description of the key
+func ConfigKeyDescription(key string) (string, error) {
+	k, err := lookupConfigKey(key)
+	if err != nil {
+		return "", err
+	}
+	return k.description, nil
+}
+
+// ConfigKeyEnv returns the name of the environment variable which overrides the key
+func ConfigKeyEnv(key string) string {
+	return "GETMESH_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// GetConfigValue returns the effective value of the key and where it comes from
+func GetConfigValue(key string) (value, source string, err error) {
+	k, err := lookupConfigKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	if v, ok := envOverrides[key]; ok {
+		return v, ConfigSourceEnv, nil
+	}
+
+	if v := k.get(&currentConfig); v != "" {
+		return v, ConfigSourceFile, nil
+	}
+	return "", ConfigSourceDefault, nil
+}
+
+// SetConfigValue validates the value and persists it to config.json
+func SetConfigValue(homedir, key, value string) error {
+	k, err := lookupConfigKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := k.validate(value); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	k.set(&currentConfig, value)
+	return writeConfig(homedir)
+}
+
+// UnsetConfigValue removes the key from config.json so that the default value is used
+func UnsetConfigValue(homedir, key string) error {
+	k, err := lookupConfigKey(key)
+	if err != nil {
+		return err
+	}
+
+	k.set(&currentConfig, "")
+	return writeConfig(homedir)
+}
+
+func lookupConfigKey(key string) (configKey, error) {
+	k, ok := configKeys[key]
+	if !ok {
+		return configKey{}, fmt.Errorf("unknown config key %q. Available keys: %s",
+			key, strings.Join(ConfigKeyNames, ", "))
+	}
+	return k, nil
+}
+
+func loadEnvOverrides() error {
+	ret := map[string]string{}
+	for _, name := range ConfigKeyNames {
+		env := ConfigKeyEnv(name)
+		v, ok := os.LookupEnv(env)
+		if !ok || v == "" {
+			continue
+		}
+
+		if err := configKeys[name].validate(v); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", env, err)
+		}
+		ret[name] = v
+	}
+	envOverrides = ret
+	return nil
+}
+
+// ignoreInvalidConfigValues warns and ignores the values loaded from config.json which "getmesh config set" would reject,
+// e.g. the default-install-file deleted after being set, so that they can still be fixed by "getmesh config unset"
+func ignoreInvalidConfigValues(configPath string) {
+	for _, name := range ConfigKeyNames {
+		k := configKeys[name]
+		v := k.get(&currentConfig)
+		if v == "" {
+			continue
+		}
+
+		if err := k.validate(v); err != nil {
+			logger.Warnf("ignoring invalid value for %s in %s: %v\n", name, configPath, err)
+			k.set(&currentConfig, "")
+		}
+	}
+}
+
+func validateURL(in string) error {
+	u, err := url.Parse(in)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s must start with http:// or https://", in)
+	} else if u.Host == "" {
+		return fmt.Errorf("%s does not contain a host", in)
+	}
+	return nil
+}
+
+func validateHub(in string) error {
+	if in == "" {
+		return fmt.Errorf("hub must not be empty")
+	} else if strings.Contains(in, "://") {
+		return fmt.Errorf("hub %s must not contain the scheme, e.g. docker.io/istio", in)
+	} else if strings.ContainsAny(in, " \t\n") {
+		return fmt.Errorf("hub %s must not contain white spaces", in)
+	}
+	return nil
+}
+
+func validateFlavor(in string) error {
+	switch in {
+	case api.IstioDistributionFlavorTetrate, api.IstioDistributionFlavorTetrateFIPS, api.IstioDistributionFlavorIstio:
+		return nil
+	default:
+		return fmt.Errorf("unsupported flavor %s: must be one of %s, %s and %s", in,
+			api.IstioDistributionFlavorTetrate, api.IstioDistributionFlavorTetrateFIPS, api.IstioDistributionFlavorIstio)
+	}
+}
+
+func validatePositiveInt(in string) error {
+	v, err := strconv.Atoi(in)
+	if err != nil {
+		return fmt.Errorf("%s is not an integer", in)
+	} else if v <= 0 {
+		return fmt.Errorf("%s must be positive", in)
+	}
+	return nil
+}
+
+func validateBool(in string) error {
+	if _, err := strconv.ParseBool(in); err != nil {
+		return fmt.Errorf("%s is not a boolean", in)
+	}
+	return nil
+}
+
+func validateNoWhiteSpace(in string) error {
+	if in == "" {
+		return fmt.Errorf("value must not be empty")
+	} else if strings.ContainsAny(in, " \t\n") {
+		return fmt.Errorf("%s must not contain white spaces", in)
+	}
+	return nil
+}
+
+func validateInstallFile(in string) error {
+	if !filepath.IsAbs(in) {
+		return fmt.Errorf("%s must be an absolute path", in)
+	}
+
+	info, err := os.Stat(in)
+	if err != nil {
+		return fmt.Errorf("error checking %s: %v", in, err)
+	} else if info.IsDir() {
+		return fmt.Errorf("%s is a directory", in)
+	}
+	return nil
+}
+
+func validateImagePullSecrets(in string) error {
+	ss := splitList(in)
+	if len(ss) == 0 {
+		return fmt.Errorf("value must not be empty")
+	}
+
+	for _, s := range ss {
+		if strings.ContainsAny(s, "= \t\n") {
+			return fmt.Errorf("invalid secret name %q", s)
+		}
+	}
+	return nil
+}
+
+func validateMeshConfig(in string) error {
+	kvs := splitList(in)
+	if len(kvs) == 0 {
+		return fmt.Errorf("value must not be empty")
+	}
+
+	for _, kv := range kvs {
+		if ps := strings.SplitN(kv, "=", 2); len(ps) != 2 || ps[0] == "" {
+			return fmt.Errorf("%q is not in the form of key=value", kv)
+		}
+	}
+	return nil
+}
+
+// split the comma-separated list ignoring empty elements
+func splitList(in string) []string {
+	var ret []string
+	for _, s := range strings.Split(in, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func validateWarningPolicy(in string) error {
+	if in != WarningPolicyPrompt && in != WarningPolicyFail {
+		return fmt.Errorf("unsupported policy %s: must be either %s or %s", in, WarningPolicyPrompt, WarningPolicyFail)
+	}
+	return nil
+}
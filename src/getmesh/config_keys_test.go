@@ -0,0 +1,147 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package getmesh
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigKeys(t *testing.T) {
+	require.Len(t, configKeys, len(ConfigKeyNames))
+	for _, k := range ConfigKeyNames {
+		_, ok := configKeys[k]
+		require.True(t, ok, k)
+	}
+}
+
+func TestSetConfigValue(t *testing.T) {
+	GlobalConfigMux.Lock()
+	defer GlobalConfigMux.Unlock()
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+	currentConfig = Config{}
+	defer func() { currentConfig = Config{} }()
+
+	t.Run("ok", func(t *testing.T) {
+		for _, c := range []struct{ key, value string }{
+			{key: "manifest-url", value: "https://example.com/manifest.json"},
+			{key: "default-hub", value: "gcr.io/istio-testing"},
+			{key: "default-flavor", value: "tetratefips"},
+			{key: "eol-warning-days", value: "60"},
+			{key: "non-interactive", value: "true"},
+			{key: "http-proxy", value: "http://proxy.example.com:3128"},
+			{key: "https-proxy", value: "http://proxy.example.com:3128"},
+			{key: "no-proxy", value: "localhost,.svc"},
+			{key: "default-tag", value: "1.9.5-tetrate-v0"},
+			{key: "default-profile", value: "minimal"},
+			{key: "default-image-pull-secrets", value: "secret-a,secret-b"},
+			{key: "default-mesh-config", value: "accessLogFile=/dev/stdout,enableTracing=true"},
+		} {
+			require.NoError(t, SetConfigValue(home, c.key, c.value))
+			v, src, err := GetConfigValue(c.key)
+			require.NoError(t, err)
+			assert.Equal(t, c.value, v)
+			assert.Equal(t, ConfigSourceFile, src)
+		}
+
+		b, err := ioutil.ReadFile(getConfigPath(home))
+		require.NoError(t, err)
+		var actual Config
+		require.NoError(t, json.Unmarshal(b, &actual))
+		assert.Equal(t, Config{
+			ManifestURL:             "https://example.com/manifest.json",
+			DefaultHub:              "gcr.io/istio-testing",
+			DefaultFlavor:           "tetratefips",
+			EOLWarningDays:          60,
+			NonInteractive:          true,
+			HTTPProxy:               "http://proxy.example.com:3128",
+			HTTPSProxy:              "http://proxy.example.com:3128",
+			NoProxy:                 "localhost,.svc",
+			DefaultTag:              "1.9.5-tetrate-v0",
+			DefaultProfile:          "minimal",
+			DefaultImagePullSecrets: []string{"secret-a", "secret-b"},
+			DefaultMeshConfig:       []string{"accessLogFile=/dev/stdout", "enableTracing=true"},
+		}, actual)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, c := range []struct{ key, value string }{
+			{key: "unknown", value: "foo"},
+			{key: "manifest-url", value: "example.com/manifest.json"},
+			{key: "default-hub", value: ""},
+			{key: "default-hub", value: "https://gcr.io/istio"},
+			{key: "default-flavor", value: "foo"},
+			{key: "eol-warning-days", value: "-1"},
+			{key: "eol-warning-days", value: "a"},
+			{key: "non-interactive", value: "yes please"},
+			{key: "warning-policy", value: "ignore"},
+			{key: "default-install-file", value: "relative/operator.yaml"},
+			{key: "default-install-file", value: home},
+			{key: "default-profile", value: "my profile"},
+			{key: "default-image-pull-secrets", value: ","},
+			{key: "default-mesh-config", value: "accessLogFile"},
+		} {
+			require.Error(t, SetConfigValue(home, c.key, c.value), c)
+		}
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		require.NoError(t, UnsetConfigValue(home, "eol-warning-days"))
+		v, src, err := GetConfigValue("eol-warning-days")
+		require.NoError(t, err)
+		assert.Equal(t, "", v)
+		assert.Equal(t, ConfigSourceDefault, src)
+		assert.Equal(t, 0, GetActiveConfig().EOLWarningDays)
+	})
+}
+
+func Test_loadEnvOverrides(t *testing.T) {
+	GlobalConfigMux.Lock()
+	defer GlobalConfigMux.Unlock()
+	currentConfig = Config{DefaultHub: "docker.io/istio"}
+	defer func() {
+		currentConfig = Config{}
+		envOverrides = map[string]string{}
+	}()
+
+	require.Equal(t, "GETMESH_DEFAULT_HUB", ConfigKeyEnv("default-hub"))
+	require.NoError(t, os.Setenv("GETMESH_DEFAULT_HUB", "gcr.io/istio-testing"))
+	require.NoError(t, os.Setenv("GETMESH_NON_INTERACTIVE", "true"))
+	defer os.Unsetenv("GETMESH_DEFAULT_HUB")
+	defer os.Unsetenv("GETMESH_NON_INTERACTIVE")
+
+	require.NoError(t, loadEnvOverrides())
+	actual := GetActiveConfig()
+	assert.Equal(t, "gcr.io/istio-testing", actual.DefaultHub)
+	assert.True(t, actual.NonInteractive)
+	// the persisted value is untouched
+	assert.Equal(t, "docker.io/istio", currentConfig.DefaultHub)
+
+	v, src, err := GetConfigValue("default-hub")
+	require.NoError(t, err)
+	assert.Equal(t, "gcr.io/istio-testing", v)
+	assert.Equal(t, ConfigSourceEnv, src)
+
+	require.NoError(t, os.Setenv("GETMESH_EOL_WARNING_DAYS", "ten"))
+	defer os.Unsetenv("GETMESH_EOL_WARNING_DAYS")
+	require.Error(t, loadEnvOverrides())
+}
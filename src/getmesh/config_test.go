@@ -25,6 +25,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
 )
 
 func TestSetIstioVersion(t *testing.T) {
@@ -96,6 +97,28 @@ func TestInitConfig(t *testing.T) {
 		assert.Nil(t, currentConfig.IstioDistribution)
 	})
 
+	t.Run("invalid value", func(t *testing.T) {
+		home, err := ioutil.TempDir("", "")
+		require.NoError(t, err)
+		defer os.RemoveAll(home)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(getConfigPath(home)), 0755))
+		require.NoError(t, ioutil.WriteFile(getConfigPath(home),
+			[]byte(`{"default_flavor":"invalid","default_install_file":"/not/exist.yaml","default_tag":"1.9.0"}`), 0644))
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, InitConfig(home))
+		})
+		require.Contains(t, buf.String(), "ignoring invalid value for default-flavor in "+getConfigPath(home))
+		require.Contains(t, buf.String(), "ignoring invalid value for default-install-file in "+getConfigPath(home))
+		require.Empty(t, currentConfig.DefaultFlavor)
+		require.Empty(t, currentConfig.DefaultInstallFile)
+		require.Equal(t, "1.9.0", currentConfig.DefaultTag)
+
+		// can be fixed by unset
+		require.NoError(t, UnsetConfigValue(home, "default-flavor"))
+		currentConfig = Config{}
+	})
+
 }
 
 func Test_getConfigPath(t *testing.T) {
@@ -0,0 +1,140 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const fileName = "history.jsonl"
+
+// Entry is a record of an istioctl invocation which mutated a cluster
+type Entry struct {
+	Timestamp    time.Time     `json:"timestamp"`
+	User         string        `json:"user"`
+	Context      string        `json:"context"`
+	Distribution string        `json:"distribution"`
+	Args         []string      `json:"args"`
+	ExitCode     int           `json:"exit_code"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// Filter narrows down the entries. Zero values match everything
+type Filter struct {
+	Context      string
+	Since, Until time.Time
+}
+
+func (f Filter) match(e *Entry) bool {
+	if f.Context != "" && f.Context != e.Context {
+		return false
+	} else if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	} else if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+func filePath(homedir string) string {
+	return filepath.Join(homedir, fileName)
+}
+
+// Append adds the entry to the log
+func Append(homedir string, e Entry) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	p := filePath(homedir)
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening history at %s: %w", p, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		return fmt.Errorf("error writing history at %s: %w", p, err)
+	}
+	return nil
+}
+
+// Load returns the entries matched with the filter in the recorded order
+func Load(homedir string, filter Filter) ([]Entry, error) {
+	p := filePath(homedir)
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening history at %s: %w", p, err)
+	}
+	defer f.Close()
+
+	var ret []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("error parsing line %d of %s: %w", line, p, err)
+		}
+		if filter.match(&e) {
+			ret = append(ret, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history at %s: %w", p, err)
+	}
+	return ret, nil
+}
+
+var sensitiveKeyRegexp = regexp.MustCompile(`(?i)(password|passwd|token|credential|api[-_]?key|private[-_]?key)`)
+
+const masked = "***"
+
+// SanitizeArgs masks the values of the flags and "--set" paths which look sensitive
+func SanitizeArgs(args []string) []string {
+	ret := make([]string, len(args))
+	var prev string
+	for i, a := range args {
+		ret[i] = a
+		switch {
+		case prev == "--set" || prev == "-s":
+			if kv := strings.SplitN(a, "=", 2); len(kv) == 2 && sensitiveKeyRegexp.MatchString(kv[0]) {
+				ret[i] = kv[0] + "=" + masked
+			}
+		case strings.HasPrefix(prev, "-") && sensitiveKeyRegexp.MatchString(prev) && !strings.HasPrefix(a, "-"):
+			ret[i] = masked
+		case strings.HasPrefix(a, "-") && strings.Contains(a, "="):
+			if kv := strings.SplitN(a, "=", 2); sensitiveKeyRegexp.MatchString(kv[0]) {
+				ret[i] = kv[0] + "=" + masked
+			}
+		}
+		prev = a
+	}
+	return ret
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendLoad(t *testing.T) {
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	entries, err := Load(home, Filter{})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	base := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	for i, c := range []string{"a", "b", "a"} {
+		require.NoError(t, Append(home, Entry{
+			Timestamp:    base.Add(time.Duration(i) * time.Hour),
+			User:         "user",
+			Context:      c,
+			Distribution: "1.9.5-tetrate-v0",
+			Args:         []string{"install", "-y"},
+			ExitCode:     i,
+			Duration:     time.Second,
+		}))
+	}
+
+	entries, err = Load(home, Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, base.Add(time.Hour), entries[1].Timestamp.UTC())
+	require.Equal(t, []string{"install", "-y"}, entries[1].Args)
+	require.Equal(t, time.Second, entries[1].Duration)
+
+	entries, err = Load(home, Filter{Context: "a"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, 2, entries[1].ExitCode)
+
+	entries, err = Load(home, Filter{Since: base.Add(30 * time.Minute), Until: base.Add(90 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "b", entries[0].Context)
+
+	info, err := os.Stat(filePath(home))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestSanitizeArgs(t *testing.T) {
+	require.Equal(t,
+		[]string{"install", "--set", "values.global.remotePassword=***", "--set", "hub=gcr.io/istio",
+			"--token", "***", "--api-key=***", "-y"},
+		SanitizeArgs([]string{"install", "--set", "values.global.remotePassword=secret", "--set", "hub=gcr.io/istio",
+			"--token", "abc", "--api-key=xyz", "-y"}))
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installdiff
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// the namespace used for namespaced resources rendered without the namespace, as "istioctl install" does
+const defaultNamespace = "istio-system"
+
+// NewClusterGetter creates the Getter which fetches the live objects via the dynamic client
+func NewClusterGetter(config *rest.Config) (Getter, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating discovery client: %w", err)
+	}
+
+	cli, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating dynamic client: %w", err)
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
+	return func(desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		gvk := desired.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			// the CRD is not installed yet
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var ri dynamic.ResourceInterface = cli.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			ns := desired.GetNamespace()
+			if ns == "" {
+				ns = defaultNamespace
+			}
+			ri = cli.Resource(mapping.Resource).Namespace(ns)
+		}
+
+		live, err := ri.Get(context.Background(), desired.GetName(), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return live, err
+	}, nil
+}
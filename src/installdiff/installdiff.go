@@ -0,0 +1,217 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Status is the kind of the change to a resource
+type Status string
+
+const (
+	StatusAdded     Status = "added"
+	StatusChanged   Status = "changed"
+	StatusUnchanged Status = "unchanged"
+)
+
+// FieldDiff is a difference at the field path, e.g. "spec.template.spec.containers[0].image"
+type FieldDiff struct {
+	Path string
+	// nil if the field does not exist in the cluster
+	Live    interface{}
+	Desired interface{}
+}
+
+// ResourceDiff is the difference of a resource between the rendered manifest and the cluster
+type ResourceDiff struct {
+	Kind, Namespace, Name string
+	Status                Status
+	Fields                []FieldDiff
+}
+
+// Getter returns the live object of the desired one from the cluster, or nil if it does not exist
+type Getter func(desired *unstructured.Unstructured) (*unstructured.Unstructured, error)
+
+// ParseManifest parses the multi-document YAML rendered by "istioctl manifest generate"
+func ParseManifest(raw []byte) ([]*unstructured.Unstructured, error) {
+	var ret []*unstructured.Unstructured
+	d := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(raw), 4096)
+	for {
+		var obj map[string]interface{}
+		if err := d.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing manifest: %w", err)
+		}
+
+		if len(obj) == 0 {
+			continue
+		}
+		ret = append(ret, &unstructured.Unstructured{Object: obj})
+	}
+	return ret, nil
+}
+
+// Diff compares the desired objects with the live ones. Only the fields in the desired objects are compared
+// so that the defaults and the status filled by the cluster are not reported
+func Diff(desired []*unstructured.Unstructured, get Getter) ([]ResourceDiff, error) {
+	ret := make([]ResourceDiff, 0, len(desired))
+	for _, d := range desired {
+		rd := ResourceDiff{Kind: d.GetKind(), Namespace: d.GetNamespace(), Name: d.GetName()}
+		live, err := get(d)
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s: %w", rd.ID(), err)
+		}
+
+		if live == nil {
+			rd.Status = StatusAdded
+		} else {
+			rd.Fields = diffObject(d.Object, live.Object)
+			rd.Status = StatusUnchanged
+			if len(rd.Fields) > 0 {
+				rd.Status = StatusChanged
+			}
+		}
+		ret = append(ret, rd)
+	}
+	return ret, nil
+}
+
+// ID returns the human readable identifier of the resource
+func (r *ResourceDiff) ID() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s %s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// the metadata fields which are compared. The others are managed by the cluster
+var comparedMetadataFields = []string{"labels", "annotations"}
+
+func diffObject(desired, live map[string]interface{}) []FieldDiff {
+	var ret []FieldDiff
+	keys := make([]string, 0, len(desired))
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch k {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			dm, _ := desired[k].(map[string]interface{})
+			lm, _ := live[k].(map[string]interface{})
+			for _, f := range comparedMetadataFields {
+				ret = append(ret, diffValue("metadata."+f, dm[f], lm[f])...)
+			}
+		default:
+			ret = append(ret, diffValue(k, desired[k], live[k])...)
+		}
+	}
+	return ret
+}
+
+func diffValue(path string, desired, live interface{}) []FieldDiff {
+	switch d := desired.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return []FieldDiff{{Path: path, Desired: desired, Live: live}}
+		}
+
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var ret []FieldDiff
+		for _, k := range keys {
+			ret = append(ret, diffValue(path+"."+k, d[k], l[k])...)
+		}
+		return ret
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			return []FieldDiff{{Path: path, Desired: desired, Live: live}}
+		}
+
+		var ret []FieldDiff
+		for i := range d {
+			ret = append(ret, diffValue(fmt.Sprintf("%s[%d]", path, i), d[i], l[i])...)
+		}
+		return ret
+	default:
+		if !scalarEqual(desired, live) {
+			return []FieldDiff{{Path: path, Desired: desired, Live: live}}
+		}
+		return nil
+	}
+}
+
+// compare scalars ignoring the difference of the number types between YAML and JSON decoders
+func scalarEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// Print writes the per-resource diff followed by the summary
+func Print(w io.Writer, diffs []ResourceDiff) {
+	var added, changed, unchanged int
+	for _, d := range diffs {
+		switch d.Status {
+		case StatusAdded:
+			added++
+			fmt.Fprintf(w, "+ %s (new)\n", d.ID())
+		case StatusChanged:
+			changed++
+			fmt.Fprintf(w, "~ %s\n", d.ID())
+			for _, f := range d.Fields {
+				if f.Live == nil {
+					fmt.Fprintf(w, "    + %s: %s\n", f.Path, formatValue(f.Desired))
+				} else {
+					fmt.Fprintf(w, "    ~ %s: %s -> %s\n", f.Path, formatValue(f.Live), formatValue(f.Desired))
+				}
+			}
+		default:
+			unchanged++
+		}
+	}
+	fmt.Fprintf(w, "\n%d to add, %d to change, %d unchanged\n", added, changed, unchanged)
+}
+
+func formatValue(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(string(raw))
+}
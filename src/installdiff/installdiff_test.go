@@ -0,0 +1,131 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installdiff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testManifest = `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: istiod
+  namespace: istio-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: istiod
+  namespace: istio-system
+  labels:
+    app: istiod
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: discovery
+        image: docker.io/istio/pilot:1.9.5
+        args: ["discovery", "--monitoringAddr=:15014"]
+---
+# empty document
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: istio
+  namespace: istio-system
+data:
+  mesh: "accessLogFile: /dev/stdout"
+`
+
+func TestDiff(t *testing.T) {
+	desired, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+	require.Len(t, desired, 3)
+
+	live := map[string]map[string]interface{}{
+		"Deployment": {
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name": "istiod", "namespace": "istio-system", "uid": "xxx",
+				"labels": map[string]interface{}{"app": "istiod", "istio.io/rev": "default"},
+			},
+			"spec": map[string]interface{}{
+				"replicas":             int64(1),
+				"revisionHistoryLimit": int64(10),
+				"template": map[string]interface{}{"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{
+						"name":  "discovery",
+						"image": "docker.io/istio/pilot:1.9.4",
+						"args":  []interface{}{"discovery"},
+					}},
+				}},
+			},
+			"status": map[string]interface{}{"replicas": int64(1)},
+		},
+		"ConfigMap": {
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "istio", "namespace": "istio-system"},
+			"data":       map[string]interface{}{"mesh": "accessLogFile: /dev/stdout"},
+		},
+	}
+
+	diffs, err := Diff(desired, func(d *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		if obj, ok := live[d.GetKind()]; ok {
+			return &unstructured.Unstructured{Object: obj}, nil
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []ResourceDiff{
+		{Kind: "ServiceAccount", Namespace: "istio-system", Name: "istiod", Status: StatusAdded},
+		{Kind: "Deployment", Namespace: "istio-system", Name: "istiod", Status: StatusChanged, Fields: []FieldDiff{
+			{
+				Path:    "spec.template.spec.containers[0].args",
+				Desired: []interface{}{"discovery", "--monitoringAddr=:15014"},
+				Live:    []interface{}{"discovery"},
+			},
+			{
+				Path:    "spec.template.spec.containers[0].image",
+				Desired: "docker.io/istio/pilot:1.9.5",
+				Live:    "docker.io/istio/pilot:1.9.4",
+			},
+		}},
+		{Kind: "ConfigMap", Namespace: "istio-system", Name: "istio", Status: StatusUnchanged},
+	}, diffs)
+
+	buf := new(bytes.Buffer)
+	Print(buf, diffs)
+	require.Equal(t, `+ ServiceAccount istio-system/istiod (new)
+~ Deployment istio-system/istiod
+    ~ spec.template.spec.containers[0].args: ["discovery"] -> ["discovery","--monitoringAddr=:15014"]
+    ~ spec.template.spec.containers[0].image: "docker.io/istio/pilot:1.9.4" -> "docker.io/istio/pilot:1.9.5"
+
+1 to add, 1 to change, 1 unchanged
+`, buf.String())
+}
+
+func TestParseManifest_invalid(t *testing.T) {
+	_, err := ParseManifest([]byte("kind: [a"))
+	require.Error(t, err)
+}
@@ -0,0 +1,310 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"strings"
+)
+
+type flagSpec struct {
+	long, short string
+	hasValue    bool
+}
+
+// the flags of istioctl which getmesh needs to understand. Unknown flags are assumed to be boolean
+// unless the value is given in the form of "--flag=value", and are kept as given
+var knownFlags = []flagSpec{
+	// global
+	{long: "kubeconfig", short: "c", hasValue: true},
+	{long: "context", hasValue: true},
+	{long: "istioNamespace", short: "i", hasValue: true},
+	{long: "namespace", short: "n", hasValue: true},
+	{long: "vklog", hasValue: true},
+	{long: "log_output_level", hasValue: true},
+	{long: "log_stacktrace_level", hasValue: true},
+	{long: "log_caller", hasValue: true},
+	{long: "log_target", hasValue: true},
+	{long: "help", short: "h"},
+	// install, upgrade, uninstall, manifest, verify-install and precheck
+	{long: "filename", short: "f", hasValue: true},
+	{long: "set", short: "s", hasValue: true},
+	{long: "manifests", short: "d", hasValue: true},
+	{long: "revision", short: "r", hasValue: true},
+	{long: "charts", hasValue: true},
+	{long: "readiness-timeout", hasValue: true},
+	{long: "output", short: "o", hasValue: true},
+	{long: "skip-confirmation", short: "y"},
+	{long: "force"},
+	{long: "verify"},
+	{long: "dry-run"},
+	{long: "purge"},
+}
+
+var (
+	knownLongFlags  = map[string]flagSpec{}
+	knownShortFlags = map[string]flagSpec{}
+)
+
+func init() {
+	for _, f := range knownFlags {
+		knownLongFlags[f.long] = f
+		if f.short != "" {
+			knownShortFlags[f.short] = f
+		}
+	}
+}
+
+type commandSpec struct {
+	aliases  []string
+	children map[string]commandSpec
+}
+
+// the command tree of istioctl keyed by the canonical names
+var rootCommand = commandSpec{children: map[string]commandSpec{
+	"install":        {aliases: []string{"apply"}},
+	"upgrade":        {},
+	"uninstall":      {},
+	"verify-install": {},
+	"precheck":       {},
+	"analyze":        {},
+	"version":        {},
+	"kube-inject":    {},
+	"proxy-status":   {aliases: []string{"ps"}},
+	"proxy-config":   {aliases: []string{"pc"}},
+	"dashboard":      {aliases: []string{"dash", "d"}},
+	"manifest": {children: map[string]commandSpec{
+		"generate": {}, "diff": {}, "install": {},
+	}},
+	"profile": {children: map[string]commandSpec{
+		"list": {}, "dump": {}, "diff": {},
+	}},
+	"operator": {children: map[string]commandSpec{
+		"init": {}, "dump": {}, "remove": {},
+	}},
+	"tag": {children: tagCommands},
+	"x": {aliases: []string{"experimental", "exp"}, children: map[string]commandSpec{
+		"precheck": {}, "uninstall": {}, "analyze": {},
+		"revision": {children: map[string]commandSpec{
+			"list": {}, "describe": {}, "remove": {}, "tag": {children: tagCommands},
+		}},
+	}},
+}}
+
+var tagCommands = map[string]commandSpec{"set": {}, "remove": {}, "list": {}, "generate": {}}
+
+// the commands which mutate the cluster
+var mutatingCommands = [][]string{
+	{"install"},
+	{"upgrade"},
+	{"uninstall"},
+	{"manifest", "install"},
+	{"operator", "init"},
+	{"operator", "remove"},
+	{"tag", "set"},
+	{"tag", "remove"},
+	{"x", "uninstall"},
+	{"x", "revision", "remove"},
+	{"x", "revision", "tag", "set"},
+	{"x", "revision", "tag", "remove"},
+}
+
+func (c commandSpec) child(name string) (string, commandSpec, bool) {
+	for canonical, cc := range c.children {
+		if canonical == name {
+			return canonical, cc, true
+		}
+		for _, a := range cc.aliases {
+			if a == name {
+				return canonical, cc, true
+			}
+		}
+	}
+	return "", commandSpec{}, false
+}
+
+// Flag is a flag given to istioctl
+type Flag struct {
+	// canonical long name without dashes, e.g. "filename"
+	Name string
+	// as given without the value, e.g. "-f"
+	Given string
+	Value string
+}
+
+// Args is the model of the istioctl arguments
+type Args struct {
+	// canonical command path, e.g. ["x", "precheck"]
+	Command     []string
+	Flags       []Flag
+	Positionals []string
+	// normalized tokens where "--flag=value" is split into "--flag" and "value"
+	Tokens []string
+
+	commandPos int
+	// normalized tokens of each flag in Flags
+	flagTokens [][]string
+}
+
+// ParseArgs parses the istioctl arguments in the same way as cobra does with the known command tree and flags
+func ParseArgs(args []string) *Args {
+	ret := &Args{commandPos: -1}
+	cmd, inCommand := rootCommand, true
+	for i := 0; i < len(args); i++ {
+		a := strings.TrimSpace(args[i])
+		switch {
+		case a == "--":
+			ret.Tokens = append(ret.Tokens, args[i:]...)
+			ret.Positionals = append(ret.Positionals, args[i+1:]...)
+			return ret
+		case strings.HasPrefix(a, "-") && a != "-":
+			f, spec, ok := parseFlag(a)
+			if !ok && spec.hasValue && i+1 < len(args) {
+				i++
+				f.Value = args[i]
+				ok = true
+			}
+			ts := []string{a}
+			if ok && spec.hasValue {
+				ts = []string{f.Given, f.Value}
+			}
+			ret.Flags = append(ret.Flags, f)
+			ret.flagTokens = append(ret.flagTokens, ts)
+			ret.Tokens = append(ret.Tokens, ts...)
+		default:
+			ret.Tokens = append(ret.Tokens, a)
+			if inCommand {
+				if name, c, ok := cmd.child(a); ok {
+					ret.Command = append(ret.Command, name)
+					ret.commandPos = len(ret.Tokens) - 1
+					cmd = c
+					continue
+				}
+				inCommand = false
+			}
+			ret.Positionals = append(ret.Positionals, a)
+		}
+	}
+	return ret
+}
+
+// parse the flag token. ok is true if the value is included in the token
+func parseFlag(token string) (f Flag, spec flagSpec, ok bool) {
+	if strings.HasPrefix(token, "--") {
+		kv := strings.SplitN(token, "=", 2)
+		f.Given = kv[0]
+		spec, known := knownLongFlags[strings.TrimPrefix(kv[0], "--")]
+		if !known {
+			spec = flagSpec{long: strings.TrimPrefix(kv[0], "--")}
+		}
+		f.Name = spec.long
+		if len(kv) == 2 {
+			f.Value, ok = kv[1], true
+		}
+		return f, spec, ok
+	}
+
+	// shorthand in the form of "-f", "-f=value" or "-fvalue"
+	name := token[1:2]
+	f.Given = "-" + name
+	spec, known := knownShortFlags[name]
+	rest := token[2:]
+	if !known {
+		spec = flagSpec{long: name}
+	}
+	f.Name = spec.long
+	if (spec.hasValue && rest != "") || strings.HasPrefix(rest, "=") {
+		f.Value, ok = strings.TrimPrefix(rest, "="), true
+	}
+	return f, spec, ok
+}
+
+// IsCommand returns true if the command path is exactly the given one
+func (a *Args) IsCommand(path ...string) bool {
+	if len(a.Command) != len(path) {
+		return false
+	}
+	for i := range path {
+		if a.Command[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsMutating returns true if the command mutates the cluster
+func (a *Args) IsMutating() bool {
+	if a.Has("help") || a.Has("dry-run") {
+		return false
+	}
+	for _, c := range mutatingCommands {
+		if a.IsCommand(c...) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandPos returns the index of the last command token in Tokens, or -1 if no command is given
+func (a *Args) CommandPos() int {
+	return a.commandPos
+}
+
+// Has returns true if the flag is given
+func (a *Args) Has(name string) bool {
+	for _, f := range a.Flags {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns all the values of the flag in the given order
+func (a *Args) Values(name string) []string {
+	var ret []string
+	for _, f := range a.Flags {
+		if f.Name == name {
+			ret = append(ret, f.Value)
+		}
+	}
+	return ret
+}
+
+// Rewrite returns the tokens of the other command with the same positionals and flags except the dropped ones
+func (a *Args) Rewrite(command []string, drop ...string) []string {
+	dropped := make(map[string]bool, len(drop))
+	for _, d := range drop {
+		dropped[d] = true
+	}
+
+	ret := append([]string{}, command...)
+	for i, f := range a.Flags {
+		if !dropped[f.Name] {
+			ret = append(ret, a.flagTokens[i]...)
+		}
+	}
+	return append(ret, a.Positionals...)
+}
+
+// SetPaths returns {path -> value} given by "--set path=value"
+func (a *Args) SetPaths() map[string]string {
+	ret := map[string]string{}
+	for _, v := range a.Values("set") {
+		if kv := strings.SplitN(v, "=", 2); len(kv) == 2 {
+			ret[strings.TrimSpace(kv[0])] = kv[1]
+		}
+	}
+	return ret
+}
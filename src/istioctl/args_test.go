@@ -0,0 +1,170 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArgs(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		args       []string
+		command    []string
+		commandPos int
+		tokens     []string
+		flags      []Flag
+	}{
+		{
+			name:       "double dash",
+			args:       []string{"install", "--manifests=testfile"},
+			command:    []string{"install"},
+			tokens:     []string{"install", "--manifests", "testfile"},
+			flags:      []Flag{{Name: "manifests", Given: "--manifests", Value: "testfile"}},
+			commandPos: 0,
+		},
+		{
+			name:       "single dash",
+			args:       []string{"install", "-d=dir1/"},
+			command:    []string{"install"},
+			tokens:     []string{"install", "-d", "dir1/"},
+			flags:      []Flag{{Name: "manifests", Given: "-d", Value: "dir1/"}},
+			commandPos: 0,
+		},
+		{
+			name:       "set",
+			args:       []string{"install", "--set", "profile=demo", "-s=values.option1=true", "--set=values.global.istioNamespace=x"},
+			command:    []string{"install"},
+			tokens:     []string{"install", "--set", "profile=demo", "-s", "values.option1=true", "--set", "values.global.istioNamespace=x"},
+			commandPos: 0,
+			flags: []Flag{
+				{Name: "set", Given: "--set", Value: "profile=demo"},
+				{Name: "set", Given: "-s", Value: "values.option1=true"},
+				{Name: "set", Given: "--set", Value: "values.global.istioNamespace=x"},
+			},
+		},
+		{
+			name:       "file with dashes",
+			args:       []string{"install", "-f=dir-a/my-file.yaml", "-fother-file.yaml"},
+			command:    []string{"install"},
+			tokens:     []string{"install", "-f", "dir-a/my-file.yaml", "-f", "other-file.yaml"},
+			commandPos: 0,
+			flags: []Flag{
+				{Name: "filename", Given: "-f", Value: "dir-a/my-file.yaml"},
+				{Name: "filename", Given: "-f", Value: "other-file.yaml"},
+			},
+		},
+		{
+			name:       "bool flags",
+			args:       []string{"--set", "profile=demo", "--skip-confirmation", "--manifests=testfile/", "install", "-y", "--force=true"},
+			command:    []string{"install"},
+			tokens:     []string{"--set", "profile=demo", "--skip-confirmation", "--manifests", "testfile/", "install", "-y", "--force=true"},
+			commandPos: 5,
+			flags: []Flag{
+				{Name: "set", Given: "--set", Value: "profile=demo"},
+				{Name: "skip-confirmation", Given: "--skip-confirmation"},
+				{Name: "manifests", Given: "--manifests", Value: "testfile/"},
+				{Name: "skip-confirmation", Given: "-y"},
+				{Name: "force", Given: "--force", Value: "true"},
+			},
+		},
+		{
+			name:       "nested command with alias",
+			args:       []string{"experimental", "precheck", "--revision=canary", "-i", "istio-system"},
+			command:    []string{"x", "precheck"},
+			tokens:     []string{"experimental", "precheck", "--revision", "canary", "-i", "istio-system"},
+			commandPos: 1,
+			flags: []Flag{
+				{Name: "revision", Given: "--revision", Value: "canary"},
+				{Name: "istioNamespace", Given: "-i", Value: "istio-system"},
+			},
+		},
+		{
+			name:       "command name as flag value",
+			args:       []string{"manifest", "generate", "-f", "install", "-o", "out"},
+			command:    []string{"manifest", "generate"},
+			tokens:     []string{"manifest", "generate", "-f", "install", "-o", "out"},
+			commandPos: 1,
+			flags: []Flag{
+				{Name: "filename", Given: "-f", Value: "install"},
+				{Name: "output", Given: "-o", Value: "out"},
+			},
+		},
+		{
+			name:       "positional",
+			args:       []string{"proxy-config", "clusters", "install"},
+			command:    []string{"proxy-config"},
+			tokens:     []string{"proxy-config", "clusters", "install"},
+			commandPos: 0,
+		},
+		{
+			name:       "unknown flags",
+			args:       []string{"--unknown", "analyze", "--foo=bar", "--", "install"},
+			command:    []string{"analyze"},
+			tokens:     []string{"--unknown", "analyze", "--foo=bar", "--", "install"},
+			commandPos: 1,
+			flags: []Flag{
+				{Name: "unknown", Given: "--unknown"},
+				{Name: "foo", Given: "--foo", Value: "bar"},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			actual := ParseArgs(c.args)
+			require.Equal(t, c.command, actual.Command)
+			require.Equal(t, c.commandPos, actual.CommandPos())
+			require.Equal(t, c.tokens, actual.Tokens)
+			require.Equal(t, c.flags, actual.Flags)
+		})
+	}
+}
+
+func TestArgs_SetPaths(t *testing.T) {
+	a := ParseArgs([]string{"install", "--set", "hub=gcr.io/istio", "-s=meshConfig.enableTracing=true", "--set", "invalid"})
+	require.True(t, a.IsCommand("install"))
+	require.False(t, a.IsCommand("manifest", "install"))
+	require.Equal(t, map[string]string{"hub": "gcr.io/istio", "meshConfig.enableTracing": "true"}, a.SetPaths())
+	require.Equal(t, []string{"hub=gcr.io/istio", "meshConfig.enableTracing=true", "invalid"}, a.Values("set"))
+	require.False(t, a.Has("help"))
+}
+
+func TestArgs_IsMutating(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		exp  bool
+	}{
+		{args: []string{"install", "-y"}, exp: true},
+		{args: []string{"apply", "-f", "a.yaml"}, exp: true},
+		{args: []string{"x", "uninstall", "--purge"}, exp: true},
+		{args: []string{"tag", "set", "prod", "--revision", "1-9-5"}, exp: true},
+		{args: []string{"x", "revision", "tag", "set", "prod"}, exp: true},
+		{args: []string{"x", "revision", "remove", "1-8-3"}, exp: true},
+		{args: []string{"install", "--help"}},
+		{args: []string{"install", "--dry-run"}},
+		{args: []string{"manifest", "generate"}},
+		{args: []string{"x", "revision", "list"}},
+		{args: []string{"version"}},
+	} {
+		require.Equal(t, c.exp, ParseArgs(c.args).IsMutating(), c.args)
+	}
+}
+
+func TestArgs_Rewrite(t *testing.T) {
+	a := ParseArgs([]string{"install", "-y", "--set=hub=gcr.io/istio", "-f", "a.yaml", "--readiness-timeout", "1m", "--verify"})
+	require.Equal(t, []string{"manifest", "generate", "--set", "hub=gcr.io/istio", "-f", "a.yaml"},
+		a.Rewrite([]string{"manifest", "generate"}, "skip-confirmation", "readiness-timeout", "verify"))
+}
@@ -179,11 +179,15 @@ func Exec(homeDir string, args []string) error {
 }
 
 func ExecWithWriters(homeDir string, args []string, stdout, stderr io.Writer) error {
-	conf := getmesh.GetActiveConfig()
-	if err := checkExist(homeDir, conf.IstioDistribution); err != nil {
+	return ExecDistribution(homeDir, getmesh.GetActiveConfig().IstioDistribution, args, stdout, stderr)
+}
+
+// ExecDistribution executes the fetched istioctl of the given distribution regardless of the active one
+func ExecDistribution(homeDir string, distribution *api.IstioDistribution, args []string, stdout, stderr io.Writer) error {
+	if err := checkExist(homeDir, distribution); err != nil {
 		return err
 	}
-	path := GetIstioctlPath(homeDir, conf.IstioDistribution)
+	path := GetIstioctlPath(homeDir, distribution)
 	cmd := exec.Command(path, args...)
 
 	if stdout != nil {
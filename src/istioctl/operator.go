@@ -0,0 +1,80 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const istioOperatorKind = "IstioOperator"
+
+// Operators are the IstioOperator objects in the files given by "-f"
+type Operators []*unstructured.Unstructured
+
+// ReadOperators reads the IstioOperator objects in the files. The other kinds of objects are ignored
+func ReadOperators(files []string) (Operators, error) {
+	var ret Operators
+	for _, f := range files {
+		raw, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", f, err)
+		}
+
+		d := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(raw), 4096)
+		for {
+			var obj map[string]interface{}
+			if err := d.Decode(&obj); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("error parsing %s: %w", f, err)
+			}
+
+			if u := (&unstructured.Unstructured{Object: obj}); u.GetKind() == istioOperatorKind {
+				ret = append(ret, u)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// HasPath returns true if the path of "--set", e.g. "meshConfig.accessLogFile", is set in any of the operators
+func (o Operators) HasPath(path string) bool {
+	fields := append([]string{"spec"}, strings.Split(path, ".")...)
+	for _, op := range o {
+		if _, found, _ := unstructured.NestedFieldNoCopy(op.Object, fields...); found {
+			return true
+		}
+	}
+	return false
+}
+
+// StringValue returns the string value at the path in the operators, which the last one takes precedence just like istioctl does
+func (o Operators) StringValue(path string) string {
+	fields := append([]string{"spec"}, strings.Split(path, ".")...)
+	var ret string
+	for _, op := range o {
+		if v, _, _ := unstructured.NestedString(op.Object, fields...); v != "" {
+			ret = v
+		}
+	}
+	return ret
+}
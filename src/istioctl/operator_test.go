@@ -0,0 +1,63 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOperators(t *testing.T) {
+	f, err := ioutil.TempFile("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-an-operator
+data:
+  hub: ignored
+---
+apiVersion: install.istio.io/v1alpha1
+kind: IstioOperator
+spec:
+  hub: my-registry.io/istio
+  meshConfig:
+    accessLogFile: /dev/stdout
+  values:
+    global:
+      imagePullSecrets:
+        - mine
+`)
+	require.NoError(t, err)
+
+	ops, err := ReadOperators([]string{f.Name()})
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+
+	for _, p := range []string{"hub", "meshConfig.accessLogFile", "values.global.imagePullSecrets"} {
+		require.True(t, ops.HasPath(p), p)
+	}
+	for _, p := range []string{"tag", "profile", "meshConfig.enableTracing", "data.hub"} {
+		require.False(t, ops.HasPath(p), p)
+	}
+
+	_, err = ReadOperators([]string{"not-exist.yaml"})
+	require.Error(t, err)
+}
@@ -63,7 +63,7 @@ func endOfLifeCheckerImpl(m *api.Manifest, now time.Time) error {
 			return err
 		}
 
-		if v.Minor() == currentVer.Minor() && eol.UTC().AddDate(0, -1, 0).Before(now) {
+		if v.Minor() == currentVer.Minor() && getmesh.EOLWarningStart(eol).Before(now) {
 			logger.Warnf("Your current active minor version %s is reaching the end of life on %s. "+
 				"We strongly recommend you to upgrade to the available higher minor versions: %s.\n",
 				mv, eol.Format("2006-01-02"), strings.Join(greaterVersions, ", "))
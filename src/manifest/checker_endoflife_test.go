@@ -79,6 +79,18 @@ func Test_endOfLifeChecker(t *testing.T) {
 		require.Equal(t, "", buf.String())
 	})
 
+	t.Run("warning days", func(t *testing.T) {
+		require.NoError(t, getmesh.SetIstioVersion(home, &api.IstioDistribution{Version: "1.7.1"}))
+		require.NoError(t, getmesh.SetConfigValue(home, "eol-warning-days", "90"))
+		defer func() { require.NoError(t, getmesh.UnsetConfigValue(home, "eol-warning-days")) }()
+		buf := logger.ExecuteWithLock(func() {
+			now := time.Date(2020, 8, 1, 0, 0, 0, 0, time.Local)
+			require.NoError(t, endOfLifeCheckerImpl(m, now))
+		})
+
+		require.Contains(t, buf.String(), "Your current active minor version 1.7 is reaching the end of life on 2020-10-10.")
+	})
+
 	t.Run("warn", func(t *testing.T) {
 		now := time.Date(2020, 11, 5, 0, 0, 0, 0, time.Local)
 		exp := `[WARNING] Your current active minor version %s is reaching the end of life on 2020-10-10. We strongly recommend you to upgrade to the available higher minor versions: 1.8.1-tetrate-v0, 1.9.10-tetratefips-v0, 1.9.0-istio-v0.`
@@ -27,6 +27,7 @@ import (
 	"github.com/olekukonko/tablewriter"
 
 	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/getmesh"
 	"github.com/tetratelabs/getmesh/src/util/logger"
 )
 
@@ -54,7 +55,11 @@ func FetchManifest() (ret *api.Manifest, err error) {
 			return nil, fmt.Errorf("error unmarshalling fetched manifest: %v", err)
 		}
 	} else {
-		ret, err = fetchManifest(manifestURL)
+		url := manifestURL
+		if u := getmesh.GetActiveConfig().ManifestURL; u != "" {
+			url = u
+		}
+		ret, err = fetchManifest(url)
 		if err != nil {
 			return nil, err
 		}
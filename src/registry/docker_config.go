@@ -0,0 +1,167 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// credential holds base64 encoded "username:password"
+type credential struct {
+	auth string
+}
+
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+	// the credential helpers, e.g. "desktop", "gcloud" and "ecr-login"
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// dockerCredentials are the credentials in the docker config.json and the credential helpers for the others
+type dockerCredentials struct {
+	// {registry host -> credential}
+	auths map[string]credential
+	// the helper for all the registries, and {registry host -> helper} which takes precedence
+	credsStore  string
+	credHelpers map[string]string
+}
+
+func dockerConfigPath() (string, error) {
+	if d := os.Getenv("DOCKER_CONFIG"); d != "" {
+		return filepath.Join(d, "config.json"), nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".docker", "config.json"), nil
+}
+
+// load the credentials from the docker config.json
+func loadDockerCredentials() (*dockerCredentials, error) {
+	p, err := dockerConfigPath()
+	if err != nil {
+		return nil, fmt.Errorf("error locating docker config: %w", err)
+	}
+
+	raw, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return &dockerCredentials{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading docker config at %s: %w", p, err)
+	}
+	return parseDockerCredentials(raw)
+}
+
+func parseDockerCredentials(raw []byte) (*dockerCredentials, error) {
+	var conf dockerConfig
+	if err := json.Unmarshal(raw, &conf); err != nil {
+		return nil, fmt.Errorf("error unmarshalling docker config: %w", err)
+	}
+
+	ret := &dockerCredentials{
+		auths:       make(map[string]credential, len(conf.Auths)),
+		credsStore:  conf.CredsStore,
+		credHelpers: make(map[string]string, len(conf.CredHelpers)),
+	}
+	for k, a := range conf.Auths {
+		auth := a.Auth
+		if auth == "" && a.Username != "" {
+			auth = base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+		}
+		if auth == "" {
+			continue
+		}
+		ret.auths[normalizeRegistryHost(k)] = credential{auth: auth}
+	}
+	for k, h := range conf.CredHelpers {
+		ret.credHelpers[normalizeRegistryHost(k)] = h
+	}
+	return ret, nil
+}
+
+// get returns the credential of the registry host in the same order as docker does:
+// the helper for the host, the auths in config.json, and then the default helper
+func (d *dockerCredentials) get(host string) (credential, bool) {
+	if d == nil {
+		return credential{}, false
+	}
+
+	if h, ok := d.credHelpers[host]; ok {
+		return credentialFromHelper(h, host)
+	} else if c, ok := d.auths[host]; ok {
+		return c, true
+	} else if d.credsStore != "" {
+		return credentialFromHelper(d.credsStore, host)
+	}
+	return credential{}, false
+}
+
+// execCredentialHelper runs "docker-credential-<helper> get". This is a variable for test purpose
+var execCredentialHelper = func(helper, serverURL string) ([]byte, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	return cmd.Output()
+}
+
+// get the credential via the docker credential helper protocol.
+// The errors such as the missing helper or the credential not stored in it are treated as no credential
+func credentialFromHelper(helper, host string) (credential, bool) {
+	serverURL := host
+	if host == dockerHubHost {
+		// docker stores the credential of Docker Hub with this key
+		serverURL = "https://index.docker.io/v1/"
+	}
+
+	raw, err := execCredentialHelper(helper, serverURL)
+	if err != nil {
+		return credential{}, false
+	}
+
+	var res struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	// the identity token, denoted by the username "<token>", cannot be used for the basic auth
+	if err := json.Unmarshal(raw, &res); err != nil || res.Secret == "" || res.Username == "<token>" {
+		return credential{}, false
+	}
+	return credential{auth: base64.StdEncoding.EncodeToString([]byte(res.Username + ":" + res.Secret))}, true
+}
+
+// normalize the keys in docker config.json, e.g. "https://index.docker.io/v1/", into the host
+func normalizeRegistryHost(in string) string {
+	in = strings.TrimPrefix(strings.TrimPrefix(in, "https://"), "http://")
+	in = strings.SplitN(in, "/", 2)[0]
+	switch in {
+	case "index.docker.io", dockerHubRegistry:
+		return dockerHubHost
+	}
+	return in
+}
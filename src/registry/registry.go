@@ -0,0 +1,223 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// images required by "istioctl install"
+var RequiredIstioImages = []string{"pilot", "proxyv2"}
+
+var ErrImageNotFound = errors.New("image not found")
+
+const (
+	dockerHubHost     = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// accepted manifest media types of both OCI and Docker, including the multi-arch indexes
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// Client talks to container registries via the OCI distribution API
+type Client struct {
+	httpClient *http.Client
+	creds      *dockerCredentials
+}
+
+// NewClient creates the client with the credentials in the docker config.json and its credential helpers
+func NewClient() (*Client, error) {
+	creds, err := loadDockerCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{httpClient: &http.Client{Timeout: 30 * time.Second}, creds: creds}, nil
+}
+
+// VerifyHub checks that all the images exist in the hub with the given tag
+func (c *Client) VerifyHub(hub, tag string, images []string) error {
+	for _, img := range images {
+		if err := c.ImageExists(hub, img, tag); err != nil {
+			return fmt.Errorf("%s/%s:%s: %w", hub, img, tag, err)
+		}
+	}
+	return nil
+}
+
+// ImageExists returns nil if the image manifest is found in the registry, ErrImageNotFound if it does not exist
+func (c *Client) ImageExists(hub, image, tag string) error {
+	host, repo := parseHub(hub, image)
+	u := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registryEndpoint(host), repo, tag)
+
+	res, err := c.headManifest(u, "")
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		authz, err := c.authorize(host, res.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return fmt.Errorf("error authenticating against %s: %w", host, err)
+		}
+
+		if res, err = c.headManifest(u, authz); err != nil {
+			return err
+		}
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrImageNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("access denied by %s. Please make sure you have logged in by \"docker login %s\"", host, host)
+	default:
+		return fmt.Errorf("unexpected status from %s: %s", host, res.Status)
+	}
+}
+
+func (c *Client) headManifest(u, authz string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ","))
+	if authz != "" {
+		req.Header.Set("Authorization", authz)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting %s: %w", u, err)
+	}
+	res.Body.Close()
+	return res, nil
+}
+
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// construct the Authorization header value from the WWW-Authenticate challenge
+// https://docs.docker.com/registry/spec/auth/token/
+func (c *Client) authorize(host, challenge string) (string, error) {
+	cred, hasCred := c.creds.get(host)
+	ps := strings.SplitN(challenge, " ", 2)
+	switch strings.ToLower(ps[0]) {
+	case "basic":
+		if !hasCred {
+			return "", fmt.Errorf("no credential found for %s. Please log in by \"docker login %s\"", host, host)
+		}
+		return "Basic " + cred.auth, nil
+	case "bearer":
+		if len(ps) != 2 {
+			return "", fmt.Errorf("invalid challenge: %s", challenge)
+		}
+	default:
+		return "", fmt.Errorf("unsupported challenge: %s", challenge)
+	}
+
+	params := map[string]string{}
+	for _, m := range challengeParamRegexp.FindAllStringSubmatch(ps[1], -1) {
+		params[m[1]] = m[2]
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid realm in challenge: %s", challenge)
+	}
+
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if v, ok := params[k]; ok {
+			q.Set(k, v)
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCred {
+		req.Header.Set("Authorization", "Basic "+cred.auth)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting token: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request denied: %s", res.Status)
+	}
+
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading token: %w", err)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return "", fmt.Errorf("error unmarshalling token: %w", err)
+	}
+
+	if token.Token != "" {
+		return "Bearer " + token.Token, nil
+	} else if token.AccessToken != "" {
+		return "Bearer " + token.AccessToken, nil
+	}
+	return "", fmt.Errorf("empty token returned from %s", realm.Host)
+}
+
+// split the hub, e.g. "gcr.io/istio-testing", into the registry host and the repository of the image
+func parseHub(hub, image string) (host, repo string) {
+	ps := strings.SplitN(strings.TrimSuffix(hub, "/"), "/", 2)
+	// the first component is the registry host only if it looks like a host name as docker CLI does
+	if len(ps) == 2 && (strings.ContainsAny(ps[0], ".:") || ps[0] == "localhost") {
+		host, repo = ps[0], ps[1]+"/"+image
+	} else {
+		host, repo = dockerHubHost, hub+"/"+image
+	}
+
+	if host == "index.docker.io" || host == dockerHubRegistry {
+		host = dockerHubHost
+	}
+	return
+}
+
+func registryEndpoint(host string) string {
+	if host == dockerHubHost {
+		return dockerHubRegistry
+	}
+	return host
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_VerifyHub(t *testing.T) {
+	const token = "this-is-token"
+	basic := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.Header.Get("Authorization") != "Basic "+basic {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			require.Equal(t, "repository:private/pilot:pull", r.URL.Query().Get("scope"))
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"token": "%s"}`, token)))
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/v2/private/") && r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:private/pilot:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/istio/pilot/manifests/1.9.5-tetrate-v0",
+			"/v2/istio/proxyv2/manifests/1.9.5-tetrate-v0",
+			"/v2/private/pilot/manifests/1.9.5-tetrate-v0":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	t.Run("ok", func(t *testing.T) {
+		c := &Client{httpClient: srv.Client()}
+		require.NoError(t, c.VerifyHub(host+"/istio", "1.9.5-tetrate-v0", RequiredIstioImages))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		c := &Client{httpClient: srv.Client()}
+		err := c.VerifyHub(host+"/istio", "1.9.4-tetrate-v0", RequiredIstioImages)
+		require.True(t, errors.Is(err, ErrImageNotFound))
+		require.Contains(t, err.Error(), host+"/istio/pilot:1.9.4-tetrate-v0")
+	})
+
+	t.Run("token", func(t *testing.T) {
+		c := &Client{httpClient: srv.Client(), creds: &dockerCredentials{auths: map[string]credential{host: {auth: basic}}}}
+		require.NoError(t, c.ImageExists(host+"/private", "pilot", "1.9.5-tetrate-v0"))
+	})
+
+	t.Run("no credential", func(t *testing.T) {
+		c := &Client{httpClient: srv.Client()}
+		err := c.ImageExists(host+"/private", "pilot", "1.9.5-tetrate-v0")
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrImageNotFound))
+	})
+}
+
+func Test_parseHub(t *testing.T) {
+	for _, c := range []struct {
+		hub, host, repo string
+	}{
+		{hub: "docker.io/istio", host: "docker.io", repo: "istio/pilot"},
+		{hub: "index.docker.io/istio", host: "docker.io", repo: "istio/pilot"},
+		{hub: "istio", host: "docker.io", repo: "istio/pilot"},
+		{hub: "gcr.io/istio-testing", host: "gcr.io", repo: "istio-testing/pilot"},
+		{hub: "containers.istio.tetratelabs.com", host: "docker.io", repo: "containers.istio.tetratelabs.com/pilot"},
+		{hub: "localhost:5000/istio/", host: "localhost:5000", repo: "istio/pilot"},
+		{hub: "localhost/istio", host: "localhost", repo: "istio/pilot"},
+		{hub: "my.registry.com/org/team", host: "my.registry.com", repo: "org/team/pilot"},
+	} {
+		host, repo := parseHub(c.hub, "pilot")
+		require.Equal(t, c.host, host, c.hub)
+		require.Equal(t, c.repo, repo, c.hub)
+	}
+}
+
+func Test_parseDockerCredentials(t *testing.T) {
+	actual, err := parseDockerCredentials([]byte(`{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "aaa"},
+    "gcr.io": {"auth": "bbb"},
+    "https://my.registry.com/v2/": {"username": "user", "password": "pass"},
+    "empty.registry.com": {}
+  },
+  "credsStore": "desktop",
+  "credHelpers": {"https://123.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"}
+}`))
+	require.NoError(t, err)
+	require.Equal(t, &dockerCredentials{
+		auths: map[string]credential{
+			"docker.io":       {auth: "aaa"},
+			"gcr.io":          {auth: "bbb"},
+			"my.registry.com": {auth: base64.StdEncoding.EncodeToString([]byte("user:pass"))},
+		},
+		credsStore:  "desktop",
+		credHelpers: map[string]string{"123.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"},
+	}, actual)
+}
+
+func Test_dockerCredentials_get(t *testing.T) {
+	defer func(f func(string, string) ([]byte, error)) { execCredentialHelper = f }(execCredentialHelper)
+	var called []string
+	execCredentialHelper = func(helper, serverURL string) ([]byte, error) {
+		called = append(called, helper+" "+serverURL)
+		switch serverURL {
+		case "https://index.docker.io/v1/", "123.dkr.ecr.us-east-1.amazonaws.com":
+			return []byte(`{"ServerURL":"` + serverURL + `","Username":"user","Secret":"pass"}`), nil
+		case "identity.registry.com":
+			return []byte(`{"ServerURL":"` + serverURL + `","Username":"<token>","Secret":"refresh"}`), nil
+		}
+		return nil, errors.New("credentials not found in native keychain")
+	}
+
+	creds := &dockerCredentials{
+		auths:       map[string]credential{"gcr.io": {auth: "bbb"}},
+		credsStore:  "desktop",
+		credHelpers: map[string]string{"123.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"},
+	}
+	basic := credential{auth: base64.StdEncoding.EncodeToString([]byte("user:pass"))}
+
+	for _, c := range []struct {
+		host   string
+		exp    credential
+		ok     bool
+		called string
+	}{
+		{host: "gcr.io", exp: credential{auth: "bbb"}, ok: true},
+		{host: "docker.io", exp: basic, ok: true, called: "desktop https://index.docker.io/v1/"},
+		{host: "123.dkr.ecr.us-east-1.amazonaws.com", exp: basic, ok: true,
+			called: "ecr-login 123.dkr.ecr.us-east-1.amazonaws.com"},
+		{host: "identity.registry.com", called: "desktop identity.registry.com"},
+		{host: "unknown.registry.com", called: "desktop unknown.registry.com"},
+	} {
+		called = nil
+		actual, ok := creds.get(c.host)
+		require.Equal(t, c.ok, ok, c.host)
+		require.Equal(t, c.exp, actual, c.host)
+		if c.called == "" {
+			require.Empty(t, called, c.host)
+		} else {
+			require.Equal(t, []string{c.called}, called, c.host)
+		}
+	}
+
+	// no docker config
+	_, ok := (*dockerCredentials)(nil).get("gcr.io")
+	require.False(t, ok)
+}
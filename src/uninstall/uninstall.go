@@ -0,0 +1,253 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uninstall
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	revisionLabel   = "istio.io/rev"
+	injectionLabel  = "istio-injection"
+	defaultRevision = "default"
+	sidecarName     = "istio-proxy"
+	istioAPIGroup   = "istio.io"
+)
+
+// Target is what "istioctl uninstall" removes
+type Target struct {
+	// empty for all the revisions
+	Revision string
+	// whether the cluster-wide resources including CRDs are removed
+	Purge bool
+}
+
+func (t Target) all() bool {
+	return t.Revision == "" || t.Purge
+}
+
+// Report is the list of resources that still depend on the control plane to be removed
+type Report struct {
+	InjectedNamespaces []string
+	// in the form of "${namespace}/${pod}"
+	SidecarPods []string
+	// in the form of "${kind} ${namespace}/${name}"
+	IstioResources []string
+}
+
+// Empty returns true if nothing depends on the control plane
+func (r *Report) Empty() bool {
+	return len(r.InjectedNamespaces) == 0 && len(r.SidecarPods) == 0 && len(r.IstioResources) == 0
+}
+
+// Print writes the report
+func (r *Report) Print(w io.Writer) {
+	printList(w, "Namespaces still labeled for the sidecar injection", r.InjectedNamespaces)
+	printList(w, "Pods still running sidecars", r.SidecarPods)
+	printList(w, "Istio resources which will be orphaned", r.IstioResources)
+}
+
+func printList(w io.Writer, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s (%d):\n", title, len(items))
+	for _, i := range items {
+		fmt.Fprintf(w, "  - %s\n", i)
+	}
+}
+
+// Inspector finds the resources affected by the uninstall
+type Inspector struct {
+	KubeCli   kubernetes.Interface
+	Dynamic   dynamic.Interface
+	Discovery discovery.DiscoveryInterface
+}
+
+// Inspect lists the resources which still depend on the target control plane
+func (i *Inspector) Inspect(t Target) (*Report, error) {
+	ret := &Report{}
+	var err error
+	if ret.InjectedNamespaces, err = i.injectedNamespaces(t); err != nil {
+		return nil, err
+	}
+	if ret.SidecarPods, err = i.sidecarPods(t); err != nil {
+		return nil, err
+	}
+	// the resources are left without the control plane only when all the revisions are removed
+	if t.all() {
+		if ret.IstioResources, err = i.istioResources(); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (i *Inspector) injectedNamespaces(t Target) ([]string, error) {
+	var selectors []string
+	switch {
+	case t.all():
+		selectors = []string{injectionLabel + "=enabled", revisionLabel}
+	case t.Revision == defaultRevision:
+		selectors = []string{injectionLabel + "=enabled", revisionLabel + "=" + defaultRevision}
+	default:
+		selectors = []string{revisionLabel + "=" + t.Revision}
+	}
+
+	set := map[string]bool{}
+	for _, sel := range selectors {
+		nss, err := i.KubeCli.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{LabelSelector: sel})
+		if err != nil {
+			return nil, fmt.Errorf("error listing namespaces: %w", err)
+		}
+		for _, ns := range nss.Items {
+			set[ns.Name] = true
+		}
+	}
+	return sortedKeys(set), nil
+}
+
+func (i *Inspector) sidecarPods(t Target) ([]string, error) {
+	pods, err := i.KubeCli.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+
+	var ret []string
+	for _, p := range pods.Items {
+		if !t.all() {
+			rev := p.Labels[revisionLabel]
+			if rev == "" {
+				rev = defaultRevision
+			}
+			if rev != t.Revision {
+				continue
+			}
+		}
+
+		for _, c := range p.Spec.Containers {
+			if c.Name == sidecarName {
+				ret = append(ret, p.Namespace+"/"+p.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// list the custom resources in the Istio API groups, e.g. networking.istio.io
+func (i *Inspector) istioResources() ([]string, error) {
+	_, lists, err := i.Discovery.ServerGroupsAndResources()
+	if err != nil && len(lists) == 0 {
+		return nil, fmt.Errorf("error discovering API resources: %w", err)
+	}
+
+	seen := map[schema.GroupResource]bool{}
+	var ret []string
+	for _, l := range lists {
+		gv, err := schema.ParseGroupVersion(l.GroupVersion)
+		if err != nil || !strings.HasSuffix(gv.Group, istioAPIGroup) {
+			continue
+		}
+
+		for _, r := range l.APIResources {
+			gr := schema.GroupResource{Group: gv.Group, Resource: r.Name}
+			// skip subresources and the same resources in the other versions
+			if strings.Contains(r.Name, "/") || seen[gr] || !hasVerb(r.Verbs, "list") {
+				continue
+			}
+			seen[gr] = true
+
+			objs, err := i.Dynamic.Resource(gv.WithResource(r.Name)).List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("error listing %s: %w", gr.String(), err)
+			}
+			for _, o := range objs.Items {
+				id := o.GetName()
+				if ns := o.GetNamespace(); ns != "" {
+					id = ns + "/" + id
+				}
+				ret = append(ret, r.Kind+" "+id)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+func hasVerb(verbs []string, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// RemainingIstiod returns the istiod pods of the target left in the namespace
+func RemainingIstiod(kubeCli kubernetes.Interface, namespace string, t Target) ([]string, error) {
+	sel := "app=istiod"
+	if !t.all() {
+		sel += "," + revisionLabel + "=" + t.Revision
+	}
+
+	pods, err := kubeCli.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: sel})
+	if err != nil {
+		return nil, fmt.Errorf("error listing istiod pods: %w", err)
+	}
+
+	ret := make([]string, 0, len(pods.Items))
+	for _, p := range pods.Items {
+		ret = append(ret, p.Namespace+"/"+p.Name)
+	}
+	return ret, nil
+}
+
+// WaitIstiodRemoved waits until no istiod pod of the target remains in the namespace
+func WaitIstiodRemoved(kubeCli kubernetes.Interface, namespace string, t Target, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining, err := RemainingIstiod(kubeCli, namespace, t)
+		if err != nil {
+			return err
+		} else if len(remaining) == 0 {
+			return nil
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("istiod still remains after the uninstall: %s", strings.Join(remaining, ", "))
+		}
+		time.Sleep(interval)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
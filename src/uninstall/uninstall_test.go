@@ -0,0 +1,122 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uninstall
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestInspector() *Inspector {
+	pod := func(ns, name, rev string, containers ...string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: map[string]string{}}}
+		if rev != "" {
+			p.Labels[revisionLabel] = rev
+		}
+		for _, c := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: c})
+		}
+		return p
+	}
+
+	cs := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{injectionLabel: "enabled"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{revisionLabel: "canary"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "c"}},
+		pod("a", "app-1", "default", "app", sidecarName),
+		pod("b", "app-2", "canary", "app", sidecarName),
+		pod("c", "app-3", "", "app"),
+	)
+	cs.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "networking.istio.io/v1alpha3", APIResources: []metav1.APIResource{
+			{Name: "virtualservices", Kind: "VirtualService", Namespaced: true, Verbs: []string{"get", "list"}},
+			{Name: "virtualservices/status", Kind: "VirtualService", Namespaced: true, Verbs: []string{"get"}},
+		}},
+		{GroupVersion: "networking.istio.io/v1beta1", APIResources: []metav1.APIResource{
+			{Name: "virtualservices", Kind: "VirtualService", Namespaced: true, Verbs: []string{"get", "list"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"get", "list"}},
+		}},
+	}
+
+	vs := &unstructured.Unstructured{}
+	vs.SetGroupVersionKind(schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "VirtualService"})
+	vs.SetNamespace("a")
+	vs.SetName("reviews")
+
+	return &Inspector{
+		KubeCli: cs,
+		Dynamic: fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{
+				{Group: "networking.istio.io", Version: "v1alpha3", Resource: "virtualservices"}: "VirtualServiceList",
+			}, vs),
+		Discovery: &fakediscovery.FakeDiscovery{Fake: &cs.Fake},
+	}
+}
+
+func TestInspector_Inspect(t *testing.T) {
+	i := newTestInspector()
+
+	t.Run("all", func(t *testing.T) {
+		r, err := i.Inspect(Target{})
+		require.NoError(t, err)
+		require.Equal(t, &Report{
+			InjectedNamespaces: []string{"a", "b"},
+			SidecarPods:        []string{"a/app-1", "b/app-2"},
+			IstioResources:     []string{"VirtualService a/reviews"},
+		}, r)
+
+		buf := new(bytes.Buffer)
+		r.Print(buf)
+		require.Contains(t, buf.String(), "Pods still running sidecars (2):\n  - a/app-1\n  - b/app-2\n")
+	})
+
+	t.Run("revision", func(t *testing.T) {
+		r, err := i.Inspect(Target{Revision: "canary"})
+		require.NoError(t, err)
+		require.Equal(t, &Report{InjectedNamespaces: []string{"b"}, SidecarPods: []string{"b/app-2"}}, r)
+
+		r, err = i.Inspect(Target{Revision: "default"})
+		require.NoError(t, err)
+		require.Equal(t, &Report{InjectedNamespaces: []string{"a"}, SidecarPods: []string{"a/app-1"}}, r)
+
+		r, err = i.Inspect(Target{Revision: "unused"})
+		require.NoError(t, err)
+		require.True(t, r.Empty())
+	})
+}
+
+func TestWaitIstiodRemoved(t *testing.T) {
+	cs := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "istiod-canary", Namespace: "istio-system", Labels: map[string]string{"app": "istiod", revisionLabel: "canary"},
+	}})
+
+	require.NoError(t, WaitIstiodRemoved(cs, "istio-system", Target{Revision: "default"}, 0, 0))
+	err := WaitIstiodRemoved(cs, "istio-system", Target{}, 10*time.Millisecond, time.Millisecond)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "istio-system/istiod-canary")
+}
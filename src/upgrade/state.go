@@ -0,0 +1,122 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Phase is the step of the upgrade to be executed next
+type Phase string
+
+const (
+	// install the new control plane as the revision
+	PhaseInstall Phase = "install"
+	// move the namespaces to the new revision and roll out the workloads
+	PhaseMigrate Phase = "migrate"
+	// remove the old revision
+	PhaseCleanup Phase = "cleanup"
+	// the upgrade has been completed
+	PhaseDone Phase = "done"
+)
+
+// State is persisted after each step so that the interrupted upgrade can be resumed or rolled back
+type State struct {
+	To           string `json:"to"`
+	Revision     string `json:"revision"`
+	FromRevision string `json:"from_revision"`
+	Phase        Phase  `json:"phase"`
+	// nil until the target namespaces are determined
+	Namespaces []string `json:"namespaces"`
+	Migrated   []string `json:"migrated"`
+
+	path string
+}
+
+func statePath(homedir, revision string) string {
+	return filepath.Join(homedir, "upgrade", revision+".json")
+}
+
+// NewState creates the state of a new upgrade
+func NewState(homedir, to, revision, fromRevision string, namespaces []string) *State {
+	return &State{
+		To:           to,
+		Revision:     revision,
+		FromRevision: fromRevision,
+		Phase:        PhaseInstall,
+		Namespaces:   namespaces,
+		path:         statePath(homedir, revision),
+	}
+}
+
+// LoadState returns the state of the upgrade to the revision, or nil if there's no such upgrade
+func LoadState(homedir, revision string) (*State, error) {
+	p := statePath(homedir, revision)
+	raw, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading upgrade state at %s: %w", p, err)
+	}
+
+	s := &State{path: p}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, fmt.Errorf("error unmarshalling upgrade state at %s: %w", p, err)
+	}
+	return s, nil
+}
+
+// Save persists the state
+func (s *State) Save() error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("error creating directory for upgrade state: %w", err)
+	}
+	if err := ioutil.WriteFile(s.path, raw, 0644); err != nil {
+		return fmt.Errorf("error writing upgrade state at %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Remove deletes the persisted state
+func (s *State) Remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing upgrade state at %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func (s *State) pendingNamespaces() []string {
+	done := make(map[string]bool, len(s.Migrated))
+	for _, ns := range s.Migrated {
+		done[ns] = true
+	}
+
+	var ret []string
+	for _, ns := range s.Namespaces {
+		if !done[ns] {
+			ret = append(ret, ns)
+		}
+	}
+	return ret
+}
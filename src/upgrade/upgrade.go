@@ -0,0 +1,300 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	istioversion "istio.io/pkg/version"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tetratelabs/getmesh/api"
+	"github.com/tetratelabs/getmesh/src/util/logger"
+)
+
+const (
+	RevisionLabel   = "istio.io/rev"
+	InjectionLabel  = "istio-injection"
+	DefaultRevision = "default"
+
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+var ErrProxyTimeout = errors.New("timed out waiting for proxies")
+
+// Upgrader executes the revision-based canary upgrade
+type Upgrader struct {
+	KubeCli kubernetes.Interface
+	// Istioctl executes the istioctl of the target distribution
+	Istioctl func(args []string) error
+	// ProxyVersions returns the data plane versions, i.e. the ones reported by "istioctl version"
+	ProxyVersions func() ([]istioversion.ProxyInfo, error)
+	Timeout       time.Duration
+	PollInterval  time.Duration
+}
+
+// Options controls how far an upgrade proceeds in a single run
+type Options struct {
+	// the whole "istioctl install" arguments for the new revision
+	InstallArgs []string
+	// migrate only one namespace per run
+	OneNamespace bool
+	// stop before removing the old revision
+	SkipCleanup bool
+}
+
+// Run proceeds the upgrade from the persisted phase
+func (u *Upgrader) Run(s *State, opts Options) error {
+	target, err := api.IstioDistributionFromString(s.To)
+	if err != nil {
+		return err
+	}
+
+	for {
+		switch s.Phase {
+		case PhaseInstall:
+			logger.Infof("Installing %s as the revision %q\n", s.To, s.Revision)
+			if err := u.Istioctl(opts.InstallArgs); err != nil {
+				return fmt.Errorf("error installing the revision %s: %w", s.Revision, err)
+			}
+			s.Phase = PhaseMigrate
+		case PhaseMigrate:
+			if s.Namespaces == nil {
+				ns, err := u.TargetNamespaces(s.FromRevision)
+				if err != nil {
+					return err
+				}
+				s.Namespaces = ns
+				if err := s.Save(); err != nil {
+					return err
+				}
+			}
+
+			for _, ns := range s.pendingNamespaces() {
+				logger.Infof("Migrating namespace %s to the revision %q\n", ns, s.Revision)
+				if err := u.migrate(ns, s.Revision, func(v string) bool { return v == target.ImageTag() }); err != nil {
+					return err
+				}
+				s.Migrated = append(s.Migrated, ns)
+				if err := s.Save(); err != nil {
+					return err
+				}
+
+				if !opts.OneNamespace {
+					continue
+				}
+				// stop after each namespace, including the last one, so that it can be verified before the cleanup
+				next := "migrate the next namespace"
+				if len(s.pendingNamespaces()) == 0 {
+					s.Phase = PhaseCleanup
+					if err := s.Save(); err != nil {
+						return err
+					}
+					next = fmt.Sprintf("remove the old revision %q", s.FromRevision)
+				}
+				logger.Infof("Namespace %s has been migrated. Verify it and run the same command again to %s\n", ns, next)
+				return nil
+			}
+			s.Phase = PhaseCleanup
+		case PhaseCleanup:
+			if opts.SkipCleanup {
+				logger.Infof("All the namespaces have been migrated. Run the same command without \"--skip-cleanup\" "+
+					"to remove the old revision %q\n", s.FromRevision)
+				return nil
+			}
+
+			logger.Infof("Removing the old revision %q\n", s.FromRevision)
+			if err := u.Istioctl([]string{"x", "uninstall", "--revision", s.FromRevision, "-y"}); err != nil {
+				return fmt.Errorf("error removing the old revision %s: %w", s.FromRevision, err)
+			}
+			s.Phase = PhaseDone
+		case PhaseDone:
+			logger.Infof("The upgrade to %s (revision %q) has been completed\n", s.To, s.Revision)
+			return nil
+		default:
+			return fmt.Errorf("unknown upgrade phase: %s", s.Phase)
+		}
+
+		if err := s.Save(); err != nil {
+			return err
+		}
+	}
+}
+
+// Rollback moves the migrated namespaces back to the old revision and removes the new revision
+func (u *Upgrader) Rollback(s *State) error {
+	if s.Phase == PhaseDone {
+		return fmt.Errorf("the old revision %q has already been removed so the upgrade cannot be rolled back", s.FromRevision)
+	}
+
+	target, err := api.IstioDistributionFromString(s.To)
+	if err != nil {
+		return err
+	}
+
+	for len(s.Migrated) > 0 {
+		ns := s.Migrated[len(s.Migrated)-1]
+		logger.Infof("Moving namespace %s back to the revision %q\n", ns, s.FromRevision)
+		if err := u.migrate(ns, s.FromRevision, func(v string) bool { return v != target.ImageTag() }); err != nil {
+			return err
+		}
+		s.Migrated = s.Migrated[:len(s.Migrated)-1]
+		if err := s.Save(); err != nil {
+			return err
+		}
+	}
+
+	logger.Infof("Removing the revision %q\n", s.Revision)
+	if err := u.Istioctl([]string{"x", "uninstall", "--revision", s.Revision, "-y"}); err != nil {
+		return fmt.Errorf("error removing the revision %s: %w", s.Revision, err)
+	}
+	return nil
+}
+
+// TargetNamespaces returns the namespaces whose sidecars are injected by the revision
+func (u *Upgrader) TargetNamespaces(revision string) ([]string, error) {
+	selectors := []string{RevisionLabel + "=" + revision}
+	if revision == DefaultRevision {
+		selectors = append(selectors, InjectionLabel+"=enabled")
+	}
+
+	set := map[string]bool{}
+	for _, sel := range selectors {
+		nss, err := u.KubeCli.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{LabelSelector: sel})
+		if err != nil {
+			return nil, fmt.Errorf("error listing namespaces: %w", err)
+		}
+		for _, ns := range nss.Items {
+			set[ns.Name] = true
+		}
+	}
+
+	ret := make([]string, 0, len(set))
+	for ns := range set {
+		ret = append(ret, ns)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// relabel the namespace, restart the workloads and wait for the proxies to be ready
+func (u *Upgrader) migrate(ns, revision string, versionOK func(string) bool) error {
+	if err := u.relabel(ns, revision); err != nil {
+		return err
+	}
+	if err := u.restart(ns); err != nil {
+		return err
+	}
+	return u.waitProxies(ns, versionOK)
+}
+
+func (u *Upgrader) relabel(ns, revision string) error {
+	cli := u.KubeCli.CoreV1().Namespaces()
+	n, err := cli.Get(context.Background(), ns, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting namespace %s: %w", ns, err)
+	}
+
+	if n.Labels == nil {
+		n.Labels = map[string]string{}
+	}
+	// the default revision is labeled in the way compatible with non-revisioned installations
+	if revision == DefaultRevision {
+		n.Labels[InjectionLabel] = "enabled"
+		delete(n.Labels, RevisionLabel)
+	} else {
+		n.Labels[RevisionLabel] = revision
+		delete(n.Labels, InjectionLabel)
+	}
+
+	if _, err := cli.Update(context.Background(), n, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error labeling namespace %s: %w", ns, err)
+	}
+	return nil
+}
+
+// restart the workloads in the same way as "kubectl rollout restart"
+func (u *Upgrader) restart(ns string) error {
+	ctx := context.Background()
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"%s":"%s"}}}}}`,
+		restartedAtAnnotation, time.Now().Format(time.RFC3339)))
+	apps := u.KubeCli.AppsV1()
+
+	deps, err := apps.Deployments(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing deployments in %s: %w", ns, err)
+	}
+	for _, d := range deps.Items {
+		if _, err := apps.Deployments(ns).Patch(ctx, d.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("error restarting deployment %s/%s: %w", ns, d.Name, err)
+		}
+	}
+
+	sts, err := apps.StatefulSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing statefulsets in %s: %w", ns, err)
+	}
+	for _, s := range sts.Items {
+		if _, err := apps.StatefulSets(ns).Patch(ctx, s.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("error restarting statefulset %s/%s: %w", ns, s.Name, err)
+		}
+	}
+
+	dss, err := apps.DaemonSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing daemonsets in %s: %w", ns, err)
+	}
+	for _, d := range dss.Items {
+		if _, err := apps.DaemonSets(ns).Patch(ctx, d.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("error restarting daemonset %s/%s: %w", ns, d.Name, err)
+		}
+	}
+	return nil
+}
+
+// wait until all the proxies in the namespace report the expected version
+func (u *Upgrader) waitProxies(ns string, versionOK func(string) bool) error {
+	deadline := time.Now().Add(u.Timeout)
+	for {
+		proxies, err := u.ProxyVersions()
+		if err != nil {
+			return fmt.Errorf("error getting proxy versions: %w", err)
+		}
+
+		var remaining []string
+		for _, p := range proxies {
+			// the ID is in the form of "${pod}.${namespace}"
+			if strings.HasSuffix(p.ID, "."+ns) && !versionOK(p.IstioVersion) {
+				remaining = append(remaining, p.ID)
+			}
+		}
+
+		if len(remaining) == 0 {
+			return nil
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("%w in namespace %s: %s", ErrProxyTimeout, ns, strings.Join(remaining, ", "))
+		}
+
+		logger.Infof("Waiting for %d proxies in namespace %s to be rolled out\n", len(remaining), ns)
+		time.Sleep(u.PollInterval)
+	}
+}
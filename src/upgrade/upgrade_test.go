@@ -0,0 +1,154 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestUpgrader(t *testing.T) (*Upgrader, *[][]string) {
+	cs := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{InjectionLabel: "enabled"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{RevisionLabel: "default"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "c"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "a"}},
+	)
+
+	var executed [][]string
+	return &Upgrader{
+		KubeCli:  cs,
+		Istioctl: func(args []string) error { executed = append(executed, args); return nil },
+		// the proxies follow the revision label of their namespace immediately
+		ProxyVersions: func() ([]istioversion.ProxyInfo, error) {
+			var ret []istioversion.ProxyInfo
+			for _, ns := range []string{"a", "b", "c"} {
+				v := "1.8.3-tetrate-v0"
+				if namespaceLabels(t, cs, ns)[RevisionLabel] == "1-9-5" {
+					v = "1.9.5-tetrate-v0"
+				}
+				ret = append(ret, istioversion.ProxyInfo{ID: "pod." + ns, IstioVersion: v})
+			}
+			return ret, nil
+		},
+		Timeout:      time.Second,
+		PollInterval: time.Millisecond,
+	}, &executed
+}
+
+func namespaceLabels(t *testing.T, cs kubernetes.Interface, ns string) map[string]string {
+	n, err := cs.CoreV1().Namespaces().Get(context.Background(), ns, metav1.GetOptions{})
+	require.NoError(t, err)
+	return n.Labels
+}
+
+func TestUpgrader_Run(t *testing.T) {
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	u, executed := newTestUpgrader(t)
+	install := []string{"install", "--revision", "1-9-5", "-y"}
+	s := NewState(home, "1.9.5-tetrate-v0", "1-9-5", DefaultRevision, nil)
+
+	// migrate only the first namespace
+	require.NoError(t, u.Run(s, Options{InstallArgs: install, OneNamespace: true}))
+	require.Equal(t, [][]string{install}, *executed)
+	require.Equal(t, map[string]string{RevisionLabel: "1-9-5"}, namespaceLabels(t, u.KubeCli, "a"))
+	require.Equal(t, map[string]string{RevisionLabel: "default"}, namespaceLabels(t, u.KubeCli, "b"))
+	d, err := u.KubeCli.AppsV1().Deployments("a").Get(context.Background(), "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, d.Spec.Template.Annotations, restartedAtAnnotation)
+
+	// resume from the persisted state
+	s, err = LoadState(home, "1-9-5")
+	require.NoError(t, err)
+	require.Equal(t, PhaseMigrate, s.Phase)
+	require.Equal(t, []string{"a", "b"}, s.Namespaces)
+	require.Equal(t, []string{"a"}, s.Migrated)
+
+	require.NoError(t, u.Run(s, Options{InstallArgs: install, SkipCleanup: true}))
+	require.Equal(t, PhaseCleanup, s.Phase)
+	require.Equal(t, map[string]string{RevisionLabel: "1-9-5"}, namespaceLabels(t, u.KubeCli, "b"))
+	require.Empty(t, namespaceLabels(t, u.KubeCli, "c"))
+	require.Len(t, *executed, 1)
+
+	require.NoError(t, u.Run(s, Options{InstallArgs: install}))
+	require.Equal(t, PhaseDone, s.Phase)
+	require.Equal(t, []string{"x", "uninstall", "--revision", "default", "-y"}, (*executed)[1])
+
+	require.Error(t, u.Rollback(s))
+}
+
+func TestUpgrader_Run_oneNamespace(t *testing.T) {
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	u, executed := newTestUpgrader(t)
+	s := NewState(home, "1.9.5-tetrate-v0", "1-9-5", DefaultRevision, []string{"a"})
+	opts := Options{InstallArgs: []string{"install"}, OneNamespace: true}
+
+	// the old revision must not be removed in the same run as the last namespace is migrated
+	require.NoError(t, u.Run(s, opts))
+	require.Equal(t, PhaseCleanup, s.Phase)
+	require.Equal(t, map[string]string{RevisionLabel: "1-9-5"}, namespaceLabels(t, u.KubeCli, "a"))
+	require.Len(t, *executed, 1)
+
+	s, err = LoadState(home, "1-9-5")
+	require.NoError(t, err)
+	require.Equal(t, PhaseCleanup, s.Phase)
+	require.NoError(t, u.Run(s, opts))
+	require.Equal(t, PhaseDone, s.Phase)
+	require.Equal(t, []string{"x", "uninstall", "--revision", "default", "-y"}, (*executed)[1])
+}
+
+func TestUpgrader_Rollback(t *testing.T) {
+	home, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	u, executed := newTestUpgrader(t)
+	s := NewState(home, "1.9.5-tetrate-v0", "1-9-5", DefaultRevision, []string{"a"})
+	require.NoError(t, u.Run(s, Options{InstallArgs: []string{"install"}, SkipCleanup: true}))
+	require.Equal(t, map[string]string{RevisionLabel: "1-9-5"}, namespaceLabels(t, u.KubeCli, "a"))
+
+	require.NoError(t, u.Rollback(s))
+	require.Equal(t, map[string]string{InjectionLabel: "enabled"}, namespaceLabels(t, u.KubeCli, "a"))
+	require.Empty(t, s.Migrated)
+	require.Equal(t, []string{"x", "uninstall", "--revision", "1-9-5", "-y"}, (*executed)[1])
+}
+
+func TestUpgrader_waitProxies(t *testing.T) {
+	u, _ := newTestUpgrader(t)
+	u.Timeout = 10 * time.Millisecond
+	err := u.waitProxies("a", func(v string) bool { return v == "1.9.5-tetrate-v0" })
+	require.True(t, errors.Is(err, ErrProxyTimeout))
+	require.Contains(t, err.Error(), "pod.a")
+
+	require.NoError(t, u.waitProxies("a", func(v string) bool { return v == "1.8.3-tetrate-v0" }))
+}
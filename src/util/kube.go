@@ -17,6 +17,7 @@ package util
 import (
 	"fmt"
 	"os"
+	"sort"
 
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -25,6 +26,9 @@ import (
 
 var KubeConfig string
 
+// InCluster makes the clients use the service account of the pod instead of the kubeconfig
+var InCluster bool
+
 func GetKubeConfigLocation() string {
 	kubeconfig := KubeConfig
 	if kubeconfig != "" {
@@ -37,6 +41,14 @@ func GetKubeConfigLocation() string {
 	return kubeconfig
 }
 func GetK8sConfig() (*rest.Config, error) {
+	if InCluster {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error building in-cluster config: %w", err)
+		}
+		return config, nil
+	}
+
 	kubeconfig := GetKubeConfigLocation()
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
@@ -58,3 +70,49 @@ func GetK8sClient() (*kubernetes.Clientset, error) {
 
 	return kubeCli, nil
 }
+
+// GetK8sClientForContext creates the client for the context in the kubeconfig, or the current context if empty
+func GetK8sClientForContext(context string) (*kubernetes.Clientset, error) {
+	if context == "" {
+		return GetK8sClient()
+	}
+
+	kubeconfig := GetKubeConfigLocation()
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error building config for the context %s from kubeconfig located in %s: %w", context, kubeconfig, err)
+	}
+
+	kubeCli, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate k8s client: %w", err)
+	}
+	return kubeCli, nil
+}
+
+// GetKubeContext returns the current context of the kubeconfig
+func GetKubeContext(kubeconfig string) (string, error) {
+	conf, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("error loading kubeconfig located in %s: %w", kubeconfig, err)
+	}
+	return conf.CurrentContext, nil
+}
+
+// GetKubeContexts returns the sorted names of all the contexts in the kubeconfig
+func GetKubeContexts(kubeconfig string) ([]string, error) {
+	conf, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubeconfig located in %s: %w", kubeconfig, err)
+	}
+
+	ret := make([]string, 0, len(conf.Contexts))
+	for name := range conf.Contexts {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
@@ -15,6 +15,7 @@
 package util
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -53,3 +54,43 @@ func TestGetKubeConfigLocation(t *testing.T) {
 		KubeConfig = "" //cleanup
 	})
 }
+
+func TestGetKubeContexts(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`apiVersion: v1
+kind: Config
+current-context: prod
+contexts:
+- name: prod
+  context: {cluster: prod}
+- name: dev
+  context: {cluster: dev}
+`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	actual, err := GetKubeContexts(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev", "prod"}, actual)
+
+	current, err := GetKubeContext(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, "prod", current)
+}
+
+func TestGetK8sConfig_InCluster(t *testing.T) {
+	InCluster = true
+	defer func() { InCluster = false }()
+
+	// not running in a pod
+	original := os.Getenv("KUBERNETES_SERVICE_HOST")
+	os.Setenv("KUBERNETES_SERVICE_HOST", "")
+	defer os.Setenv("KUBERNETES_SERVICE_HOST", original)
+
+	_, err := GetK8sConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "in-cluster")
+}